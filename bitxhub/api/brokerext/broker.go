@@ -0,0 +1,142 @@
+// Package brokerext defines the gRPC methods of bitxhub which are not part of
+// the ChainBroker service generated from bitxhub-model. The service reuses the
+// messages of bitxhub-model, so it is written by hand in the same form as the
+// generated code.
+package brokerext
+
+import (
+	"context"
+
+	"github.com/meshplus/bitxhub-model/pb"
+	"google.golang.org/grpc"
+)
+
+const serviceName = "pb.ChainBrokerExt"
+
+// ChainBrokerExtClient is the client API for ChainBrokerExt service.
+type ChainBrokerExtClient interface {
+	AddVPNode(ctx context.Context, in *pb.VpInfo, opts ...grpc.CallOption) (*pb.Response, error)
+	OrderStatus(ctx context.Context, in *pb.Request, opts ...grpc.CallOption) (*pb.Response, error)
+	GetTransactionStatus(ctx context.Context, in *pb.TransactionHashMsg, opts ...grpc.CallOption) (*pb.Response, error)
+}
+
+type chainBrokerExtClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewChainBrokerExtClient(cc *grpc.ClientConn) ChainBrokerExtClient {
+	return &chainBrokerExtClient{cc}
+}
+
+func (c *chainBrokerExtClient) AddVPNode(ctx context.Context, in *pb.VpInfo, opts ...grpc.CallOption) (*pb.Response, error) {
+	out := new(pb.Response)
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/AddVPNode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainBrokerExtClient) OrderStatus(ctx context.Context, in *pb.Request, opts ...grpc.CallOption) (*pb.Response, error) {
+	out := new(pb.Response)
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/OrderStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainBrokerExtClient) GetTransactionStatus(ctx context.Context, in *pb.TransactionHashMsg, opts ...grpc.CallOption) (*pb.Response, error) {
+	out := new(pb.Response)
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/GetTransactionStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ChainBrokerExtServer is the server API for ChainBrokerExt service.
+type ChainBrokerExtServer interface {
+	AddVPNode(context.Context, *pb.VpInfo) (*pb.Response, error)
+	OrderStatus(context.Context, *pb.Request) (*pb.Response, error)
+	GetTransactionStatus(context.Context, *pb.TransactionHashMsg) (*pb.Response, error)
+}
+
+func RegisterChainBrokerExtServer(s *grpc.Server, srv ChainBrokerExtServer) {
+	s.RegisterService(&_ChainBrokerExt_serviceDesc, srv)
+}
+
+func _ChainBrokerExt_AddVPNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(pb.VpInfo)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainBrokerExtServer).AddVPNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + serviceName + "/AddVPNode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainBrokerExtServer).AddVPNode(ctx, req.(*pb.VpInfo))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChainBrokerExt_OrderStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(pb.Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainBrokerExtServer).OrderStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + serviceName + "/OrderStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainBrokerExtServer).OrderStatus(ctx, req.(*pb.Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChainBrokerExt_GetTransactionStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(pb.TransactionHashMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainBrokerExtServer).GetTransactionStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + serviceName + "/GetTransactionStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainBrokerExtServer).GetTransactionStatus(ctx, req.(*pb.TransactionHashMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _ChainBrokerExt_serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*ChainBrokerExtServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddVPNode",
+			Handler:    _ChainBrokerExt_AddVPNode_Handler,
+		},
+		{
+			MethodName: "OrderStatus",
+			Handler:    _ChainBrokerExt_OrderStatus_Handler,
+		},
+		{
+			MethodName: "GetTransactionStatus",
+			Handler:    _ChainBrokerExt_GetTransactionStatus_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "broker_ext",
+}
@@ -0,0 +1,149 @@
+package brokerext
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/utilities"
+	"github.com/meshplus/bitxhub-model/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	pattern_ChainBrokerExt_AddVPNode_0 = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"v1", "addvpnode"}, "", runtime.AssumeColonVerbOpt(true)))
+
+	forward_ChainBrokerExt_AddVPNode_0 = runtime.ForwardResponseMessage
+
+	pattern_ChainBrokerExt_OrderStatus_0 = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"v1", "order_status"}, "", runtime.AssumeColonVerbOpt(true)))
+
+	forward_ChainBrokerExt_OrderStatus_0 = runtime.ForwardResponseMessage
+
+	filter_ChainBrokerExt_OrderStatus_0 = &utilities.DoubleArray{Encoding: map[string]int{}, Base: []int(nil), Check: []int(nil)}
+
+	pattern_ChainBrokerExt_GetTransactionStatus_0 = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1, 1, 0, 4, 1, 5, 2}, []string{"v1", "transaction_status", "tx_hash"}, "", runtime.AssumeColonVerbOpt(true)))
+
+	forward_ChainBrokerExt_GetTransactionStatus_0 = runtime.ForwardResponseMessage
+)
+
+func request_ChainBrokerExt_AddVPNode_0(ctx context.Context, marshaler runtime.Marshaler, client ChainBrokerExtClient, req *http.Request, pathParams map[string]string) (*pb.Response, runtime.ServerMetadata, error) {
+	var protoReq pb.VpInfo
+	var metadata runtime.ServerMetadata
+
+	newReader, berr := utilities.IOReaderFactory(req.Body)
+	if berr != nil {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", berr)
+	}
+	if err := marshaler.NewDecoder(newReader()).Decode(&protoReq); err != nil && err != io.EOF {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	msg, err := client.AddVPNode(ctx, &protoReq, grpc.Header(&metadata.HeaderMD), grpc.Trailer(&metadata.TrailerMD))
+	return msg, metadata, err
+}
+
+func request_ChainBrokerExt_OrderStatus_0(ctx context.Context, marshaler runtime.Marshaler, client ChainBrokerExtClient, req *http.Request, pathParams map[string]string) (*pb.Response, runtime.ServerMetadata, error) {
+	var protoReq pb.Request
+	var metadata runtime.ServerMetadata
+
+	if err := req.ParseForm(); err != nil {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if err := runtime.PopulateQueryParameters(&protoReq, req.Form, filter_ChainBrokerExt_OrderStatus_0); err != nil {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	msg, err := client.OrderStatus(ctx, &protoReq, grpc.Header(&metadata.HeaderMD), grpc.Trailer(&metadata.TrailerMD))
+	return msg, metadata, err
+}
+
+func request_ChainBrokerExt_GetTransactionStatus_0(ctx context.Context, marshaler runtime.Marshaler, client ChainBrokerExtClient, req *http.Request, pathParams map[string]string) (*pb.Response, runtime.ServerMetadata, error) {
+	var protoReq pb.TransactionHashMsg
+	var metadata runtime.ServerMetadata
+
+	val, ok := pathParams["tx_hash"]
+	if !ok {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "missing parameter %s", "tx_hash")
+	}
+
+	var err error
+	protoReq.TxHash, err = runtime.String(val)
+	if err != nil {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "type mismatch, parameter: %s, error: %v", "tx_hash", err)
+	}
+
+	msg, err := client.GetTransactionStatus(ctx, &protoReq, grpc.Header(&metadata.HeaderMD), grpc.Trailer(&metadata.TrailerMD))
+	return msg, metadata, err
+}
+
+// RegisterChainBrokerExtHandler registers the http handlers for service ChainBrokerExt to "mux".
+// The handlers forward requests to the grpc endpoint over "conn".
+func RegisterChainBrokerExtHandler(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	return RegisterChainBrokerExtHandlerClient(ctx, mux, NewChainBrokerExtClient(conn))
+}
+
+// RegisterChainBrokerExtHandlerClient registers the http handlers for service ChainBrokerExt
+// to "mux". The handlers forward requests to the grpc endpoint over the given implementation of "ChainBrokerExtClient".
+func RegisterChainBrokerExtHandlerClient(ctx context.Context, mux *runtime.ServeMux, client ChainBrokerExtClient) error {
+	mux.Handle("POST", pattern_ChainBrokerExt_AddVPNode_0, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+		inboundMarshaler, outboundMarshaler := runtime.MarshalerForRequest(mux, req)
+		rctx, err := runtime.AnnotateContext(ctx, mux, req)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
+			return
+		}
+		resp, md, err := request_ChainBrokerExt_AddVPNode_0(rctx, inboundMarshaler, client, req, pathParams)
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
+			return
+		}
+
+		forward_ChainBrokerExt_AddVPNode_0(ctx, mux, outboundMarshaler, w, req, resp, mux.GetForwardResponseOptions()...)
+	})
+
+	mux.Handle("GET", pattern_ChainBrokerExt_OrderStatus_0, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+		inboundMarshaler, outboundMarshaler := runtime.MarshalerForRequest(mux, req)
+		rctx, err := runtime.AnnotateContext(ctx, mux, req)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
+			return
+		}
+		resp, md, err := request_ChainBrokerExt_OrderStatus_0(rctx, inboundMarshaler, client, req, pathParams)
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
+			return
+		}
+
+		forward_ChainBrokerExt_OrderStatus_0(ctx, mux, outboundMarshaler, w, req, resp, mux.GetForwardResponseOptions()...)
+	})
+
+	mux.Handle("GET", pattern_ChainBrokerExt_GetTransactionStatus_0, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+		inboundMarshaler, outboundMarshaler := runtime.MarshalerForRequest(mux, req)
+		rctx, err := runtime.AnnotateContext(ctx, mux, req)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
+			return
+		}
+		resp, md, err := request_ChainBrokerExt_GetTransactionStatus_0(rctx, inboundMarshaler, client, req, pathParams)
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
+			return
+		}
+
+		forward_ChainBrokerExt_GetTransactionStatus_0(ctx, mux, outboundMarshaler, w, req, resp, mux.GetForwardResponseOptions()...)
+	})
+
+	return nil
+}
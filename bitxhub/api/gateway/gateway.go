@@ -13,6 +13,7 @@ import (
 
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/api/brokerext"
 	"github.com/meshplus/bitxhub/internal/loggers"
 	"github.com/meshplus/bitxhub/internal/repo"
 	"github.com/rs/cors"
@@ -98,6 +99,10 @@ func (g *Gateway) Start() error {
 		if err != nil {
 			return err
 		}
+		err = brokerext.RegisterChainBrokerExtHandler(g.ctx, g.mux, conn)
+		if err != nil {
+			return err
+		}
 
 		go func() {
 			err := g.server.ListenAndServeTLS(g.certFile, g.keyFile)
@@ -112,6 +117,10 @@ func (g *Gateway) Start() error {
 		if err != nil {
 			return err
 		}
+		err = brokerext.RegisterChainBrokerExtHandler(g.ctx, g.mux, conn)
+		if err != nil {
+			return err
+		}
 
 		go func() {
 			err := g.server.ListenAndServe()
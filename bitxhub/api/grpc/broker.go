@@ -10,6 +10,7 @@ import (
 	"github.com/grpc-ecosystem/go-grpc-middleware/ratelimit"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/api/brokerext"
 	"github.com/meshplus/bitxhub/internal/coreapi/api"
 	"github.com/meshplus/bitxhub/internal/loggers"
 	"github.com/meshplus/bitxhub/internal/repo"
@@ -84,6 +85,7 @@ func (cbs *ChainBrokerService) Start() error {
 	}
 
 	pb.RegisterChainBrokerServer(cbs.server, cbs)
+	brokerext.RegisterChainBrokerExtServer(cbs.server, cbs)
 
 	cbs.logger.WithFields(logrus.Fields{
 		"port": cbs.config.Port.Grpc,
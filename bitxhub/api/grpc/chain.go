@@ -17,6 +17,17 @@ func GetChainStatus(cbs *ChainBrokerService) (*pb.Response, error) {
 	}, nil
 }
 
+// OrderStatus returns the consensus state of this node as JSON.
+func (cbs *ChainBrokerService) OrderStatus(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	data, err := json.Marshal(cbs.api.Chain().OrderStatus())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Response{
+		Data: data,
+	}, nil
+}
+
 func GetValidators(cbs *ChainBrokerService) (*pb.Response, error) {
 	admins := cbs.genesis.Admins
 	addresses := make([]string, 0)
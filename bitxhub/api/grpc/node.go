@@ -7,6 +7,8 @@ import (
 	"fmt"
 
 	"github.com/meshplus/bitxhub-model/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func (cbs *ChainBrokerService) DelVPNode(ctx context.Context, req *pb.DelVPNodeRequest) (*pb.Response, error) {
@@ -48,3 +50,40 @@ func (cbs *ChainBrokerService) DelVPNode(ctx context.Context, req *pb.DelVPNodeR
 		Data: nil,
 	},nil
 }
+
+func (cbs *ChainBrokerService) AddVPNode(ctx context.Context, req *pb.VpInfo) (*pb.Response, error) {
+	if req.Id == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id of the new vp node must be positive")
+	}
+	if req.Pid == "" || req.Account == "" || len(req.Hosts) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "pid, account and hosts of the new vp node are required")
+	}
+
+	peersBytes, err := cbs.api.Network().PeerInfo()
+	if err != nil {
+		return nil, err
+	}
+	peers := make(map[uint64]*pb.VpInfo)
+	if err := json.Unmarshal(peersBytes, &peers); err != nil {
+		return nil, err
+	}
+	for id, peer := range peers {
+		if id == req.Id {
+			return nil, fmt.Errorf("vp node id %d already exists", req.Id)
+		}
+		if peer.Pid == req.Pid {
+			return nil, fmt.Errorf("vp node pid %s already exists", req.Pid)
+		}
+	}
+
+	if err := cbs.api.Broker().OrderReady(); err != nil {
+		return nil, err
+	}
+
+	if err := cbs.api.Broker().AddVPNode(req); err != nil {
+		return nil, err
+	}
+	return &pb.Response{
+		Data: nil,
+	}, nil
+}
@@ -2,13 +2,19 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/meshplus/bitxhub-kit/crypto"
 	"github.com/meshplus/bitxhub-kit/crypto/asym"
+	"github.com/meshplus/bitxhub-kit/storage"
 	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // SendTransaction handles transaction sent by the client.
@@ -92,6 +98,12 @@ func (cbs *ChainBrokerService) sendTransaction(tx *pb.Transaction) (string, erro
 	}
 	err := cbs.api.Broker().HandleTransaction(tx)
 	if err != nil {
+		if errors.Is(err, order.ErrBusy) {
+			return "", status.Error(codes.Unavailable, err.Error())
+		}
+		if errors.Is(err, order.ErrReplayed) {
+			return "", status.Error(codes.AlreadyExists, err.Error())
+		}
 		return "", err
 	}
 
@@ -127,3 +139,26 @@ func (cbs *ChainBrokerService) GetTransaction(ctx context.Context, req *pb.Trans
 		TxMeta: meta,
 	}, nil
 }
+
+// GetTransactionStatus returns the lifecycle status of the transaction as JSON.
+func (cbs *ChainBrokerService) GetTransactionStatus(ctx context.Context, req *pb.TransactionHashMsg) (*pb.Response, error) {
+	hash := types.NewHashByStr(req.TxHash)
+	if hash == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid format of tx hash for querying transaction status")
+	}
+	txStatus, err := cbs.api.Broker().GetTransactionStatus(hash)
+	if err != nil {
+		if errors.Is(err, storage.ErrorNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, err
+	}
+
+	data, err := json.Marshal(txStatus)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Response{
+		Data: data,
+	}, nil
+}
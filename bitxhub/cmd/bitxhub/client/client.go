@@ -19,12 +19,14 @@ var clientCMD = cli.Command{
 	Subcommands: cli.Commands{
 		accountCMD(),
 		chainCMD(),
+		orderCMD(),
 		blockCMD(),
 		networkCMD(),
 		receiptCMD(),
 		txCMD(),
 		validatorsCMD(),
 		delVPNodeCMD(),
+		addVPNodeCMD(),
 		governanceCMD(),
 	},
 }
@@ -77,3 +77,67 @@ func delVPNode(ctx *cli.Context) error {
 	fmt.Println(string(data))
 	return nil
 }
+
+func addVPNodeCMD() cli.Command {
+	return cli.Command{
+		Name:  "addVPNode",
+		Usage: "add a vp node",
+		Flags: []cli.Flag{
+			cli.Uint64Flag{
+				Name:  "id",
+				Usage: "id of vp node",
+			},
+			cli.StringFlag{
+				Name:  "pid",
+				Usage: "pid of vp node",
+			},
+			cli.StringFlag{
+				Name:  "account",
+				Usage: "account address of vp node",
+			},
+			cli.StringSliceFlag{
+				Name:  "hosts",
+				Usage: "multiaddr hosts of vp node",
+			},
+		},
+		Action: addVPNode,
+	}
+}
+
+func addVPNode(ctx *cli.Context) error {
+	vpInfo := pb.VpInfo{
+		Id:      ctx.Uint64("id"),
+		Pid:     ctx.String("pid"),
+		Account: ctx.String("account"),
+		Hosts:   ctx.StringSlice("hosts"),
+	}
+	if vpInfo.Id == 0 {
+		return fmt.Errorf("please input id")
+	}
+	if vpInfo.Pid == "" {
+		return fmt.Errorf("please input pid")
+	}
+	if vpInfo.Account == "" {
+		return fmt.Errorf("please input account")
+	}
+	if len(vpInfo.Hosts) == 0 {
+		return fmt.Errorf("please input hosts")
+	}
+
+	url, err := getURL(ctx, "addvpnode")
+	if err != nil {
+		return err
+	}
+
+	reqData, err := json.Marshal(vpInfo)
+	if err != nil {
+		return err
+	}
+	data, err := httpPost(ctx, url, reqData)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+)
+
+func orderCMD() cli.Command {
+	return cli.Command{
+		Name:  "order",
+		Usage: "Query bitxhub order info",
+		Subcommands: []cli.Command{
+			{
+				Name:   "status",
+				Usage:  "Query the consensus status of the node, such as view, primary, watermarks and pending transactions",
+				Action: getOrderStatus,
+			},
+		},
+	}
+}
+
+func getOrderStatus(ctx *cli.Context) error {
+	url, err := getURL(ctx, "order_status")
+	if err != nil {
+		return err
+	}
+
+	data, err := httpGet(ctx, url)
+	if err != nil {
+		return fmt.Errorf("http get: %w", err)
+	}
+
+	ret, err := parseResponse(data)
+	if err != nil {
+		return err
+	}
+
+	retJson, err := prettyJson(ret)
+	if err != nil {
+		return fmt.Errorf("wrong response: %w", err)
+	}
+
+	fmt.Println(retJson)
+
+	return nil
+}
@@ -22,6 +22,11 @@ func txCMD() cli.Command {
 				Usage:  "Query transaction",
 				Action: getTransaction,
 			},
+			{
+				Name:   "status",
+				Usage:  "Query transaction status: received, pooled, batched, executed or rejected",
+				Action: getTransactionStatus,
+			},
 			{
 				Name:  "send",
 				Usage: "Send transaction",
@@ -72,6 +77,38 @@ func getTransaction(ctx *cli.Context) error {
 	return nil
 }
 
+func getTransactionStatus(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("please input transaction hash")
+	}
+
+	hash := ctx.Args().Get(0)
+
+	url, err := getURL(ctx, "transaction_status/"+hash)
+	if err != nil {
+		return err
+	}
+
+	data, err := httpGet(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	ret, err := parseResponse(data)
+	if err != nil {
+		return err
+	}
+
+	retJson, err := prettyJson(ret)
+	if err != nil {
+		return fmt.Errorf("wrong response: %w", err)
+	}
+
+	fmt.Println(retJson)
+
+	return nil
+}
+
 func sendTransaction(ctx *cli.Context) error {
 	toString := ctx.String("to")
 	amount := ctx.Uint64("amount")
@@ -32,6 +32,7 @@ func main() {
 		initCMD(),
 		startCMD(),
 		keyCMD(),
+		orderCMD(),
 		versionCMD(),
 		certCMD,
 		client.LoadClientCMD(),
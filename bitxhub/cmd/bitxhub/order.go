@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/meshplus/bitxhub/internal/plugins"
+	"github.com/meshplus/bitxhub/internal/repo"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
+	"github.com/spf13/viper"
+	"github.com/urfave/cli"
+)
+
+func orderCMD() cli.Command {
+	return cli.Command{
+		Name:  "order",
+		Usage: "Operate order config",
+		Subcommands: []cli.Command{
+			{
+				Name:  "validate",
+				Usage: "Validate the rbft section of order.toml and print the effective config",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "path",
+						Usage: "Specify order.toml path, default to order.toml in repo",
+					},
+				},
+				Action: validateOrderConfig,
+			},
+			{
+				Name:  "migrate",
+				Usage: "Copy the consensus state to another storage backend, the node must be stopped",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:     "to",
+						Usage:    "Specify the target backend, leveldb or pebble",
+						Required: true,
+					},
+					cli.StringFlag{
+						Name:  "from",
+						Usage: "Specify the source backend, default to rbft.storage.backend of order.toml in repo",
+					},
+					cli.StringFlag{
+						Name:  "path",
+						Usage: "Specify the consensus storage path, default to storage/order in repo",
+					},
+				},
+				Action: migrateOrderStorage,
+			},
+			{
+				Name:  "inspect",
+				Usage: "Decode the consensus state to JSON, and delete keys of it for manual repair, the node must be stopped",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "storage",
+						Usage: "Specify the consensus storage path, default to storage/order in repo",
+					},
+					cli.StringFlag{
+						Name:  "backend",
+						Usage: "Specify the storage backend, default to rbft.storage.backend of order.toml in repo",
+					},
+					cli.StringFlag{
+						Name:  "plugin",
+						Usage: "Specify the order plugin decoding the state, default to order.plugin of bitxhub.toml in repo",
+					},
+					cli.StringFlag{
+						Name:  "kind",
+						Usage: "Only show the keys of this kind: view, new-view, checkpoint, watermark, nodes, qset, pset, cset, batch, commit or unknown",
+					},
+					cli.StringFlag{
+						Name:  "export",
+						Usage: "Write the state to this file with the raw values rather than printing it",
+					},
+					cli.StringSliceFlag{
+						Name:  "delete-state",
+						Usage: "Delete this state key, e.g. consensus.pset.0.11.digest, it can be repeated",
+					},
+					cli.StringSliceFlag{
+						Name:  "delete-batch",
+						Usage: "Delete this batch, it can be repeated",
+					},
+				},
+				Action: inspectOrderStorage,
+			},
+		},
+	}
+}
+
+func validateOrderConfig(ctx *cli.Context) error {
+	path := ctx.String("path")
+	if path == "" {
+		repoRoot := ctx.GlobalString("repo")
+		var err error
+		if len(repoRoot) == 0 {
+			if repoRoot, err = repo.PathRoot(); err != nil {
+				return err
+			}
+		}
+		path = filepath.Join(repoRoot, "order.toml")
+	}
+
+	config, err := rbftconfig.LoadFile(path)
+	if err != nil {
+		return fmt.Errorf("invalid order config: %w", err)
+	}
+
+	fmt.Printf("%s is valid, effective config:\n%s\n", path, config)
+	return nil
+}
+
+// migrateOrderStorage copies the state into a new storage next to the old one
+// and swaps them when the copy is complete, the old storage is kept as a
+// backup.
+func migrateOrderStorage(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.GlobalString("repo"))
+	if err != nil {
+		return err
+	}
+
+	from := ctx.String("from")
+	if from == "" {
+		config, err := rbftconfig.Load(repoRoot)
+		if err != nil {
+			return fmt.Errorf("invalid order config: %w", err)
+		}
+		from = config.Rbft.StorageConfig.Backend
+	}
+	to := ctx.String("to")
+	for _, backend := range []string{from, to} {
+		if backend != rbftconfig.StorageLevelDB && backend != rbftconfig.StoragePebble {
+			return fmt.Errorf("can't migrate with storage backend %q, only leveldb and pebble are persistent", backend)
+		}
+	}
+	if from == to {
+		return fmt.Errorf("the storage is already %s", to)
+	}
+
+	path := ctx.String("path")
+	if path == "" {
+		path = repo.GetStoragePath(repoRoot, "order")
+	}
+	tmpPath := path + "." + to
+	backupPath := path + "." + from + ".bak"
+	for _, p := range []string{tmpPath, backupPath} {
+		if _, err := os.Stat(p); err == nil {
+			return fmt.Errorf("%s already exists, remove it first", p)
+		}
+	}
+
+	count, err := copyOrderStorage(from, path, to, tmpPath)
+	if err != nil {
+		_ = os.RemoveAll(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(path, backupPath); err != nil {
+		return fmt.Errorf("back up %s: %w", path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("move %s to %s: %w", tmpPath, path, err)
+	}
+
+	fmt.Printf("Migrated %d keys from %s to %s, the old storage is kept at %s\n", count, from, to, backupPath)
+	fmt.Printf("Set rbft.storage.backend = \"%s\" in order.toml before starting the node\n", to)
+	return nil
+}
+
+func copyOrderStorage(from, srcPath, to, dstPath string) (int, error) {
+	if _, err := os.Stat(srcPath); err != nil {
+		return 0, fmt.Errorf("consensus storage: %w", err)
+	}
+
+	src, err := rbftstorage.Open(from, srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dst, err := rbftstorage.Open(to, dstPath)
+	if err != nil {
+		return 0, err
+	}
+	count, err := rbftstorage.Migrate(src, dst)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return count, err
+}
+
+type inspectReport struct {
+	Backend string               `json:"backend"`
+	Path    string               `json:"path"`
+	Verify  string               `json:"verify"`
+	Entries []*rbftstorage.Entry `json:"entries"`
+}
+
+func inspectOrderStorage(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.GlobalString("repo"))
+	if err != nil {
+		return err
+	}
+
+	backend := ctx.String("backend")
+	if backend == "" {
+		backend = rbftconfig.StorageLevelDB
+		if config, err := rbftconfig.Load(repoRoot); err == nil {
+			backend = config.Rbft.StorageConfig.Backend
+		}
+	}
+	path := ctx.String("storage")
+	if path == "" {
+		path = repo.GetStoragePath(repoRoot, "order")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("consensus storage: %w", err)
+	}
+
+	storage, err := rbftstorage.Open(backend, path)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	stateKeys := ctx.StringSlice("delete-state")
+	batchKeys := ctx.StringSlice("delete-batch")
+	if len(stateKeys) != 0 || len(batchKeys) != 0 {
+		return deleteOrderState(storage, stateKeys, batchKeys)
+	}
+
+	entries, err := rbftstorage.Inspect(storage, loadStateDecoder(ctx, repoRoot))
+	if err != nil {
+		return fmt.Errorf("inspect consensus storage: %w", err)
+	}
+	report := &inspectReport{
+		Backend: backend,
+		Path:    path,
+		Verify:  "ok",
+		Entries: make([]*rbftstorage.Entry, 0, len(entries)),
+	}
+	if err := storage.Verify(); err != nil {
+		report.Verify = err.Error()
+	}
+	export := ctx.String("export")
+	for _, entry := range entries {
+		if kind := ctx.String("kind"); kind != "" && entry.Kind != kind {
+			continue
+		}
+		if export == "" {
+			entry.Raw = nil
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if export == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	if err := ioutil.WriteFile(export, data, 0644); err != nil {
+		return fmt.Errorf("export consensus state: %w", err)
+	}
+	fmt.Printf("Exported %d keys to %s\n", len(report.Entries), export)
+	return nil
+}
+
+// loadStateDecoder returns the decoder of the order plugin, the state is
+// decoded without the types of the RBFT core if there is none.
+func loadStateDecoder(ctx *cli.Context, repoRoot string) rbftstorage.Decoder {
+	pluginPath := ctx.String("plugin")
+	if pluginPath == "" {
+		config, err := repo.UnmarshalConfig(viper.New(), repoRoot, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Read bitxhub config failed, decode without the order plugin: %s\n", err)
+			return nil
+		}
+		pluginPath = config.Order.Plugin
+	}
+	if !filepath.IsAbs(pluginPath) {
+		pluginPath = filepath.Join(repoRoot, pluginPath)
+	}
+
+	decode, err := plugins.LoadStateDecoder(pluginPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Decode without the order plugin: %s\n", err)
+		return nil
+	}
+	return decode
+}
+
+// deleteOrderState deletes the keys at once, the commit record is updated so
+// a storage repaired this way passes the startup check. A missing key is
+// deleted as well, it is how a key lost by a torn write is given up.
+func deleteOrderState(storage *rbftstorage.Storage, stateKeys, batchKeys []string) error {
+	b := &rbftstorage.Batch{}
+	for _, key := range stateKeys {
+		if err := checkOrderKey(storage.State, key); err != nil {
+			return fmt.Errorf("state key %s: %w", key, err)
+		}
+		b.DeleteState([]byte(key))
+	}
+	for _, key := range batchKeys {
+		if err := checkOrderKey(storage.Batches, key); err != nil {
+			return fmt.Errorf("batch %s: %w", key, err)
+		}
+		b.DeleteBatch([]byte(key))
+	}
+
+	if err := storage.Write(b, true); err != nil {
+		return fmt.Errorf("delete consensus state: %w", err)
+	}
+	fmt.Printf("Deleted %d state keys and %d batches\n", len(stateKeys), len(batchKeys))
+	return nil
+}
+
+func checkOrderKey(store rbftstorage.Store, key string) error {
+	_, err := store.Get([]byte(key))
+	if err == rbftstorage.ErrNotFound {
+		fmt.Printf("%s is not found, it is recorded as deleted\n", key)
+		return nil
+	}
+	return err
+}
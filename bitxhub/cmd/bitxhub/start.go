@@ -159,13 +159,18 @@ func handleShutdown(node *app.BitXHub, wg *sync.WaitGroup) {
 	signal.Notify(stop, syscall.SIGINT)
 
 	go func() {
-		<-stop
-		fmt.Println("received interrupt signal, shutting down...")
-		if err := node.Stop(); err != nil {
-			panic(err)
+		select {
+		case <-stop:
+			fmt.Println("received interrupt signal, shutting down...")
+			if err := node.Stop(); err != nil {
+				panic(err)
+			}
+			wg.Done()
+			os.Exit(0)
+		case <-node.Removed():
+			fmt.Println("this node has been removed from the consortium, shutting down...")
+			wg.Done()
 		}
-		wg.Done()
-		os.Exit(0)
 	}()
 }
 
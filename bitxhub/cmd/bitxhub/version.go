@@ -1,17 +1,74 @@
 package main
 
-import "github.com/urfave/cli"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/meshplus/bitxhub/internal/plugins"
+	"github.com/meshplus/bitxhub/internal/repo"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/spf13/viper"
+	"github.com/urfave/cli"
+)
 
 func versionCMD() cli.Command {
 	return cli.Command{
-		Name:   "version",
-		Usage:  "BitXHub version",
+		Name:  "version",
+		Usage: "BitXHub version",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "plugin",
+				Usage: "Specify the order plugin, default to order.plugin of bitxhub.toml in repo",
+			},
+		},
 		Action: version,
 	}
 }
 
 func version(ctx *cli.Context) error {
 	printVersion()
+	printOrderVersion(ctx)
 
 	return nil
 }
+
+// printOrderVersion prints the order engine linked into bitxhub or the
+// manifest of the order plugin the repo is configured with, the plugin is
+// only read and never opened.
+func printOrderVersion(ctx *cli.Context) {
+	fmt.Printf("Order engines: %v, order interface v%d\n", order.Engines(), order.InterfaceVersion)
+
+	pluginPath := ctx.String("plugin")
+	if pluginPath == "" {
+		repoRoot, err := repo.PathRootWithDefault(ctx.GlobalString("repo"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Find repo failed, skip the order plugin: %s\n", err)
+			return
+		}
+		config, err := repo.UnmarshalConfig(viper.New(), repoRoot, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Read bitxhub config failed, skip the order plugin: %s\n", err)
+			return
+		}
+		if _, ok := order.Lookup(config.Order.Engine); ok {
+			fmt.Printf("Order engine: %s\n", config.Order.Engine)
+			return
+		}
+		pluginPath = config.Order.Plugin
+		if !filepath.IsAbs(pluginPath) {
+			pluginPath = filepath.Join(repoRoot, pluginPath)
+		}
+	}
+
+	fmt.Printf("Order plugin: %s\n", pluginPath)
+	manifest, err := plugins.ReadManifest(pluginPath)
+	if manifest != nil {
+		fmt.Printf("Plugin manifest: %s\n", manifest)
+	}
+	if err != nil {
+		fmt.Printf("Plugin can't be loaded: %s\n", err)
+		return
+	}
+	fmt.Println("The name and the order interface the plugin declares are checked when bitxhub starts")
+}
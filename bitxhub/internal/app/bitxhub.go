@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/fdlimit"
 	"github.com/meshplus/bitxhub-kit/storage/blockfile"
 	"github.com/meshplus/bitxhub-kit/storage/leveldb"
+	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub/api/gateway"
 	"github.com/meshplus/bitxhub/api/grpc"
 	_ "github.com/meshplus/bitxhub/imports"
@@ -25,6 +27,7 @@ import (
 	"github.com/meshplus/bitxhub/internal/router"
 	"github.com/meshplus/bitxhub/internal/storages"
 	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 	"github.com/meshplus/bitxhub/pkg/peermgr"
 	"github.com/sirupsen/logrus"
 )
@@ -36,6 +39,8 @@ type BitXHub struct {
 	Router        router.Router
 	Order         order.Order
 	PeerMgr       peermgr.PeerManager
+	TxStatus      *txstatus.Index
+	ReplayFilter  *order.ReplayFilter
 
 	Monitor       *profile.Monitor
 	Pprof         *profile.Pprof
@@ -46,6 +51,9 @@ type BitXHub struct {
 	repo   *repo.Repo
 	logger logrus.FieldLogger
 
+	removedC   chan struct{} // closed when this node is removed and has shut down
+	removeOnce sync.Once
+
 	Ctx    context.Context
 	Cancel context.CancelFunc
 }
@@ -80,6 +88,7 @@ func NewBitXHub(rep *repo.Repo, orderPath string) (*BitXHub, error) {
 		order.WithRepoRoot(orderRoot),
 		order.WithStoragePath(repo.GetStoragePath(repoRoot, "order")),
 		order.WithPluginPath(rep.Config.Plugin),
+		order.WithEngine(rep.Config.Engine),
 		order.WithNodes(m),
 		order.WithID(rep.NetworkConfig.ID),
 		order.WithIsNew(rep.NetworkConfig.New),
@@ -90,6 +99,8 @@ func NewBitXHub(rep *repo.Repo, orderPath string) (*BitXHub, error) {
 		order.WithGetChainMetaFunc(bxh.Ledger.GetChainMeta),
 		order.WithGetBlockByHeightFunc(bxh.Ledger.GetBlock),
 		order.WithGetAccountNonceFunc(bxh.Ledger.GetNonce),
+		order.WithTxStatus(bxh.TxStatus),
+		order.WithReplayFilter(bxh.ReplayFilter),
 	)
 	if err != nil {
 		return nil, err
@@ -107,6 +118,9 @@ func NewBitXHub(rep *repo.Repo, orderPath string) (*BitXHub, error) {
 	bxh.Order = order
 	bxh.Router = r
 
+	// watch order.toml on changed
+	repo.WatchOrderConfig(orderRoot, &rep.ConfigChangeFeed)
+
 	return bxh, nil
 }
 
@@ -163,6 +177,12 @@ func GenerateBitXHubWithoutOrder(rep *repo.Repo) (*BitXHub, error) {
 		return nil, fmt.Errorf("create ViewExecutor: %w", err)
 	}
 
+	replayFilter, err := newReplayFilter(repoRoot, rwLdg)
+	if err != nil {
+		return nil, fmt.Errorf("create replay filter: %w", err)
+	}
+	txExec.SetReplayFilter(replayFilter)
+
 	peerMgr, err := peermgr.New(rep, loggers.Logger(loggers.P2P), rwLdg)
 	if err != nil {
 		return nil, fmt.Errorf("create peer manager: %w", err)
@@ -175,9 +195,33 @@ func GenerateBitXHubWithoutOrder(rep *repo.Repo) (*BitXHub, error) {
 		BlockExecutor: txExec,
 		ViewExecutor:  viewExec,
 		PeerMgr:       peerMgr,
+		TxStatus:      txstatus.New(txstatus.DefaultTTL),
+		ReplayFilter:  replayFilter,
+		removedC:      make(chan struct{}),
 	}, nil
 }
 
+// newReplayFilter loads the persisted replay filter and adds the blocks
+// executed after its last checkpoint.
+func newReplayFilter(repoRoot string, ldg ledger.Ledger) (*order.ReplayFilter, error) {
+	store, err := leveldb.New(repo.GetStoragePath(repoRoot, "replay"))
+	if err != nil {
+		return nil, err
+	}
+	lookUp, err := order.NewReqLookUp(store, loggers.Logger(loggers.Executor))
+	if err != nil {
+		return nil, err
+	}
+	filter := order.NewReplayFilter(lookUp, func(hash *types.Hash) bool {
+		_, err := ldg.GetTransactionMeta(hash)
+		return err == nil
+	})
+	if err := filter.Rebuild(ldg.GetChainMeta().Height, ldg.GetBlock); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
 func (bxh *BitXHub) Start() error {
 
 	if err := bxh.raiseUlimit(2048); err != nil {
@@ -214,6 +258,18 @@ func (bxh *BitXHub) Start() error {
 }
 
 func (bxh *BitXHub) Stop() error {
+	// the blocks agreed by the order are executed before the executor stops
+	if o, ok := bxh.Order.(order.GracefulStopper); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), bxh.repo.Config.Order.StopTimeout)
+		report := o.GracefulStop(ctx)
+		cancel()
+		if report.Abandoned() {
+			bxh.logger.Warnf("Order stopped with work abandoned: %s", report)
+		} else {
+			bxh.logger.Infof("Order stopped: %s", report)
+		}
+	}
+
 	if err := bxh.BlockExecutor.Stop(); err != nil {
 		return fmt.Errorf("block executor stop: %w", err)
 	}
@@ -241,6 +297,51 @@ func (bxh *BitXHub) Stop() error {
 	return nil
 }
 
+// Removed returns a channel which is closed after this node has been removed
+// from the consortium and all services have been stopped.
+func (bxh *BitXHub) Removed() <-chan struct{} {
+	return bxh.removedC
+}
+
+// handleNodeRemoved stops the write path after the order reports that this
+// node has been removed from the consortium. Depending on the config, the node
+// either keeps serving queries as a read-only node, or stops the api, the
+// executors and the ledger in order and closes the Removed channel.
+func (bxh *BitXHub) handleNodeRemoved() {
+	bxh.removeOnce.Do(func() {
+		bxh.logger.Warn("This node has been removed from the consortium")
+
+		if !bxh.repo.Config.Order.ExitOnRemoved {
+			if err := bxh.BlockExecutor.Stop(); err != nil {
+				bxh.logger.Errorf("block executor stop: %v", err)
+			}
+			if err := bxh.Router.Stop(); err != nil {
+				bxh.logger.Errorf("InterchainRouter stop: %v", err)
+			}
+			bxh.logger.Info("Bitxhub keeps running as a read-only node")
+			return
+		}
+
+		if bxh.Gateway != nil {
+			if err := bxh.Gateway.Stop(); err != nil {
+				bxh.logger.Errorf("gateway stop: %v", err)
+			}
+		}
+		if bxh.Grpc != nil {
+			if err := bxh.Grpc.Stop(); err != nil {
+				bxh.logger.Errorf("grpc stop: %v", err)
+			}
+		}
+		if err := bxh.Stop(); err != nil {
+			bxh.logger.Errorf("bitxhub stop: %v", err)
+		}
+		bxh.Ledger.Close()
+		bxh.logger.Info("Ledger closed")
+
+		close(bxh.removedC)
+	})
+}
+
 func (bxh *BitXHub) ReConfig(repo *repo.Repo) {
 	if repo.Config != nil {
 		config := repo.Config
@@ -266,6 +367,15 @@ func (bxh *BitXHub) ReConfig(repo *repo.Repo) {
 			bxh.logger.Errorf("reconfig Pprof failed: %v", err)
 		}
 	}
+	// order.toml is the only trigger of the order, a change of bitxhub.toml
+	// doesn't reload it
+	if repo.OrderConfigChanged {
+		if o, ok := bxh.Order.(order.ReConfigurable); ok {
+			if err := o.ReConfig(); err != nil {
+				bxh.logger.Errorf("reconfig Order failed: %v", err)
+			}
+		}
+	}
 	if repo.NetworkConfig != nil {
 		config := repo.NetworkConfig
 		if err := bxh.PeerMgr.ReConfig(config); err != nil {
@@ -276,10 +386,15 @@ func (bxh *BitXHub) ReConfig(repo *repo.Repo) {
 
 func (bxh *BitXHub) printLogo() {
 	for {
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-bxh.removedC:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
 		err := bxh.Order.Ready()
 		if err == nil {
 			bxh.logger.WithFields(logrus.Fields{
+				"engine":      bxh.repo.Config.Order.Engine,
 				"plugin_path": bxh.repo.Config.Order.Plugin,
 			}).Info("Order is ready")
 			fmt.Println()
@@ -1,8 +1,11 @@
 package app
 
 import (
+	"github.com/meshplus/bitxhub-model/pb"
 	"github.com/meshplus/bitxhub/internal/model/events"
 	"github.com/meshplus/bitxhub/internal/repo"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -29,18 +32,22 @@ func (bxh *BitXHub) listenEvent() {
 	blockCh := make(chan events.ExecutedEvent)
 	orderMsgCh := make(chan events.OrderMessageEvent)
 	configCh := make(chan *repo.Repo)
+	consensusCh := make(chan order.ConsensusEvent)
 
 	blockSub := bxh.BlockExecutor.SubscribeBlockEvent(blockCh)
 	orderMsgSub := bxh.PeerMgr.SubscribeOrderMessage(orderMsgCh)
 	configSub := bxh.repo.SubscribeConfigChange(configCh)
+	consensusSub := bxh.Order.SubscribeConsensusEvent(consensusCh)
 
 	defer blockSub.Unsubscribe()
 	defer orderMsgSub.Unsubscribe()
 	defer configSub.Unsubscribe()
+	defer consensusSub.Unsubscribe()
 
 	for {
 		select {
 		case ev := <-blockCh:
+			bxh.TxStatus.Set(txstatus.Executed, ev.Block.BlockHeader.Number, blockTxHashes(ev.Block)...)
 			go bxh.Order.ReportState(ev.Block.BlockHeader.Number, ev.Block.BlockHash, ev.TxHashList)
 			go bxh.Router.PutBlockAndMeta(ev.Block, ev.InterchainMeta)
 		case ev := <-orderMsgCh:
@@ -51,8 +58,24 @@ func (bxh *BitXHub) listenEvent() {
 			}()
 		case config := <-configCh:
 			bxh.ReConfig(config)
+		case ev := <-consensusCh:
+			bxh.logger.WithFields(logrus.Fields{
+				"type":   ev.Type.String(),
+				"detail": ev.Detail,
+			}).Info("Receive consensus event")
+			if ev.Type == order.NodeRemoved {
+				go bxh.handleNodeRemoved()
+			}
 		case <-bxh.Ctx.Done():
 			return
 		}
 	}
 }
+
+func blockTxHashes(block *pb.Block) []string {
+	hashes := make([]string, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		hashes = append(hashes, tx.TransactionHash.String())
+	}
+	return hashes
+}
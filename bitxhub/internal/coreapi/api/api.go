@@ -6,6 +6,8 @@ import (
 	"github.com/meshplus/bitxhub-model/pb"
 	"github.com/meshplus/bitxhub/internal/ledger"
 	"github.com/meshplus/bitxhub/internal/model/events"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 	"github.com/meshplus/bitxhub/pkg/peermgr"
 )
 
@@ -23,6 +25,7 @@ type BrokerAPI interface {
 	HandleView(tx *pb.Transaction) (*pb.Receipt, error)
 	GetTransaction(*types.Hash) (*pb.Transaction, error)
 	GetTransactionMeta(*types.Hash) (*pb.TransactionMeta, error)
+	GetTransactionStatus(*types.Hash) (*txstatus.Status, error)
 	GetReceipt(*types.Hash) (*pb.Receipt, error)
 	GetBlock(mode string, key string) (*pb.Block, error)
 	GetBlocks(start uint64, end uint64) ([]*pb.Block, error)
@@ -44,6 +47,9 @@ type BrokerAPI interface {
 	// DelVPNode delete a vp node by given id.
 	DelVPNode(delID uint64) error
 
+	// AddVPNode adds a vp node with given info.
+	AddVPNode(vpInfo *pb.VpInfo) error
+
 	FetchSignsFromOtherPeers(content string, typ pb.GetMultiSignsRequest_Type) map[string][]byte
 	GetSign(content string, typ pb.GetMultiSignsRequest_Type) (string, []byte, error)
 	GetBlockHeaders(start uint64, end uint64) ([]*pb.BlockHeader, error)
@@ -58,10 +64,13 @@ type ChainAPI interface {
 	Status() string
 	Meta() (*pb.ChainMeta, error)
 	TPS(begin, end uint64) (uint64, error)
+	OrderStatus() *order.OrderStatus
 }
 
 type FeedAPI interface {
 	SubscribeNewBlockEvent(chan<- events.ExecutedEvent) event.Subscription
+
+	SubscribeConsensusEvent(chan<- order.ConsensusEvent) event.Subscription
 }
 
 type AccountAPI interface {
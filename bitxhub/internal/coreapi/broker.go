@@ -13,6 +13,7 @@ import (
 	"github.com/meshplus/bitxhub/internal/coreapi/api"
 	"github.com/meshplus/bitxhub/internal/executor/contracts"
 	"github.com/meshplus/bitxhub/internal/model"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -33,11 +34,14 @@ func (b *BrokerAPI) HandleTransaction(tx *pb.Transaction) error {
 		"hash": tx.TransactionHash.String(),
 	}).Debugf("Receive tx")
 
-	go func() {
-		if err := b.bxh.Order.Prepare(tx); err != nil {
-			b.logger.Error(err)
-		}
-	}()
+	b.bxh.TxStatus.Set(txstatus.Received, 0, tx.TransactionHash.String())
+	if err := b.bxh.Order.Prepare(tx); err != nil {
+		b.logger.WithFields(logrus.Fields{
+			"hash": tx.TransactionHash.String(),
+		}).Warnf("Prepare tx failed: %s", err)
+		b.bxh.TxStatus.Reject(err.Error(), tx.TransactionHash.String())
+		return err
+	}
 
 	return nil
 }
@@ -68,6 +72,24 @@ func (b *BrokerAPI) GetTransactionMeta(hash *types.Hash) (*pb.TransactionMeta, e
 	return b.bxh.Ledger.GetTransactionMeta(hash)
 }
 
+// GetTransactionStatus returns the tracked status of the transaction, the
+// transactions which are not tracked anymore are looked up in the ledger.
+func (b *BrokerAPI) GetTransactionStatus(hash *types.Hash) (*txstatus.Status, error) {
+	if status, ok := b.bxh.TxStatus.Get(hash.String()); ok {
+		return status, nil
+	}
+
+	meta, err := b.bxh.Ledger.GetTransactionMeta(hash)
+	if err != nil {
+		return nil, fmt.Errorf("transaction %s is unknown: %w", hash.String(), err)
+	}
+	return &txstatus.Status{
+		Hash:   hash.String(),
+		State:  txstatus.Executed,
+		Height: meta.BlockHeight,
+	}, nil
+}
+
 func (b *BrokerAPI) GetReceipt(hash *types.Hash) (*pb.Receipt, error) {
 	return b.bxh.Ledger.GetReceipt(hash)
 }
@@ -317,3 +339,7 @@ func (b BrokerAPI) GetPendingNonceByAccount(account string) uint64 {
 func (b BrokerAPI) DelVPNode(delID uint64) error {
 	return b.bxh.Order.DelNode(delID)
 }
+
+func (b BrokerAPI) AddVPNode(vpInfo *pb.VpInfo) error {
+	return b.bxh.Order.AddNode(vpInfo)
+}
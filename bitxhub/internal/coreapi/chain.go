@@ -6,6 +6,7 @@ import (
 
 	"github.com/meshplus/bitxhub-model/pb"
 	"github.com/meshplus/bitxhub/internal/coreapi/api"
+	"github.com/meshplus/bitxhub/pkg/order"
 	"go.uber.org/atomic"
 )
 
@@ -22,6 +23,10 @@ func (api *ChainAPI) Status() string {
 	return "normal"
 }
 
+func (api *ChainAPI) OrderStatus() *order.OrderStatus {
+	return api.bxh.Order.Status()
+}
+
 func (api *ChainAPI) Meta() (*pb.ChainMeta, error) {
 	return api.bxh.Ledger.GetChainMeta(), nil
 }
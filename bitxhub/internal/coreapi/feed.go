@@ -4,6 +4,7 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/meshplus/bitxhub/internal/coreapi/api"
 	"github.com/meshplus/bitxhub/internal/model/events"
+	"github.com/meshplus/bitxhub/pkg/order"
 )
 
 type FeedAPI CoreAPI
@@ -13,3 +14,7 @@ var _ api.FeedAPI = (*FeedAPI)(nil)
 func (api *FeedAPI) SubscribeNewBlockEvent(ch chan<- events.ExecutedEvent) event.Subscription {
 	return api.bxh.BlockExecutor.SubscribeBlockEvent(ch)
 }
+
+func (api *FeedAPI) SubscribeConsensusEvent(ch chan<- order.ConsensusEvent) event.Subscription {
+	return api.bxh.Order.SubscribeConsensusEvent(ch)
+}
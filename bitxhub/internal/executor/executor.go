@@ -15,6 +15,7 @@ import (
 	"github.com/meshplus/bitxhub/internal/executor/contracts"
 	"github.com/meshplus/bitxhub/internal/ledger"
 	"github.com/meshplus/bitxhub/internal/model/events"
+	"github.com/meshplus/bitxhub/pkg/order"
 	"github.com/meshplus/bitxhub/pkg/proof"
 	"github.com/meshplus/bitxhub/pkg/vm/boltvm"
 	"github.com/sirupsen/logrus"
@@ -42,6 +43,7 @@ type BlockExecutor struct {
 	wasmInstances    map[string]wasmer.Instance
 	txsExecutor      agency.TxsExecutor
 	blockFeed        event.Feed
+	replayFilter     *order.ReplayFilter
 	ctx              context.Context
 	cancel           context.CancelFunc
 }
@@ -76,6 +78,12 @@ func New(chainLedger ledger.Ledger, logger logrus.FieldLogger, typ string) (*Blo
 	return blockExecutor, nil
 }
 
+// SetReplayFilter makes the executor drop the transactions which have been
+// executed and record the executed ones in filter.
+func (exec *BlockExecutor) SetReplayFilter(filter *order.ReplayFilter) {
+	exec.replayFilter = filter
+}
+
 // Start starts executor
 func (exec *BlockExecutor) Start() error {
 	go exec.listenExecuteEvent()
@@ -208,6 +216,9 @@ func (exec *BlockExecutor) persistData() {
 	for data := range exec.persistC {
 		now := time.Now()
 		exec.ledger.PersistBlockData(data)
+		if err := exec.replayFilter.Checkpoint(data.Block.BlockHeader.Number); err != nil {
+			exec.logger.Errorf("Checkpoint replay filter: %s", err)
+		}
 		exec.postBlockEvent(data.Block, data.InterchainMeta, data.TxHashList)
 		exec.logger.WithFields(logrus.Fields{
 			"height": data.Block.BlockHeader.Number,
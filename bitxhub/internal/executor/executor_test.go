@@ -24,6 +24,7 @@ import (
 	"github.com/meshplus/bitxhub/internal/ledger/mock_ledger"
 	"github.com/meshplus/bitxhub/internal/model/events"
 	"github.com/meshplus/bitxhub/internal/repo"
+	"github.com/meshplus/bitxhub/pkg/order"
 	libp2pcert "github.com/meshplus/go-libp2p-cert"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -65,6 +66,42 @@ func TestNew(t *testing.T) {
 	assert.Equal(t, 0, len(executor.wasmInstances))
 }
 
+func TestFilterReplayed(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	mockLedger := mock_ledger.NewMockLedger(mockCtl)
+	chainMeta := &pb.ChainMeta{
+		Height:    1,
+		BlockHash: types.NewHashByStr(from),
+	}
+	mockLedger.EXPECT().GetChainMeta().Return(chainMeta).AnyTimes()
+
+	exec, err := New(mockLedger, log.NewWithModule("executor"), executorType)
+	require.Nil(t, err)
+
+	store, err := leveldb.New(t.TempDir())
+	require.Nil(t, err)
+	defer store.Close()
+	lookUp, err := order.NewReqLookUp(store, log.NewWithModule("replay_filter"))
+	require.Nil(t, err)
+	executed := make(map[string]bool)
+	exec.SetReplayFilter(order.NewReplayFilter(lookUp, func(hash *types.Hash) bool {
+		return executed[hash.String()]
+	}))
+
+	tx1 := &pb.Transaction{TransactionHash: types.NewHash([]byte("tx1"))}
+	tx2 := &pb.Transaction{TransactionHash: types.NewHash([]byte("tx2"))}
+	txs := exec.filterReplayed(2, []*pb.Transaction{tx1, tx2, tx1})
+	require.Equal(t, []*pb.Transaction{tx1, tx2}, txs)
+
+	// tx1 is persisted in block 2 and resubmitted in block 3
+	executed[tx1.TransactionHash.String()] = true
+	executed[tx2.TransactionHash.String()] = true
+	require.Nil(t, exec.replayFilter.Checkpoint(2))
+	tx3 := &pb.Transaction{TransactionHash: types.NewHash([]byte("tx3"))}
+	txs = exec.filterReplayed(3, []*pb.Transaction{tx1, tx3})
+	require.Equal(t, []*pb.Transaction{tx3}, txs)
+}
+
 func TestBlockExecutor_ExecuteBlock(t *testing.T) {
 	mockCtl := gomock.NewController(t)
 	mockLedger := mock_ledger.NewMockLedger(mockCtl)
@@ -31,6 +31,7 @@ func (exec *BlockExecutor) processExecuteEvent(block *pb.Block) *ledger.BlockDat
 	current := time.Now()
 	var txHashList []*types.Hash
 
+	block.Transactions = exec.filterReplayed(block.BlockHeader.Number, block.Transactions)
 	for _, tx := range block.Transactions {
 		txHashList = append(txHashList, tx.TransactionHash)
 	}
@@ -97,6 +98,27 @@ func (exec *BlockExecutor) processExecuteEvent(block *pb.Block) *ledger.BlockDat
 	}
 }
 
+// filterReplayed drops the transactions which have been executed, including
+// the duplicates in the same block. Every node drops the same transactions,
+// since the filter is confirmed by the ledger.
+func (exec *BlockExecutor) filterReplayed(height uint64, txs []*pb.Transaction) []*pb.Transaction {
+	if exec.replayFilter == nil {
+		return txs
+	}
+	ret := make([]*pb.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if err := exec.replayFilter.Check(tx.TransactionHash); err != nil {
+			exec.logger.WithFields(logrus.Fields{
+				"height": height,
+			}).Warnf("Drop replayed transaction: %s", err)
+			continue
+		}
+		exec.replayFilter.Add(height, tx.TransactionHash)
+		ret = append(ret, tx)
+	}
+	return ret
+}
+
 func (exec *BlockExecutor) listenPreExecuteEvent() {
 	for {
 		select {
@@ -0,0 +1,9 @@
+package orderplg
+
+import (
+	// the order engines linked into bitxhub, they are selected by order.engine
+	// of bitxhub.toml without a plugin, rbft is linked by the rbft build tag
+	// in imports
+	_ "github.com/meshplus/bitxhub/pkg/order/etcdraft"
+	_ "github.com/meshplus/bitxhub/pkg/order/solo"
+)
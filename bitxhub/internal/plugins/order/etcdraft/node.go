@@ -5,6 +5,13 @@ import (
 	"github.com/meshplus/bitxhub/pkg/order/etcdraft"
 )
 
+// Manifest is checked by bitxhub before NewNode is called.
+var Manifest = order.Manifest{
+	Name:             "raft",
+	Version:          "1.0.0",
+	InterfaceVersion: order.InterfaceVersion,
+}
+
 func NewNode(opts ...order.Option) (order.Order, error) {
 	return etcdraft.NewNode(opts...)
 }
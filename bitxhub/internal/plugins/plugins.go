@@ -1,28 +1,64 @@
 package orderplg
 
 import (
+	"debug/buildinfo"
 	"fmt"
 	"path/filepath"
 	"plugin"
+	"runtime/debug"
 
+	"github.com/meshplus/bitxhub"
 	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
 )
 
-//Load order plugin
+// New creates the order engine selected by config. An engine registered
+// under config.Engine is created directly, an engine not linked into bitxhub
+// is looked up again after the plugin is opened, a plugin may register
+// itself in its init. Without an engine name the NewNode of the plugin is
+// called.
 func New(opts ...order.Option) (order.Order, error) {
 	config, err := order.GenerateConfig(opts...)
 	if err != nil {
 		return nil, err
 	}
-	pluginPath := config.PluginPath
 
-	if !filepath.IsAbs(pluginPath) {
-		pluginPath = filepath.Join(config.RepoRoot, pluginPath)
+	if config.Engine != "" {
+		if newNode, ok := order.Lookup(config.Engine); ok {
+			return newNode(opts...)
+		}
+		if config.PluginPath == "" {
+			return nil, fmt.Errorf("order engine %q is not registered, registered engines: %v", config.Engine, order.Engines())
+		}
 	}
 
-	p, err := plugin.Open(pluginPath)
+	newNode, err := loadPlugin(pluginPath(config))
 	if err != nil {
-		return nil, fmt.Errorf("plugin open: %s", err)
+		return nil, err
+	}
+	if config.Engine != "" {
+		registered, ok := order.Lookup(config.Engine)
+		if !ok {
+			return nil, fmt.Errorf("order engine %q is registered neither in bitxhub nor by plugin %s, registered engines: %v",
+				config.Engine, config.PluginPath, order.Engines())
+		}
+		newNode = registered
+	}
+	return newNode(opts...)
+}
+
+func pluginPath(config *order.Config) string {
+	if filepath.IsAbs(config.PluginPath) {
+		return config.PluginPath
+	}
+	return filepath.Join(config.RepoRoot, config.PluginPath)
+}
+
+// loadPlugin opens the order plugin and returns its NewNode.
+func loadPlugin(pluginPath string) (order.NewNodeFunc, error) {
+	p, _, err := openPlugin(pluginPath)
+	if err != nil {
+		return nil, err
 	}
 
 	m, err := p.Lookup("NewNode")
@@ -34,5 +70,80 @@ func New(opts ...order.Option) (order.Order, error) {
 	if !ok {
 		return nil, fmt.Errorf("assert NewOrder error")
 	}
-	return NewNode(opts...)
+	return NewNode, nil
+}
+
+// ReadManifest reads the manifest of the order plugin from its build info
+// without opening it, Go can't close a plugin and opening one runs its init.
+// The manifest has only the deps, the name and the interface version the
+// plugin declares are checked when bitxhub loads it. The manifest of a plugin
+// built with other deps than bitxhub is returned with the error telling why.
+func ReadManifest(pluginPath string) (*order.Manifest, error) {
+	info, err := buildinfo.ReadFile(pluginPath)
+	if err != nil {
+		return nil, fmt.Errorf("read build info of order plugin %s: %w", pluginPath, err)
+	}
+	manifest := &order.Manifest{Deps: order.ModuleDeps(info)}
+	host, _ := debug.ReadBuildInfo()
+	if err := order.CheckDeps(manifest.Deps, order.ModuleDeps(host)); err != nil {
+		return manifest, fmt.Errorf("order plugin %s is %w, rebuild it with the go.mod of bitxhub %s",
+			pluginPath, err, bitxhub.CurrentVersion)
+	}
+	return manifest, nil
+}
+
+// openPlugin opens the order plugin once the modules it is built with match
+// bitxhub, then checks the manifest it exports. Go refuses to open a plugin
+// built with other versions of the shared packages without telling which
+// modules differ.
+func openPlugin(pluginPath string) (*plugin.Plugin, *order.Manifest, error) {
+	manifest, err := ReadManifest(pluginPath)
+	if err != nil {
+		return nil, manifest, err
+	}
+
+	p, err := plugin.Open(pluginPath)
+	if err != nil {
+		return nil, manifest, fmt.Errorf("plugin open: %s", err)
+	}
+
+	m, err := p.Lookup(order.ManifestSymbol)
+	if err != nil {
+		return nil, manifest, fmt.Errorf("order plugin %s exports no manifest, rebuild it against bitxhub %s: %s",
+			pluginPath, bitxhub.CurrentVersion, err)
+	}
+	declared, ok := m.(*order.Manifest)
+	if !ok {
+		return nil, manifest, fmt.Errorf("assert Manifest error")
+	}
+	manifest = &order.Manifest{
+		Name:             declared.Name,
+		Version:          declared.Version,
+		InterfaceVersion: declared.InterfaceVersion,
+		Deps:             manifest.Deps,
+	}
+	if err := manifest.Check(); err != nil {
+		return nil, manifest, err
+	}
+	return p, manifest, nil
+}
+
+// LoadStateDecoder looks up the decoder of the consensus state in the order
+// plugin, only the RBFT plugin has one.
+func LoadStateDecoder(pluginPath string) (rbftstorage.Decoder, error) {
+	p, _, err := openPlugin(pluginPath)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := p.Lookup("DecodeState")
+	if err != nil {
+		return nil, fmt.Errorf("plugin lookup: %s", err)
+	}
+
+	decode, ok := m.(func(kind, key string, value []byte) (interface{}, error))
+	if !ok {
+		return nil, fmt.Errorf("assert DecodeState error")
+	}
+	return decode, nil
 }
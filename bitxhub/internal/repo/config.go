@@ -141,7 +141,16 @@ type Txpool struct {
 }
 
 type Order struct {
+	// Engine selects the order engine registered under the name, the plugin
+	// is loaded if it is empty or the engine registers itself in the plugin.
+	Engine string `toml:"engine" json:"engine"`
 	Plugin string `toml:"plugin" json:"plugin"`
+	// ExitOnRemoved decides what a node does after it is removed from the
+	// consortium: shut down and exit, or keep serving queries as a read-only node.
+	ExitOnRemoved bool `mapstructure:"exit_on_removed" json:"exit_on_removed"`
+	// StopTimeout bounds the graceful stop of an order supporting it, the
+	// blocks not executed by then are abandoned.
+	StopTimeout time.Duration `mapstructure:"stop_timeout" json:"stop_timeout"`
 }
 
 type Executor struct {
@@ -194,7 +203,9 @@ func DefaultConfig() (*Config, error) {
 			BatchTimeout: 500 * time.Millisecond,
 		},
 		Order: Order{
-			Plugin: "plugins/raft.so",
+			Plugin:        "plugins/raft.so",
+			ExitOnRemoved: true,
+			StopTimeout:   10 * time.Second,
 		},
 		Executor: Executor{
 			Type: "serial",
@@ -311,6 +322,23 @@ func WatchNetworkConfig(viper *viper.Viper, feed *event.Feed, config *NetworkCon
 	})
 }
 
+// WatchOrderConfig tells the order when order.toml in orderRoot is written,
+// the order reloads the file itself.
+func WatchOrderConfig(orderRoot string, feed *event.Feed) {
+	path := filepath.Join(orderRoot, "order.toml")
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.WatchConfig()
+	v.OnConfigChange(func(in fsnotify.Event) {
+		fmt.Println("order config file changed: ", in.String())
+
+		feed.Send(&Repo{OrderConfigChanged: true})
+	})
+}
+
 func ReadConfig(v *viper.Viper, path, configType string, config interface{}) error {
 	v.SetConfigFile(path)
 	v.SetConfigType(configType)
@@ -11,11 +11,12 @@ import (
 )
 
 type Repo struct {
-	Config           *Config
-	NetworkConfig    *NetworkConfig
-	Key              *Key
-	Certs            *libp2pcert.Certs
-	ConfigChangeFeed event.Feed
+	Config             *Config
+	NetworkConfig      *NetworkConfig
+	OrderConfigChanged bool // order.toml is written, the order reloads it
+	Key                *Key
+	Certs              *libp2pcert.Certs
+	ConfigChangeFeed   event.Feed
 }
 
 func (r *Repo) SubscribeConfigChange(ch chan *Repo) event.Subscription {
@@ -6,6 +6,7 @@ import (
 	"github.com/meshplus/bitxhub-kit/crypto"
 	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 	"github.com/meshplus/bitxhub/pkg/peermgr"
 	"github.com/sirupsen/logrus"
 )
@@ -16,6 +17,7 @@ type Config struct {
 	RepoRoot         string
 	StoragePath      string
 	PluginPath       string
+	Engine           string
 	PeerMgr          peermgr.PeerManager
 	PrivKey          crypto.PrivateKey
 	Logger           logrus.FieldLogger
@@ -25,6 +27,8 @@ type Config struct {
 	GetChainMetaFunc func() *pb.ChainMeta
 	GetBlockByHeight func(height uint64) (*pb.Block, error)
 	GetAccountNonce  func(address *types.Address) uint64
+	TxStatus         *txstatus.Index
+	ReplayFilter     *ReplayFilter
 }
 
 type Option func(*Config)
@@ -59,6 +63,12 @@ func WithPluginPath(path string) Option {
 	}
 }
 
+func WithEngine(name string) Option {
+	return func(config *Config) {
+		config.Engine = name
+	}
+}
+
 func WithPeerManager(peerMgr peermgr.PeerManager) Option {
 	return func(config *Config) {
 		config.PeerMgr = peerMgr
@@ -113,6 +123,18 @@ func WithGetAccountNonceFunc(f func(address *types.Address) uint64) Option {
 	}
 }
 
+func WithTxStatus(index *txstatus.Index) Option {
+	return func(config *Config) {
+		config.TxStatus = index
+	}
+}
+
+func WithReplayFilter(filter *ReplayFilter) Option {
+	return func(config *Config) {
+		config.ReplayFilter = filter
+	}
+}
+
 func checkConfig(config *Config) error {
 	if config.Logger == nil {
 		return fmt.Errorf("logger is nil")
@@ -11,6 +11,7 @@ import (
 
 	"github.com/coreos/etcd/raft"
 	"github.com/coreos/etcd/raft/raftpb"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/meshplus/bitxhub-kit/log"
 	"github.com/meshplus/bitxhub-kit/storage"
 	"github.com/meshplus/bitxhub-kit/types"
@@ -61,6 +62,11 @@ type Node struct {
 	getChainMetaFunc  func() *pb.ChainMeta // current chain meta
 	ctx               context.Context      // context
 	haltC             chan struct{}        // exit signal
+	consensusFeed     order.EventFeed      // consensus event feed
+}
+
+func init() {
+	order.Register("raft", NewNode)
 }
 
 // NewNode new raft node
@@ -98,6 +104,7 @@ func NewNode(opts ...order.Option) (order.Order, error) {
 		Logger:          config.Logger,
 		StoragePath:     config.StoragePath,
 		GetAccountNonce: config.GetAccountNonce,
+		TxStatus:        config.TxStatus,
 
 		BatchSize:      raftConfig.RAFT.MempoolConfig.BatchSize,
 		PoolSize:       raftConfig.RAFT.MempoolConfig.PoolSize,
@@ -172,14 +179,14 @@ func NewNode(opts ...order.Option) (order.Order, error) {
 	node.syncer = stateSyncer
 
 	node.logger.Infof("Raft localID = %d", node.id)
-	node.logger.Infof("Raft lastExec = %d  ", node.lastExec)
+	node.logger.Infof("Raft lastExec = %d  ", atomic.LoadUint64(&node.lastExec))
 	node.logger.Infof("Raft snapshotCount = %d", node.snapCount)
 	return node, nil
 }
 
 // Start or restart raft node
 func (n *Node) Start() error {
-	n.blockAppliedIndex.Store(n.lastExec, n.loadAppliedIndex())
+	n.blockAppliedIndex.Store(atomic.LoadUint64(&n.lastExec), n.loadAppliedIndex())
 	rc, tickTimeout, err := generateEtcdRaftConfig(n.id, n.repoRoot, n.logger, n.raftStorage.ram)
 	if err != nil {
 		return fmt.Errorf("generate raft config: %w", err)
@@ -210,10 +217,15 @@ func (n *Node) Prepare(tx *pb.Transaction) error {
 		return err
 	}
 	if n.txCache.IsFull() && n.mempool.IsPoolFull() {
-		return errors.New("transaction cache are full, we will drop this transaction")
+		return fmt.Errorf("transaction cache are full, we will drop this transaction: %w", order.ErrBusy)
+	}
+	// the api handlers call Prepare, it never waits for the cache
+	select {
+	case n.txCache.RecvTxC <- tx:
+		return nil
+	default:
+		return fmt.Errorf("transaction cache is full: %w", order.ErrBusy)
 	}
-	n.txCache.RecvTxC <- tx
-	return nil
 }
 
 func (n *Node) Commit() chan *pb.CommitEvent {
@@ -255,6 +267,53 @@ func (n *Node) DelNode(delID uint64) error {
 	return nil
 }
 
+// AddNode sends an add vp request with given vp info.
+func (n *Node) AddNode(vpInfo *pb.VpInfo) error {
+	return fmt.Errorf("add vp node in raft: %w", order.ErrUnsupported)
+}
+
+// SubscribeConsensusEvent registers a subscription of ConsensusEvent.
+func (n *Node) SubscribeConsensusEvent(ch chan<- order.ConsensusEvent) event.Subscription {
+	return n.consensusFeed.Subscribe(ch)
+}
+
+// Status returns the raft state of this node, the term is reported as view.
+// Raft has no checkpoint watermarks, they are left zero.
+func (n *Node) Status() *order.OrderStatus {
+	pending, parked := n.mempool.TxCounts()
+	status := &order.OrderStatus{
+		Engine:        "raft",
+		NodeID:        n.id,
+		Role:          order.RoleFollower,
+		Status:        "in leader election status",
+		LastCommitted: atomic.LoadUint64(&n.lastExec),
+		PendingTxs:    pending,
+		ParkedTxs:     parked,
+	}
+	if n.peerMgr != nil {
+		status.Validators = order.SortedIDs(n.peerMgr.Peers())
+		status.ConnectedPeers = n.peerMgr.CountConnectedPeers()
+	}
+	// the raft node is created by Start
+	if n.node == nil {
+		return status
+	}
+
+	raftStatus := n.node.Status()
+	status.View = raftStatus.Term
+	status.Primary = raftStatus.Lead
+	if raftStatus.Lead != 0 {
+		status.Status = "Normal"
+	}
+	switch raftStatus.RaftState {
+	case raft.StateLeader:
+		status.Role = order.RoleLeader
+	case raft.StateCandidate, raft.StatePreCandidate:
+		status.Role = order.RoleCandidate
+	}
+	return status
+}
+
 // main work loop
 func (n *Node) run() {
 	snap, err := n.raftStorage.ram.Snapshot()
@@ -385,6 +444,15 @@ func (n *Node) run() {
 					}
 					n.logger.Infof("Raft leader changed: %d -> %d", n.leader, newLeader)
 					n.leader = newLeader
+					if dropped := n.consensusFeed.Send(order.ConsensusEvent{
+						Type:    order.ViewChanged,
+						NodeID:  n.id,
+						Primary: newLeader,
+						Height:  atomic.LoadUint64(&n.lastExec),
+						Detail:  fmt.Sprintf("raft leader changed to %d", newLeader),
+					}); dropped != 0 {
+						n.logger.Warningf("Drop leader change event for %d slow subscribers", dropped)
+					}
 				}
 			}
 			// 2: Apply Snapshot (if any) and CommittedEntries to the state machine.
@@ -463,9 +531,9 @@ func (n *Node) publishEntries(ents []raftpb.Entry) bool {
 				continue
 			}
 			// strictly avoid writing the same block
-			if requestBatch.Height != n.lastExec+1 {
+			if lastExec := atomic.LoadUint64(&n.lastExec); requestBatch.Height != lastExec+1 {
 				n.logger.Warningf("Replica %d expects to execute seq=%d, but get seq=%d, ignore it",
-					n.id, n.lastExec+1, requestBatch.Height)
+					n.id, lastExec+1, requestBatch.Height)
 				continue
 			}
 			n.mint(requestBatch)
@@ -657,6 +725,8 @@ func (n *Node) becomeFollower() {
 	n.batchTimerMgr.StopBatchTimer()
 }
 
+// setLastExec is called by the run loop only, the height is stored atomically
+// for Status.
 func (n *Node) setLastExec(height uint64) {
-	n.lastExec = height
+	atomic.StoreUint64(&n.lastExec, height)
 }
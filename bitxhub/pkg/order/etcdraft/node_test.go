@@ -1,6 +1,7 @@
 package etcdraft
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -46,6 +47,7 @@ func TestNode_Start(t *testing.T) {
 	mockPeermgr.EXPECT().Peers().Return(peers).AnyTimes()
 	mockPeermgr.EXPECT().OtherPeers().Return(otherPeers).AnyTimes()
 	mockPeermgr.EXPECT().Broadcast(gomock.Any()).AnyTimes()
+	mockPeermgr.EXPECT().CountConnectedPeers().Return(uint64(0)).AnyTimes()
 
 	order, err := NewNode(
 		order.WithRepoRoot(repoRoot),
@@ -79,6 +81,14 @@ func TestNode_Start(t *testing.T) {
 	require.Equal(t, uint64(2), commitEvent.Block.BlockHeader.Number)
 	require.Equal(t, 1, len(commitEvent.Block.Transactions))
 
+	status := order.Status()
+	require.Equal(t, "raft", status.Engine)
+	require.Equal(t, "leader", status.Role)
+	require.Equal(t, ID, status.Primary)
+	require.Equal(t, "Normal", status.Status)
+	require.True(t, status.View > 0)
+	require.True(t, status.LastCommitted >= 1)
+
 	order.Stop()
 }
 
@@ -237,6 +247,15 @@ func TestGetPendingNonceByAccount(t *testing.T) {
 	err = node.DelNode(uint64(1))
 }
 
+func TestAddNode(t *testing.T) {
+	ast := assert.New(t)
+	defer os.RemoveAll("./testdata/storage")
+	node, err := mockRaftNode(t)
+	ast.Nil(err)
+	err = node.AddNode(&pb.VpInfo{Id: uint64(5)})
+	ast.True(errors.Is(err, order.ErrUnsupported))
+}
+
 func TestRun(t *testing.T) {
 	ast := assert.New(t)
 	defer os.RemoveAll("./testdata/storage")
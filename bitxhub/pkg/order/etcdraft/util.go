@@ -3,6 +3,7 @@ package etcdraft
 import (
 	"encoding/binary"
 	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/coreos/etcd/raft"
@@ -116,7 +117,7 @@ func msgToConsensusPbMsg(data []byte, tyr raftproto.RaftMessage_Type, replicaID
 
 func (n *Node) getSnapshot() ([]byte, error) {
 	cm := pb.ChainMeta{
-		Height: n.lastExec,
+		Height: atomic.LoadUint64(&n.lastExec),
 	}
 	return cm.Marshal()
 }
@@ -149,7 +150,7 @@ func (n *Node) recoverFromSnapshot() {
 			if block == nil {
 				break
 			}
-			if block.Height() == n.lastExec+1 {
+			if block.Height() == atomic.LoadUint64(&n.lastExec)+1 {
 				localList := make([]bool, len(block.Transactions))
 				for i := 0; i < len(block.Transactions); i++ {
 					localList[i] = false
@@ -159,13 +160,13 @@ func (n *Node) recoverFromSnapshot() {
 					LocalList: localList,
 				}
 				n.commitC <- executeEvent
-				n.lastExec = block.Height()
+				atomic.StoreUint64(&n.lastExec, block.Height())
 			}
 		}
 	}
 	syncBlocks()
-	if n.lastExec != targetChainMeta.Height {
-		n.logger.Warnf("The lastExec is %d, but not equal the target block height %d", n.lastExec, targetChainMeta.Height)
+	if lastExec := atomic.LoadUint64(&n.lastExec); lastExec != targetChainMeta.Height {
+		n.logger.Warnf("The lastExec is %d, but not equal the target block height %d", lastExec, targetChainMeta.Height)
 		syncBlocks()
 	}
 	n.appliedIndex = snapshot.Metadata.Index
@@ -0,0 +1,51 @@
+package order
+
+// ConsensusEventType is the kind of state change reported by the consensus engine.
+type ConsensusEventType int32
+
+const (
+	// ViewChanged means the cluster has switched to a new view (or a new raft leader).
+	ViewChanged ConsensusEventType = iota
+	// RecoveryFinished means the node has caught up with the cluster after recovery.
+	RecoveryFinished
+	// EpochChanged means the validator set of the cluster has been updated.
+	EpochChanged
+	// ConfChanged means a configuration change has been applied.
+	ConfChanged
+	// StableCheckpoint means a new stable checkpoint has been reached.
+	StableCheckpoint
+	// NodeRemoved means this node has been removed from the cluster and the
+	// order has stopped, the node can't validate blocks anymore.
+	NodeRemoved
+	// UnknownConsensusEvent is any other event reported by the consensus engine.
+	UnknownConsensusEvent
+)
+
+func (t ConsensusEventType) String() string {
+	switch t {
+	case ViewChanged:
+		return "ViewChanged"
+	case RecoveryFinished:
+		return "RecoveryFinished"
+	case EpochChanged:
+		return "EpochChanged"
+	case ConfChanged:
+		return "ConfChanged"
+	case StableCheckpoint:
+		return "StableCheckpoint"
+	case NodeRemoved:
+		return "NodeRemoved"
+	default:
+		return "Unknown"
+	}
+}
+
+// ConsensusEvent is published by the order when the consensus state changes.
+type ConsensusEvent struct {
+	Type    ConsensusEventType
+	NodeID  uint64 // id of the node which publishes the event
+	Primary uint64 // primary (or leader) id, zero if unknown
+	View    uint64 // view of rbft, zero if unknown
+	Height  uint64 // latest block height when the event is published
+	Detail  string // raw description given by the consensus engine
+}
@@ -0,0 +1,103 @@
+package order
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// eventQueueSize is the number of events a subscriber may fall behind before
+// the events are dropped for it.
+const eventQueueSize = 64
+
+// EventFeed publishes consensus events without blocking the publisher, which
+// is the consensus goroutine. Every subscriber has a queue of its own, the
+// events a slow subscriber has no room for are dropped and counted. The zero
+// value is ready to use.
+type EventFeed struct {
+	lock    sync.Mutex
+	subs    map[*feedSub]struct{}
+	dropped uint64
+}
+
+// Subscribe delivers the events to ch in order until the subscription is
+// cancelled.
+func (f *EventFeed) Subscribe(ch chan<- ConsensusEvent) event.Subscription {
+	sub := &feedSub{
+		feed:  f,
+		ch:    ch,
+		queue: make(chan ConsensusEvent, eventQueueSize),
+		quit:  make(chan struct{}),
+		err:   make(chan error),
+	}
+	f.lock.Lock()
+	if f.subs == nil {
+		f.subs = make(map[*feedSub]struct{})
+	}
+	f.subs[sub] = struct{}{}
+	f.lock.Unlock()
+	go sub.forward()
+	return sub
+}
+
+// Send queues the event for every subscriber and returns how many of them
+// dropped it.
+func (f *EventFeed) Send(ev ConsensusEvent) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	dropped := 0
+	for sub := range f.subs {
+		select {
+		case sub.queue <- ev:
+		default:
+			dropped++
+		}
+	}
+	f.dropped += uint64(dropped)
+	return dropped
+}
+
+// Dropped returns the number of events dropped for the slow subscribers.
+func (f *EventFeed) Dropped() uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.dropped
+}
+
+type feedSub struct {
+	feed  *EventFeed
+	ch    chan<- ConsensusEvent
+	queue chan ConsensusEvent
+	quit  chan struct{}
+	err   chan error
+	once  sync.Once
+}
+
+func (s *feedSub) forward() {
+	for {
+		select {
+		case ev := <-s.queue:
+			select {
+			case s.ch <- ev:
+			case <-s.quit:
+				return
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *feedSub) Unsubscribe() {
+	s.once.Do(func() {
+		s.feed.lock.Lock()
+		delete(s.feed.subs, s)
+		s.feed.lock.Unlock()
+		close(s.quit)
+		close(s.err)
+	})
+}
+
+func (s *feedSub) Err() <-chan error {
+	return s.err
+}
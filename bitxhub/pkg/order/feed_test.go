@@ -0,0 +1,61 @@
+package order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFeed(t *testing.T) {
+	var feed EventFeed
+	fast := make(chan ConsensusEvent, eventQueueSize)
+	slow := make(chan ConsensusEvent)
+	fastSub := feed.Subscribe(fast)
+	slowSub := feed.Subscribe(slow)
+
+	send := func(from, to int) int {
+		dropped := 0
+		done := make(chan struct{})
+		go func() {
+			for i := from; i < to; i++ {
+				dropped += feed.Send(ConsensusEvent{Type: ViewChanged, Height: uint64(i)})
+			}
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("send blocks on a slow subscriber")
+		}
+		return dropped
+	}
+	receive := func(from, to int) {
+		for i := from; i < to; i++ {
+			select {
+			case ev := <-fast:
+				require.Equal(t, uint64(i), ev.Height)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("event %d is not delivered", i)
+			}
+		}
+	}
+
+	// both queues have room for the first events
+	require.Equal(t, 0, send(0, eventQueueSize))
+	receive(0, eventQueueSize)
+
+	// the slow subscriber reads nothing, it keeps the oldest events, one of
+	// them may be in flight already
+	dropped := send(eventQueueSize, 2*eventQueueSize)
+	require.Equal(t, uint64(dropped), feed.Dropped())
+	require.True(t, dropped == eventQueueSize-1 || dropped == eventQueueSize, "dropped %d", dropped)
+	receive(eventQueueSize, 2*eventQueueSize)
+	require.Equal(t, uint64(0), (<-slow).Height)
+
+	fastSub.Unsubscribe()
+	slowSub.Unsubscribe()
+	_, ok := <-fastSub.Err()
+	require.False(t, ok)
+	require.Equal(t, 0, feed.Send(ConsensusEvent{Type: ViewChanged}))
+}
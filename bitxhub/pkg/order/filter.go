@@ -44,13 +44,24 @@ func NewReqLookUp(storage storage.Storage, logger logrus.FieldLogger) (*ReqLookU
 }
 
 func (r *ReqLookUp) Add(key []byte) {
+	r.Lock()
+	defer r.Unlock()
 	r.filter.Add(key)
 }
 
 func (r *ReqLookUp) LookUp(key []byte) bool {
+	r.Lock()
+	defer r.Unlock()
 	return r.filter.TestAndAdd(key)
 }
 
+// Test is LookUp without adding the key.
+func (r *ReqLookUp) Test(key []byte) bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.filter.Test(key)
+}
+
 func (r *ReqLookUp) Build() error {
 	r.Lock()
 	defer r.Unlock()
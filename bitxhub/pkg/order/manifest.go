@@ -0,0 +1,104 @@
+package order
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// InterfaceVersion is the version of the Order interface and the Config the
+// order plugins are built against, it is bumped on every change a plugin
+// built before can't follow.
+const InterfaceVersion = 1
+
+// ManifestSymbol is the name of the Manifest variable an order plugin
+// exports.
+const ManifestSymbol = "Manifest"
+
+// Manifest describes an order plugin, bitxhub checks it before calling the
+// NewNode of the plugin. A plugin declares its name, version and the
+// InterfaceVersion it is built against, Deps are read from the build info of
+// the plugin file by the loader, so they always tell how it was built. A
+// dependency is given by its version and its go.sum hash, "v1.2.0 h1:...",
+// or by "=> dir" if it is replaced by a directory, the hashes are compared
+// with the ones of bitxhub.
+type Manifest struct {
+	Name             string            `json:"name"`
+	Version          string            `json:"version"`
+	InterfaceVersion int               `json:"interface_version"`
+	Deps             map[string]string `json:"deps,omitempty"` // module path -> version and go.sum hash
+}
+
+func (m *Manifest) String() string {
+	var b strings.Builder
+	if m.Name == "" {
+		// the manifest read from the build info of a plugin has only the deps
+		b.WriteString("build info only")
+	} else {
+		fmt.Fprintf(&b, "%s %s, order interface v%d", m.Name, m.Version, m.InterfaceVersion)
+	}
+	paths := make([]string, 0, len(m.Deps))
+	for path := range m.Deps {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&b, "\n  %s %s", path, m.Deps[path])
+	}
+	return b.String()
+}
+
+// Check returns an error telling what to rebuild if the plugin doesn't
+// implement InterfaceVersion.
+func (m *Manifest) Check() error {
+	if m.InterfaceVersion != InterfaceVersion {
+		return fmt.Errorf("order plugin %s %s implements order interface v%d but v%d is required, rebuild the plugin against this bitxhub",
+			m.Name, m.Version, m.InterfaceVersion, InterfaceVersion)
+	}
+	return nil
+}
+
+// ModuleDeps returns the version and the go.sum hash of the modules in the
+// build info, a module replaced by a directory has no hash and is given by
+// the directory.
+func ModuleDeps(info *debug.BuildInfo) map[string]string {
+	if info == nil {
+		return nil
+	}
+	deps := make(map[string]string, len(info.Deps))
+	for _, dep := range info.Deps {
+		mod := dep
+		if dep.Replace != nil {
+			mod = dep.Replace
+		}
+		if mod.Version == "" {
+			deps[dep.Path] = "=> " + mod.Path
+			continue
+		}
+		deps[dep.Path] = strings.TrimSpace(mod.Version + " " + mod.Sum)
+	}
+	return deps
+}
+
+// CheckDeps returns an error listing the modules the plugin and bitxhub are
+// built with different versions of, Go refuses to open such a plugin. The
+// modules replaced by directories are left to the check of Go.
+func CheckDeps(plugin, host map[string]string) error {
+	var mismatches []string
+	for path, version := range plugin {
+		hostVersion, ok := host[path]
+		if !ok || hostVersion == version {
+			continue
+		}
+		if strings.HasPrefix(version, "=> ") || strings.HasPrefix(hostVersion, "=> ") {
+			continue
+		}
+		mismatches = append(mismatches, fmt.Sprintf("%s %s, bitxhub has %s", path, version, hostVersion))
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+	sort.Strings(mismatches)
+	return fmt.Errorf("built with other versions of the modules bitxhub is built with: %s", strings.Join(mismatches, "; "))
+}
@@ -0,0 +1,54 @@
+package order
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestCheck(t *testing.T) {
+	m := &Manifest{Name: "rbft", Version: "1.0.0", InterfaceVersion: InterfaceVersion}
+	require.Nil(t, m.Check())
+
+	m.InterfaceVersion = InterfaceVersion + 1
+	err := m.Check()
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "rebuild the plugin")
+}
+
+func TestModuleDeps(t *testing.T) {
+	info := &debug.BuildInfo{
+		Deps: []*debug.Module{
+			{Path: "github.com/meshplus/bitxhub-kit", Version: "v1.2.0", Sum: "h1:kit="},
+			{Path: "github.com/meshplus/bitxhub", Version: "v1.0.0-rc2", Replace: &debug.Module{Path: "../bitxhub"}},
+			{Path: "github.com/meshplus/bitxhub-model", Version: "v1.1.0", Replace: &debug.Module{Path: "github.com/fork/bitxhub-model", Version: "v1.1.1", Sum: "h1:fork="}},
+		},
+	}
+	require.Equal(t, map[string]string{
+		"github.com/meshplus/bitxhub-kit":   "v1.2.0 h1:kit=",
+		"github.com/meshplus/bitxhub":       "=> ../bitxhub",
+		"github.com/meshplus/bitxhub-model": "v1.1.1 h1:fork=",
+	}, ModuleDeps(info))
+	require.Nil(t, ModuleDeps(nil))
+}
+
+func TestCheckDeps(t *testing.T) {
+	host := map[string]string{
+		"github.com/meshplus/bitxhub-kit":   "v1.2.0 h1:kit=",
+		"github.com/meshplus/bitxhub-model": "v1.1.1 h1:model=",
+		"github.com/meshplus/bitxhub":       "=> ./bitxhub",
+	}
+	plugin := map[string]string{
+		"github.com/meshplus/bitxhub-kit": "v1.2.0 h1:kit=",
+		"github.com/meshplus/bitxhub":     "=> ../bitxhub",
+		"github.com/ultramesh/rbft":       "v0.1.0 h1:rbft=",
+	}
+	require.Nil(t, CheckDeps(plugin, host))
+	require.Nil(t, CheckDeps(plugin, nil))
+
+	plugin["github.com/meshplus/bitxhub-model"] = "v1.1.0 h1:old="
+	err := CheckDeps(plugin, host)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "github.com/meshplus/bitxhub-model v1.1.0 h1:old=, bitxhub has v1.1.1 h1:model=")
+}
@@ -1,6 +1,7 @@
 package mempool
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/meshplus/bitxhub-model/pb"
@@ -37,6 +38,10 @@ type External interface {
 
 	// IsPoolFull check if memPool has exceeded the limited txSize.
 	IsPoolFull() bool
+
+	// TxCounts returns the number of ready transactions waiting for a batch
+	// and the number of transactions parked for the missing nonces.
+	TxCounts() (pending uint64, parked uint64)
 }
 
 // NewMempool return the mempool instance.
@@ -47,6 +52,7 @@ func NewMempool(config *Config) (MemPool, error) {
 // GenerateRequestBatch generates a transaction batch and post it
 // to outside if there are transactions in txPool.
 func (mpi *mempoolImpl) GenerateBlock() *raftproto.RequestBatch {
+	defer mpi.updateTxCounts()
 	if mpi.txStore.priorityNonBatchSize == 0 {
 		mpi.logger.Debug("Mempool is empty")
 		return nil
@@ -66,6 +72,7 @@ func (mpi *mempoolImpl) HasPendingRequest() bool {
 func (mpi *mempoolImpl) CommitTransactions(state *ChainState) {
 	gcStartTime := time.Now()
 	mpi.processCommitTransactions(state)
+	mpi.updateTxCounts()
 	duration := time.Now().Sub(gcStartTime).Nanoseconds()
 	mpi.logger.Debugf("GC duration %v", duration)
 }
@@ -78,6 +85,17 @@ func (mpi *mempoolImpl) IsPoolFull() bool {
 	return uint64(len(mpi.txStore.txHashMap)) >= mpi.poolSize
 }
 
+func (mpi *mempoolImpl) TxCounts() (uint64, uint64) {
+	return atomic.LoadUint64(&mpi.pendingTxs), atomic.LoadUint64(&mpi.parkedTxs)
+}
+
+// updateTxCounts publishes the sizes of the transaction store for TxCounts,
+// it is called after the store is changed.
+func (mpi *mempoolImpl) updateTxCounts() {
+	atomic.StoreUint64(&mpi.pendingTxs, mpi.txStore.priorityNonBatchSize)
+	atomic.StoreUint64(&mpi.parkedTxs, uint64(mpi.txStore.parkingLotIndex.size()))
+}
+
 func (mpi *mempoolImpl) SetBatchSeqNo(batchSeq uint64) {
 	mpi.batchSeqNo = batchSeq
 }
@@ -1,6 +1,7 @@
 package mempool
 
 import (
+	"fmt"
 	"math"
 	"os"
 	"sync"
@@ -12,6 +13,7 @@ import (
 	"github.com/meshplus/bitxhub-kit/storage/leveldb"
 	"github.com/meshplus/bitxhub-model/pb"
 	raftproto "github.com/meshplus/bitxhub/pkg/order/etcdraft/proto"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -24,6 +26,11 @@ type mempoolImpl struct {
 	poolSize    uint64
 	logger      logrus.FieldLogger
 	txStore     *transactionStore // store all transactions info
+	txStatus    *txstatus.Index
+
+	// sizes of txStore published for TxCounts, accessed atomically
+	pendingTxs uint64
+	parkedTxs  uint64
 }
 
 func newMempoolImpl(config *Config) (*mempoolImpl, error) {
@@ -32,6 +39,7 @@ func newMempoolImpl(config *Config) (*mempoolImpl, error) {
 		batchSeqNo:  config.ChainHeight,
 		logger:      config.Logger,
 		txSliceSize: config.TxSliceSize,
+		txStatus:    config.TxStatus,
 	}
 	mpi.txStore = newTransactionStore(config.GetAccountNonce, config.Logger)
 	if config.BatchSize == 0 {
@@ -57,6 +65,7 @@ func newMempoolImpl(config *Config) (*mempoolImpl, error) {
 }
 
 func (mpi *mempoolImpl) ProcessTransactions(txs []*pb.Transaction, isLeader, isLocal bool) *raftproto.RequestBatch {
+	defer mpi.updateTxCounts()
 	validTxs := make(map[string][]*pb.Transaction)
 	for _, tx := range txs {
 		// check the sequence number of tx
@@ -64,6 +73,7 @@ func (mpi *mempoolImpl) ProcessTransactions(txs []*pb.Transaction, isLeader, isL
 		currentSeqNo := mpi.txStore.nonceCache.getPendingNonce(txAccount)
 		if tx.Nonce < currentSeqNo {
 			mpi.logger.Warningf("Account %s, current sequence number is %d, required %d", txAccount, tx.Nonce, currentSeqNo+1)
+			mpi.txStatus.Reject(fmt.Sprintf("stale nonce %d, the pending nonce is %d", tx.Nonce, currentSeqNo), tx.TransactionHash.String())
 			continue
 		}
 		// check the existence of hash of this tx
@@ -81,6 +91,11 @@ func (mpi *mempoolImpl) ProcessTransactions(txs []*pb.Transaction, isLeader, isL
 
 	// Process all the new transaction and merge any errors into the original slice
 	dirtyAccounts := mpi.txStore.insertTxs(validTxs, isLocal)
+	for _, list := range validTxs {
+		for _, tx := range list {
+			mpi.txStatus.Set(txstatus.Pooled, 0, tx.TransactionHash.String())
+		}
+	}
 
 	// send tx to mempool store
 	mpi.processDirtyAccount(dirtyAccounts)
@@ -191,12 +206,15 @@ func (mpi *mempoolImpl) generateBlock() (*raftproto.RequestBatch, error) {
 
 	// convert transaction pointers to real values
 	txList := make([]*pb.Transaction, len(result))
+	txHashes := make([]string, len(result))
 	for i, v := range result {
 		rawTransaction := mpi.txStore.getTxByOrderKey(v.account, v.nonce)
 		txList[i] = rawTransaction
+		txHashes[i] = rawTransaction.TransactionHash.String()
 	}
 	mpi.batchSeqNo++
 	batchSeqNo := mpi.batchSeqNo
+	mpi.txStatus.Set(txstatus.Batched, batchSeqNo, txHashes...)
 	batch := &raftproto.RequestBatch{
 		TxList: txList,
 		Height: batchSeqNo,
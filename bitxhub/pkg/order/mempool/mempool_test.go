@@ -9,6 +9,7 @@ import (
 
 	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -157,6 +158,9 @@ func TestProcessTransactions(t *testing.T) {
 	ast.Equal(uint64(3), mpi.txStore.nonceCache.getPendingNonce(account1.String()))
 	ast.Equal(uint64(0), mpi.txStore.nonceCache.getCommitNonce(account2.String()))
 	ast.Equal(uint64(3), mpi.txStore.nonceCache.getPendingNonce(account2.String()))
+	pending, parked := mpi.TxCounts()
+	ast.Equal(uint64(4), pending)
+	ast.Equal(uint64(1), parked)
 
 	mpi.batchSize = 4
 	tx6 := constructTx(uint64(3), &privKey1)
@@ -174,6 +178,22 @@ func TestProcessTransactions(t *testing.T) {
 	ast.Equal(4, mpi.txStore.allTxs[account2.String()].index.size())
 	ast.Equal(uint64(4), mpi.txStore.nonceCache.getPendingNonce(account1.String()))
 	ast.Equal(uint64(3), mpi.txStore.nonceCache.getPendingNonce(account2.String()))
+	pending, parked = mpi.TxCounts()
+	ast.Equal(uint64(1), pending)
+	ast.Equal(uint64(2), parked)
+
+	status, ok := mpi.txStatus.Get(tx1.TransactionHash.String())
+	ast.True(ok)
+	ast.Equal(txstatus.Batched, status.State)
+	ast.Equal(uint64(2), status.Height)
+	status, _ = mpi.txStatus.Get(tx5.TransactionHash.String())
+	ast.Equal(txstatus.Pooled, status.State)
+
+	staleTx := constructTx(uint64(1), &privKey1)
+	mpi.ProcessTransactions([]*pb.Transaction{staleTx}, true, true)
+	status, _ = mpi.txStatus.Get(staleTx.TransactionHash.String())
+	ast.Equal(txstatus.Rejected, status.State)
+	ast.Contains(status.Reason, "stale nonce 1")
 }
 
 func TestForward(t *testing.T) {
@@ -9,6 +9,7 @@ import (
 	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
 	raftproto "github.com/meshplus/bitxhub/pkg/order/etcdraft/proto"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 )
 
 var (
@@ -36,6 +37,7 @@ func mockMempoolImpl(path string) (*mempoolImpl, chan *raftproto.Ready) {
 		Logger:          log.NewWithModule("consensus"),
 		StoragePath:     path,
 		GetAccountNonce: mockGetAccountNonce,
+		TxStatus:        txstatus.New(0),
 	}
 	proposalC := make(chan *raftproto.Ready)
 	mempool, _ := newMempoolImpl(config)
@@ -5,6 +5,7 @@ import (
 
 	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -33,6 +34,7 @@ type Config struct {
 	Logger             logrus.FieldLogger
 	StoragePath        string // db for persist mem pool meta data
 	GetAccountNonce    GetAccountNonceFunc
+	TxStatus           *txstatus.Index // optional, tracks the transactions pooled and batched
 }
 
 type txItem struct {
@@ -1,10 +1,25 @@
 package order
 
 import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
 )
 
+var (
+	// ErrUnsupported is returned by orders for the operations they don't support.
+	ErrUnsupported = errors.New("operation is unsupported by the order")
+
+	// ErrBusy is returned by Prepare when the order can't accept more
+	// transactions for now, the transaction can be sent again later.
+	ErrBusy = errors.New("order is busy")
+
+	// ErrReplayed is returned for a transaction which has been executed.
+	ErrReplayed = errors.New("transaction has been executed")
+)
+
 //go:generate mockgen -destination mock_order/mock_order.go -package mock_order -source order.go
 type Order interface {
 	// Start the order service.
@@ -13,7 +28,9 @@ type Order interface {
 	// Stop means frees the resources which were allocated for this service.
 	Stop()
 
-	// Prepare means send transaction to the consensus engine
+	// Prepare means send transaction to the consensus engine, it is called by
+	// the api handlers and never blocks, an error wrapping ErrBusy is returned
+	// if the transaction can't be accepted for now
 	Prepare(tx *pb.Transaction) error
 
 	// Commit recv blocks form Order and commit it by order
@@ -36,4 +53,22 @@ type Order interface {
 
 	// DelNode sends a delete vp request by given id.
 	DelNode(delID uint64) error
+
+	// AddNode sends an add vp request with given vp info.
+	AddNode(vpInfo *pb.VpInfo) error
+
+	// SubscribeConsensusEvent registers a subscription of ConsensusEvent.
+	SubscribeConsensusEvent(ch chan<- ConsensusEvent) event.Subscription
+
+	// Status returns a snapshot of the consensus state for introspection.
+	Status() *OrderStatus
+}
+
+// ReConfigurable is an optional capability of the order, it is implemented by
+// the orders which can apply a part of order.toml without restarting. The
+// host calls ReConfig once every time order.toml is written.
+type ReConfigurable interface {
+	// ReConfig reloads order.toml and applies the parameters which are safe to
+	// change live. Unsafe changes are refused with an error explaining why.
+	ReConfig() error
 }
@@ -0,0 +1,206 @@
+// Package rbftaudit records the consensus messages of the RBFT order plugin to
+// rotating files and reads them back, so a recorded stream can be replayed
+// into a local node.
+//
+// A file holds records one after another, each is
+//
+//	length uint32 | crc32 uint32 | direction uint8 | unix nano int64 | peer uint64 | message
+//
+// in big endian, the length and the checksum cover everything after them. A
+// record torn by a crash ends its file.
+package rbftaudit
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Direction tells if a message is received or sent.
+type Direction uint8
+
+const (
+	Inbound Direction = iota + 1
+	Outbound
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Inbound:
+		return "inbound"
+	case Outbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
+
+// fileSuffix is the extension of the audit files, they are named by their
+// sequence numbers so the names sort in the order they are written.
+const fileSuffix = ".audit"
+
+const (
+	headerSize = 8
+	bodyHead   = 1 + 8 + 8
+)
+
+// Record is a consensus message, Peer is the sender of an inbound message and
+// the receiver of an outbound one, 0 for a broadcast.
+type Record struct {
+	Direction Direction
+	Time      time.Time
+	Peer      uint64
+	Data      []byte
+}
+
+// Recorder appends records to the files in a directory. A file is closed once
+// it reaches the max size and the oldest files are removed to keep at most
+// max files. A nil or closed Recorder records nothing.
+type Recorder struct {
+	lock        sync.Mutex
+	dir         string
+	maxFileSize int64
+	maxFiles    int
+	seq         uint64
+	file        *os.File
+	size        int64
+}
+
+// NewRecorder starts a new file in dir after the existing ones.
+func NewRecorder(dir string, maxFileSize int64, maxFiles int) (*Recorder, error) {
+	if maxFileSize <= 0 || maxFiles <= 0 {
+		return nil, fmt.Errorf("max file size and max files must be positive")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	seqs, err := fileSeqs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		dir:         dir,
+		maxFileSize: maxFileSize,
+		maxFiles:    maxFiles,
+	}
+	if len(seqs) != 0 {
+		r.seq = seqs[len(seqs)-1]
+	}
+	if err := r.rotate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record appends a message received from or sent to peer.
+func (r *Recorder) Record(direction Direction, peer uint64, data []byte) error {
+	if r == nil {
+		return nil
+	}
+
+	buf := make([]byte, headerSize+bodyHead+len(data))
+	body := buf[headerSize:]
+	body[0] = byte(direction)
+	binary.BigEndian.PutUint64(body[1:], uint64(time.Now().UnixNano()))
+	binary.BigEndian.PutUint64(body[9:], peer)
+	copy(body[bodyHead:], data)
+	binary.BigEndian.PutUint32(buf, uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(body))
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	if r.size > 0 && r.size+int64(len(buf)) > r.maxFileSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.file.Write(buf)
+	r.size += int64(n)
+	return err
+}
+
+// rotate closes the current file, starts the next one and removes the oldest
+// ones, it must be called with the lock held.
+func (r *Recorder) rotate() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+	}
+	r.seq++
+	file, err := os.OpenFile(filepath.Join(r.dir, fileName(r.seq)), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		r.file = nil
+		return err
+	}
+	r.file = file
+	r.size = 0
+
+	seqs, err := fileSeqs(r.dir)
+	if err != nil {
+		return err
+	}
+	for len(seqs) > r.maxFiles {
+		if err := os.Remove(filepath.Join(r.dir, fileName(seqs[0]))); err != nil {
+			return err
+		}
+		seqs = seqs[1:]
+	}
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func fileName(seq uint64) string {
+	return fmt.Sprintf("%020d%s", seq, fileSuffix)
+}
+
+// fileSeqs returns the sequence numbers of the audit files in dir in order.
+func fileSeqs(dir string) ([]uint64, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seqs := make([]uint64, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, fileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool {
+		return seqs[i] < seqs[j]
+	})
+	return seqs, nil
+}
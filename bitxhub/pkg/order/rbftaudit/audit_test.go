@@ -0,0 +1,112 @@
+package rbftaudit
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, dir string) ([]*Record, int) {
+	records := make([]*Record, 0)
+	torn := 0
+	err := ReadDir(dir, func(record *Record) error {
+		records = append(records, record)
+		return nil
+	}, func(path string, err error) {
+		require.True(t, errors.Is(err, ErrTorn))
+		torn++
+	})
+	require.Nil(t, err)
+	return records, torn
+}
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(dir, 1024, 100)
+	require.Nil(t, err)
+	require.Nil(t, r.Record(Inbound, 2, []byte("pre-prepare")))
+	require.Nil(t, r.Record(Outbound, 0, []byte("prepare")))
+	require.Nil(t, r.Record(Outbound, 3, nil))
+	require.Nil(t, r.Close())
+	require.Nil(t, r.Record(Inbound, 2, []byte("commit")))
+
+	records, torn := readAll(t, dir)
+	require.Equal(t, 0, torn)
+	require.Equal(t, 3, len(records))
+	require.Equal(t, Inbound, records[0].Direction)
+	require.Equal(t, uint64(2), records[0].Peer)
+	require.Equal(t, []byte("pre-prepare"), records[0].Data)
+	require.Equal(t, Outbound, records[1].Direction)
+	require.Equal(t, uint64(0), records[1].Peer)
+	require.Equal(t, 0, len(records[2].Data))
+	require.False(t, records[1].Time.Before(records[0].Time))
+
+	// a restarted recorder appends a new file
+	r, err = NewRecorder(dir, 1024, 100)
+	require.Nil(t, err)
+	require.Nil(t, r.Record(Inbound, 4, []byte("commit")))
+	require.Nil(t, r.Close())
+	records, _ = readAll(t, dir)
+	require.Equal(t, 4, len(records))
+	require.Equal(t, []byte("commit"), records[3].Data)
+
+	var nilRecorder *Recorder
+	require.Nil(t, nilRecorder.Record(Inbound, 1, []byte("msg")))
+	require.Nil(t, nilRecorder.Close())
+}
+
+func TestRecorderRotate(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(dir, 100, 3)
+	require.Nil(t, err)
+	defer r.Close()
+	for i := 0; i < 20; i++ {
+		// every record is 8 + 17 + 25 bytes, two of them fill a file
+		require.Nil(t, r.Record(Inbound, 1, []byte(fmt.Sprintf("message %017d", i))))
+	}
+
+	seqs, err := fileSeqs(dir)
+	require.Nil(t, err)
+	require.Equal(t, []uint64{8, 9, 10}, seqs)
+	records, _ := readAll(t, dir)
+	require.Equal(t, 6, len(records))
+	require.Equal(t, []byte(fmt.Sprintf("message %017d", 14)), records[0].Data)
+}
+
+func TestReadTorn(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(dir, 1024, 10)
+	require.Nil(t, err)
+	require.Nil(t, r.Record(Inbound, 1, []byte("first")))
+	require.Nil(t, r.Record(Inbound, 1, []byte("second")))
+	require.Nil(t, r.Close())
+
+	path := filepath.Join(dir, fileName(1))
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	require.Nil(t, ioutil.WriteFile(path, data[:len(data)-3], 0644))
+
+	r, err = NewRecorder(dir, 1024, 10)
+	require.Nil(t, err)
+	require.Nil(t, r.Record(Inbound, 1, []byte("third")))
+	require.Nil(t, r.Close())
+
+	records, torn := readAll(t, dir)
+	require.Equal(t, 1, torn)
+	require.Equal(t, 2, len(records))
+	require.Equal(t, []byte("first"), records[0].Data)
+	require.Equal(t, []byte("third"), records[1].Data)
+
+	// a flipped bit is caught by the checksum
+	data[len(data)-1] ^= 0x01
+	require.Nil(t, ioutil.WriteFile(path, data, 0644))
+	err = ReadFile(path, func(record *Record) error { return nil })
+	require.True(t, errors.Is(err, ErrTorn))
+
+	_, err = NewRecorder(filepath.Join(dir, fileName(1)), 1024, 10)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,82 @@
+package rbftaudit
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrTorn is returned by ReadFile if the file ends with an incomplete or
+// corrupted record, the records before it are read.
+var ErrTorn = errors.New("audit record is torn")
+
+// ReadFile calls fn with the records of the file in order until fn returns an
+// error.
+func ReadFile(path string, fn func(record *Record) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := make([]byte, headerSize)
+	for {
+		if _, err := io.ReadFull(file, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("%w: %s", ErrTorn, err)
+		}
+		length := binary.BigEndian.Uint32(header)
+		if length < bodyHead {
+			return fmt.Errorf("%w: length %d is too short", ErrTorn, length)
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(file, body); err != nil {
+			return fmt.Errorf("%w: %s", ErrTorn, err)
+		}
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:]) {
+			return fmt.Errorf("%w: checksum mismatch", ErrTorn)
+		}
+
+		record := &Record{
+			Direction: Direction(body[0]),
+			Time:      time.Unix(0, int64(binary.BigEndian.Uint64(body[1:]))),
+			Peer:      binary.BigEndian.Uint64(body[9:]),
+			Data:      body[bodyHead:],
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadDir calls fn with the records of all audit files in dir in the order
+// they are written. A torn file is read up to the torn record, torn is called
+// with the error and the next file is read.
+func ReadDir(dir string, fn func(record *Record) error, torn func(path string, err error)) error {
+	seqs, err := fileSeqs(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, seq := range seqs {
+		path := filepath.Join(dir, fileName(seq))
+		err := ReadFile(path, fn)
+		if errors.Is(err, ErrTorn) {
+			if torn != nil {
+				torn(path, err)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+// Package rbftconfig loads and validates the rbft section of order.toml. It is
+// shared by the RBFT order plugin and the bitxhub command, so a config file can
+// be checked offline with exactly the rules the plugin applies on startup.
+package rbftconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Sign algorithms of consensus messages.
+const (
+	Secp256k1 = "secp256k1"
+	ECDSAP256 = "ecdsa_p256"
+	ECDSAP384 = "ecdsa_p384"
+	ECDSAP521 = "ecdsa_p521"
+	Ed25519   = "ed25519"
+	SM2       = "sm2"
+)
+
+// Storage backends of the consensus state.
+const (
+	StorageLevelDB = "leveldb"
+	StoragePebble  = "pebble"
+	StorageMemory  = "memory"
+)
+
+type Config struct {
+	Rbft RBFT `mapstructure:"rbft"`
+}
+
+type RBFT struct {
+	SetSize       int           `mapstructure:"set_size"`
+	BatchSize     uint64        `mapstructure:"batch_size"`
+	PoolSize      uint64        `mapstructure:"pool_size"`
+	TxCacheSize   int           `mapstructure:"tx_cache_size"`
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	ToleranceTime time.Duration `mapstructure:"tolerance_time"`
+	BatchMemLimit bool          `mapstructure:"batch_mem_limit"`
+	BatchMaxMem   uint64        `mapstructure:"batch_max_mem"`
+	VCPeriod      uint64        `mapstructure:"vc_period"`
+	Timeout       Timeout       `mapstructure:"timeout"`
+	SyncerConfig  SyncerConfig  `mapstructure:"syncer"`
+	CryptoConfig  CryptoConfig  `mapstructure:"crypto"`
+	StorageConfig StorageConfig `mapstructure:"storage"`
+	AuditConfig   AuditConfig   `mapstructure:"audit"`
+}
+
+type Timeout struct {
+	SyncState        time.Duration `mapstructure:"sync_state"`
+	SyncInterval     time.Duration `mapstructure:"sync_interval"`
+	Recovery         time.Duration `mapstructure:"recovery"`
+	FirstRequest     time.Duration `mapstructure:"first_request"`
+	Batch            time.Duration `mapstructure:"batch"`
+	Request          time.Duration `mapstructure:"request"`
+	NullRequest      time.Duration `mapstructure:"null_request"`
+	ViewChange       time.Duration `mapstructure:"viewchange"`
+	ResendViewChange time.Duration `mapstructure:"resend_viewchange"`
+	CleanViewChange  time.Duration `mapstructure:"clean_viewchange"`
+	Update           time.Duration `mapstructure:"update"`
+	Set              time.Duration `mapstructure:"set"`
+}
+
+type SyncerConfig struct {
+	SyncBlocks uint64 `mapstructure:"sync_blocks"`
+}
+
+type StorageConfig struct {
+	Backend      string `mapstructure:"backend"`
+	SyncCritical bool   `mapstructure:"sync_critical"`
+}
+
+type AuditConfig struct {
+	Enable        bool   `mapstructure:"enable"`
+	Dir           string `mapstructure:"dir"`
+	MaxFileSizeMB int64  `mapstructure:"max_file_size_mb"`
+	MaxFiles      int    `mapstructure:"max_files"`
+}
+
+type CryptoConfig struct {
+	Algorithm       string            `mapstructure:"algorithm"`
+	KeyFile         string            `mapstructure:"key_file"`
+	PublicKeys      map[string]string `mapstructure:"public_keys"`
+	VerifyCacheSize int               `mapstructure:"verify_cache_size"`
+	VerifyWorkers   int               `mapstructure:"verify_workers"`
+}
+
+// DefaultConfig returns the values used for the keys missing in order.toml.
+func DefaultConfig() *Config {
+	return &Config{
+		Rbft: RBFT{
+			SetSize:       1000,
+			BatchSize:     500,
+			PoolSize:      50000,
+			TxCacheSize:   10000,
+			CheckInterval: 100 * time.Second,
+			ToleranceTime: 5 * time.Minute,
+			BatchMemLimit: false,
+			BatchMaxMem:   10000,
+			VCPeriod:      0,
+			Timeout: Timeout{
+				SyncState:        3 * time.Second,
+				SyncInterval:     40 * time.Second,
+				Recovery:         10 * time.Second,
+				FirstRequest:     30 * time.Second,
+				Batch:            200 * time.Millisecond,
+				Request:          6 * time.Second,
+				NullRequest:      9 * time.Second,
+				ViewChange:       1 * time.Second,
+				ResendViewChange: 8 * time.Second,
+				CleanViewChange:  60 * time.Second,
+				Update:           4 * time.Second,
+				Set:              100 * time.Millisecond,
+			},
+			SyncerConfig: SyncerConfig{
+				SyncBlocks: 1,
+			},
+			CryptoConfig: CryptoConfig{
+				Algorithm:       Secp256k1,
+				VerifyCacheSize: 10000,
+			},
+			StorageConfig: StorageConfig{
+				Backend: StorageLevelDB,
+			},
+			AuditConfig: AuditConfig{
+				Enable:        false,
+				Dir:           "audit",
+				MaxFileSizeMB: 64,
+				MaxFiles:      16,
+			},
+		},
+	}
+}
+
+// Load reads the rbft section of order.toml in repoRoot on top of the
+// defaults and validates it. Unknown keys in the rbft section are rejected.
+func Load(repoRoot string) (*Config, error) {
+	return LoadFile(filepath.Join(repoRoot, "order.toml"))
+}
+
+// LoadFile is Load with the path of the config file.
+func LoadFile(path string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	config := DefaultConfig()
+	if sub := v.Sub("rbft"); sub != nil {
+		if err := sub.UnmarshalExact(&config.Rbft); err != nil {
+			return nil, fmt.Errorf("decode rbft config: %w", err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate checks the values and the relations between them, the error names
+// the offending key.
+func (c *Config) Validate() error {
+	r := c.Rbft
+	if r.SetSize <= 0 {
+		return fmt.Errorf("rbft.set_size must be positive, got %d", r.SetSize)
+	}
+	if r.BatchSize == 0 {
+		return fmt.Errorf("rbft.batch_size must be positive")
+	}
+	if r.PoolSize < r.BatchSize {
+		return fmt.Errorf("rbft.pool_size (%d) must not be less than rbft.batch_size (%d)", r.PoolSize, r.BatchSize)
+	}
+	if r.TxCacheSize <= 0 {
+		return fmt.Errorf("rbft.tx_cache_size must be positive, got %d", r.TxCacheSize)
+	}
+	if r.BatchMemLimit && r.BatchMaxMem == 0 {
+		return fmt.Errorf("rbft.batch_max_mem must be positive when rbft.batch_mem_limit is enabled")
+	}
+
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"rbft.check_interval", r.CheckInterval},
+		{"rbft.tolerance_time", r.ToleranceTime},
+		{"rbft.timeout.sync_state", r.Timeout.SyncState},
+		{"rbft.timeout.sync_interval", r.Timeout.SyncInterval},
+		{"rbft.timeout.recovery", r.Timeout.Recovery},
+		{"rbft.timeout.first_request", r.Timeout.FirstRequest},
+		{"rbft.timeout.batch", r.Timeout.Batch},
+		{"rbft.timeout.request", r.Timeout.Request},
+		{"rbft.timeout.null_request", r.Timeout.NullRequest},
+		{"rbft.timeout.viewchange", r.Timeout.ViewChange},
+		{"rbft.timeout.resend_viewchange", r.Timeout.ResendViewChange},
+		{"rbft.timeout.clean_viewchange", r.Timeout.CleanViewChange},
+		{"rbft.timeout.update", r.Timeout.Update},
+		{"rbft.timeout.set", r.Timeout.Set},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", d.key, d.value)
+		}
+	}
+
+	orders := []struct {
+		greaterKey string
+		greater    time.Duration
+		lessKey    string
+		less       time.Duration
+	}{
+		{"rbft.timeout.request", r.Timeout.Request, "rbft.timeout.batch", r.Timeout.Batch},
+		{"rbft.timeout.null_request", r.Timeout.NullRequest, "rbft.timeout.request", r.Timeout.Request},
+		{"rbft.timeout.sync_interval", r.Timeout.SyncInterval, "rbft.timeout.sync_state", r.Timeout.SyncState},
+		{"rbft.timeout.resend_viewchange", r.Timeout.ResendViewChange, "rbft.timeout.viewchange", r.Timeout.ViewChange},
+		{"rbft.timeout.clean_viewchange", r.Timeout.CleanViewChange, "rbft.timeout.resend_viewchange", r.Timeout.ResendViewChange},
+	}
+	for _, o := range orders {
+		if o.greater <= o.less {
+			return fmt.Errorf("%s (%s) must be greater than %s (%s)", o.greaterKey, o.greater, o.lessKey, o.less)
+		}
+	}
+
+	if r.SyncerConfig.SyncBlocks == 0 {
+		return fmt.Errorf("rbft.syncer.sync_blocks must be positive")
+	}
+
+	crypto := r.CryptoConfig
+	switch strings.ToLower(crypto.Algorithm) {
+	case "", Secp256k1, ECDSAP256, ECDSAP384, ECDSAP521:
+	case Ed25519, SM2:
+		if crypto.KeyFile == "" {
+			return fmt.Errorf("rbft.crypto.key_file is required by algorithm %s", crypto.Algorithm)
+		}
+	default:
+		return fmt.Errorf("rbft.crypto.algorithm %q is not supported", crypto.Algorithm)
+	}
+	if crypto.VerifyCacheSize < 0 {
+		return fmt.Errorf("rbft.crypto.verify_cache_size must not be negative, got %d", crypto.VerifyCacheSize)
+	}
+	if crypto.VerifyWorkers < 0 {
+		return fmt.Errorf("rbft.crypto.verify_workers must not be negative, got %d", crypto.VerifyWorkers)
+	}
+
+	switch r.StorageConfig.Backend {
+	case StorageLevelDB, StoragePebble, StorageMemory:
+	default:
+		return fmt.Errorf("rbft.storage.backend %q is not supported", r.StorageConfig.Backend)
+	}
+
+	audit := r.AuditConfig
+	if audit.Enable {
+		if audit.Dir == "" {
+			return fmt.Errorf("rbft.audit.dir is required when rbft.audit.enable is set")
+		}
+		if audit.MaxFileSizeMB <= 0 {
+			return fmt.Errorf("rbft.audit.max_file_size_mb must be positive, got %d", audit.MaxFileSizeMB)
+		}
+		if audit.MaxFiles <= 0 {
+			return fmt.Errorf("rbft.audit.max_files must be positive, got %d", audit.MaxFiles)
+		}
+	}
+
+	return nil
+}
+
+// Change is a key whose value differs between two configs.
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+// Diff returns the keys changed from old to new, sorted by key.
+func Diff(old, new *Config) []Change {
+	oldValues := old.values()
+	newValues := new.values()
+	changes := make([]Change, 0)
+	for key, value := range newValues {
+		if oldValues[key] != value {
+			changes = append(changes, Change{Key: key, Old: oldValues[key], New: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// String prints every key of the config with its effective value, one per
+// line and sorted by key.
+func (c *Config) String() string {
+	values := c.values()
+	lines := make([]string, 0, len(values))
+	for key, value := range values {
+		lines = append(lines, fmt.Sprintf("%s = %s", key, value))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func (c *Config) values() map[string]string {
+	values := make(map[string]string)
+	flatten("", reflect.ValueOf(*c), values)
+	return values
+}
+
+func flatten(prefix string, v reflect.Value, values map[string]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			flatten(key, field, values)
+			continue
+		}
+		if d, ok := field.Interface().(time.Duration); ok {
+			values[key] = d.String()
+			continue
+		}
+		values[key] = fmt.Sprintf("%v", field.Interface())
+	}
+}
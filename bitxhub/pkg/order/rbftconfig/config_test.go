@@ -0,0 +1,110 @@
+package rbftconfig
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	repoRoot := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(repoRoot, "order.toml"), []byte(content), 0644)
+	require.Nil(t, err)
+	return repoRoot
+}
+
+func TestLoadDefaultOrderConfig(t *testing.T) {
+	config, err := Load("../../../config")
+	require.Nil(t, err)
+	require.Equal(t, 25, config.Rbft.SetSize)
+	require.Equal(t, 6*time.Second, config.Rbft.Timeout.Request)
+	require.Equal(t, uint64(1), config.Rbft.SyncerConfig.SyncBlocks)
+	require.Equal(t, Secp256k1, config.Rbft.CryptoConfig.Algorithm)
+	require.Equal(t, StorageLevelDB, config.Rbft.StorageConfig.Backend)
+}
+
+func TestLoadMissingKeys(t *testing.T) {
+	repoRoot := writeConfig(t, `
+[rbft]
+set_size = 10
+
+    [rbft.timeout]
+        request = "7s"
+`)
+	config, err := Load(repoRoot)
+	require.Nil(t, err)
+
+	expected := DefaultConfig()
+	expected.Rbft.SetSize = 10
+	expected.Rbft.Timeout.Request = 7 * time.Second
+	require.Equal(t, expected, config)
+
+	repoRoot = writeConfig(t, `
+[solo]
+batch_timeout = "0.3s"
+`)
+	config, err = Load(repoRoot)
+	require.Nil(t, err)
+	require.Equal(t, DefaultConfig(), config)
+}
+
+func TestLoadWrongConfig(t *testing.T) {
+	tests := []struct {
+		content string
+		key     string
+	}{
+		{"[rbft]\nset_size = 0", "rbft.set_size"},
+		{"[rbft]\nbatch_size = 100\npool_size = 10", "rbft.pool_size"},
+		{"[rbft]\ntx_cache_size = 0", "rbft.tx_cache_size"},
+		{"[rbft]\nbatch_mem_limit = true\nbatch_max_mem = 0", "rbft.batch_max_mem"},
+		{"[rbft]\ncheck_interval = \"0s\"", "rbft.check_interval"},
+		{"[rbft.timeout]\nbatch = \"6s\"\nrequest = \"5s\"", "rbft.timeout.request"},
+		{"[rbft.timeout]\nrequest = \"6s\"\nnull_request = \"6s\"", "rbft.timeout.null_request"},
+		{"[rbft.timeout]\nrequest = \"2s0\"", "timeout.request"},
+		{"[rbft]\nbatch_sizee = 100", "batch_sizee"},
+		{"[rbft.syncer]\nsync_blocks = 0", "rbft.syncer.sync_blocks"},
+		{"[rbft.crypto]\nalgorithm = \"rsa\"", "rbft.crypto.algorithm"},
+		{"[rbft.crypto]\nalgorithm = \"sm2\"", "rbft.crypto.key_file"},
+		{"[rbft.crypto]\nverify_workers = -1", "rbft.crypto.verify_workers"},
+		{"[rbft.storage]\nbackend = \"rocksdb\"", "rbft.storage.backend"},
+		{"[rbft.audit]\nenable = true\nmax_files = 0", "rbft.audit.max_files"},
+	}
+
+	for _, test := range tests {
+		_, err := Load(writeConfig(t, test.content))
+		require.NotNil(t, err, test.content)
+		require.Contains(t, err.Error(), test.key, test.content)
+	}
+
+	_, err := Load("not_exist")
+	require.NotNil(t, err)
+}
+
+func TestConfigString(t *testing.T) {
+	s := DefaultConfig().String()
+	require.Contains(t, s, "rbft.timeout.request = 6s\n")
+	require.Contains(t, s, "rbft.syncer.sync_blocks = 1\n")
+	require.Contains(t, s, "rbft.crypto.algorithm = secp256k1\n")
+	require.Contains(t, s, "rbft.batch_max_mem = 10000\n")
+	require.True(t, strings.HasPrefix(s, "rbft.audit.dir = audit\n"))
+}
+
+func TestDiff(t *testing.T) {
+	old := DefaultConfig()
+	require.Equal(t, 0, len(Diff(old, DefaultConfig())))
+
+	updated := DefaultConfig()
+	updated.Rbft.SetSize = 10
+	updated.Rbft.Timeout.Batch = time.Second
+	updated.Rbft.CryptoConfig.PublicKeys = map[string]string{"1": "key"}
+	changes := Diff(old, updated)
+	require.Equal(t, []Change{
+		{Key: "rbft.crypto.public_keys", Old: "map[]", New: "map[1:key]"},
+		{Key: "rbft.set_size", Old: "1000", New: "10"},
+		{Key: "rbft.timeout.batch", Old: "200ms", New: "1s"},
+	}, changes)
+}
@@ -0,0 +1,192 @@
+package rbftstorage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommitKey is the state key of the commit record, it is out of the namespace
+// of the RBFT core.
+const CommitKey = "storage.commit"
+
+// ErrTorn is returned by Verify if the last write is not complete.
+var ErrTorn = errors.New("consensus storage is torn")
+
+// Op is a put, or a delete if Delete is set.
+type Op struct {
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
+
+// Batch groups the writes to the state and the batches of a Storage.
+type Batch struct {
+	state   []Op
+	batches []Op
+}
+
+func (b *Batch) PutState(key, value []byte) {
+	b.state = append(b.state, Op{Key: copyBytes(key), Value: copyBytes(value)})
+}
+
+func (b *Batch) DeleteState(key []byte) {
+	b.state = append(b.state, Op{Key: copyBytes(key), Delete: true})
+}
+
+func (b *Batch) PutBatch(key, value []byte) {
+	b.batches = append(b.batches, Op{Key: copyBytes(key), Value: copyBytes(value)})
+}
+
+func (b *Batch) DeleteBatch(key []byte) {
+	b.batches = append(b.batches, Op{Key: copyBytes(key), Delete: true})
+}
+
+func (b *Batch) Len() int {
+	return len(b.state) + len(b.batches)
+}
+
+func (b *Batch) Reset() {
+	b.state = nil
+	b.batches = nil
+}
+
+// commitRecord describes the last write, every written key maps to the hash
+// of its value, or to an empty string if it is deleted.
+type commitRecord struct {
+	Seq     uint64            `json:"seq"`
+	State   map[string]string `json:"state"`
+	Batches map[string]string `json:"batches"`
+}
+
+func digests(ops []Op) map[string]string {
+	ret := make(map[string]string, len(ops))
+	for _, op := range ops {
+		ret[string(op.Key)] = digest(op)
+	}
+	return ret
+}
+
+func digest(op Op) string {
+	if op.Delete {
+		return ""
+	}
+	sum := sha256.Sum256(op.Value)
+	return hex.EncodeToString(sum[:])
+}
+
+// Write applies b with a commit record describing it. The state is written
+// at once, so is everything if the state and the batches share one database.
+// Otherwise the batches are written first, the state written afterwards is
+// never ahead of them.
+func (s *Storage) Write(b *Batch, sync bool) error {
+	if b.Len() == 0 {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	record, err := json.Marshal(&commitRecord{
+		Seq:     s.seq + 1,
+		State:   digests(b.state),
+		Batches: digests(b.batches),
+	})
+	if err != nil {
+		return err
+	}
+	state := append(append([]Op{}, b.state...), Op{Key: []byte(CommitKey), Value: record})
+
+	if shared, ok := sharedStore(s.State, s.Batches); ok {
+		ops := make([]Op, 0, len(state)+len(b.batches))
+		ops = append(ops, s.Batches.(*prefixStore).ops(b.batches)...)
+		ops = append(ops, s.State.(*prefixStore).ops(state)...)
+		if err := shared.Write(ops, sync); err != nil {
+			return err
+		}
+	} else {
+		if len(b.batches) != 0 {
+			if err := s.Batches.Write(b.batches, sync); err != nil {
+				return fmt.Errorf("write batches: %w", err)
+			}
+		}
+		if err := s.State.Write(state, sync); err != nil {
+			return fmt.Errorf("write state: %w", err)
+		}
+	}
+
+	s.seq++
+	return nil
+}
+
+// sharedStore returns the database under both stores if there is one.
+func sharedStore(state, batches Store) (Store, bool) {
+	s, ok := state.(*prefixStore)
+	if !ok {
+		return nil, false
+	}
+	b, ok := batches.(*prefixStore)
+	if !ok || s.store != b.store {
+		return nil, false
+	}
+	return s.store, true
+}
+
+// Verify checks that the last write is complete in both stores, a storage
+// without commit record was never written by Write and is not checked.
+func (s *Storage) Verify() error {
+	record, err := s.readCommitRecord()
+	if err != nil || record == nil {
+		return err
+	}
+
+	var torn []string
+	check := func(name string, store Store, digests map[string]string) error {
+		for key, expected := range digests {
+			value, err := store.Get([]byte(key))
+			if err != nil && err != ErrNotFound {
+				return fmt.Errorf("read %s %s: %w", name, key, err)
+			}
+			actual := ""
+			if err == nil {
+				actual = digest(Op{Value: value})
+			}
+			if actual != expected {
+				torn = append(torn, name+" "+key)
+			}
+		}
+		return nil
+	}
+	if err := check("state", s.State, record.State); err != nil {
+		return err
+	}
+	if err := check("batch", s.Batches, record.Batches); err != nil {
+		return err
+	}
+
+	if len(torn) != 0 {
+		sort.Strings(torn)
+		return fmt.Errorf("%w: write %d is incomplete, %s differ", ErrTorn, record.Seq, strings.Join(torn, ", "))
+	}
+	return nil
+}
+
+func (s *Storage) readCommitRecord() (*commitRecord, error) {
+	data, err := s.State.Get([]byte(CommitKey))
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read commit record: %w", err)
+	}
+
+	record := &commitRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("%w: decode commit record: %s", ErrTorn, err)
+	}
+	return record, nil
+}
@@ -0,0 +1,96 @@
+package rbftstorage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			storage, err := Open(backend, t.TempDir())
+			require.Nil(t, err)
+			defer storage.Close()
+			require.Nil(t, storage.Verify())
+
+			require.Nil(t, storage.State.Put([]byte("consensus.old"), []byte("old")))
+			b := &Batch{}
+			b.PutState([]byte("consensus.view"), []byte("1"))
+			b.PutState([]byte("consensus.chkpt.10"), []byte("digest"))
+			b.DeleteState([]byte("consensus.old"))
+			b.PutBatch([]byte("batch-1"), []byte("txs"))
+			require.Equal(t, 4, b.Len())
+			require.Nil(t, storage.Write(b, true))
+
+			require.Equal(t, map[string]string{"consensus.view": "1", "consensus.chkpt.10": "digest"}, collect(t, storage.State, "consensus."))
+			require.Equal(t, map[string]string{"batch-1": "txs"}, collect(t, storage.Batches, ""))
+			require.Nil(t, storage.Verify())
+
+			b.Reset()
+			require.Equal(t, 0, b.Len())
+			require.Nil(t, storage.Write(b, false))
+			b.PutState([]byte("consensus.view"), []byte("2"))
+			b.DeleteBatch([]byte("batch-1"))
+			require.Nil(t, storage.Write(b, false))
+			record, err := storage.readCommitRecord()
+			require.Nil(t, err)
+			require.Equal(t, uint64(2), record.Seq)
+			require.Equal(t, map[string]string{"batch-1": ""}, record.Batches)
+			require.Nil(t, storage.Verify())
+		})
+	}
+}
+
+func TestWriteReopen(t *testing.T) {
+	for _, backend := range []string{rbftconfig.StorageLevelDB, rbftconfig.StoragePebble} {
+		t.Run(backend, func(t *testing.T) {
+			path := t.TempDir()
+			storage, err := Open(backend, path)
+			require.Nil(t, err)
+			b := &Batch{}
+			b.PutState([]byte("consensus.view"), []byte("1"))
+			require.Nil(t, storage.Write(b, true))
+			require.Nil(t, storage.Close())
+
+			storage, err = Open(backend, path)
+			require.Nil(t, err)
+			defer storage.Close()
+			require.Nil(t, storage.Verify())
+			require.Nil(t, storage.Write(b, true))
+			record, err := storage.readCommitRecord()
+			require.Nil(t, err)
+			require.Equal(t, uint64(2), record.Seq)
+		})
+	}
+}
+
+func TestVerifyTorn(t *testing.T) {
+	storage, err := Open(rbftconfig.StorageLevelDB, t.TempDir())
+	require.Nil(t, err)
+	defer storage.Close()
+
+	b := &Batch{}
+	b.PutState([]byte("consensus.pset.1"), []byte("digest"))
+	b.PutBatch([]byte("batch-1"), []byte("txs"))
+	require.Nil(t, storage.Write(b, false))
+
+	// the batch file is lost, the state refers to a missing batch
+	require.Nil(t, storage.Batches.Delete([]byte("batch-1")))
+	err = storage.Verify()
+	require.True(t, errors.Is(err, ErrTorn))
+	require.Contains(t, err.Error(), "batch batch-1")
+
+	require.Nil(t, storage.Batches.Put([]byte("batch-1"), []byte("txs")))
+	require.Nil(t, storage.Verify())
+
+	require.Nil(t, storage.State.Put([]byte("consensus.pset.1"), []byte("other")))
+	err = storage.Verify()
+	require.True(t, errors.Is(err, ErrTorn))
+	require.Contains(t, err.Error(), "state consensus.pset.1")
+
+	require.Nil(t, storage.State.Put([]byte(CommitKey), []byte("broken")))
+	require.True(t, errors.Is(storage.Verify(), ErrTorn))
+}
@@ -0,0 +1,227 @@
+package rbftstorage
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/meshplus/bitxhub-model/pb"
+)
+
+// Kinds of the consensus state, they are told by the keys the RBFT core
+// writes.
+const (
+	KindView       = "view"
+	KindNewView    = "new-view"
+	KindCheckpoint = "checkpoint"
+	KindWatermark  = "watermark"
+	KindNodes      = "nodes"
+	KindQSet       = "qset"
+	KindPSet       = "pset"
+	KindCSet       = "cset"
+	KindBatch      = "batch"
+	KindCommit     = "commit"
+	KindPendingTx  = "pending-tx"
+	KindUnknown    = "unknown"
+)
+
+// Stores of an Entry.
+const (
+	StoreState   = "state"
+	StoreBatches = "batches"
+)
+
+var statePrefixes = []struct {
+	prefix string
+	kind   string
+}{
+	{"consensus.new-view", KindNewView},
+	{"consensus.setView", KindView},
+	{"consensus.view", KindView},
+	{"consensus.chkpt.", KindCheckpoint},
+	{"consensus.rbft.h", KindWatermark},
+	{"consensus.nodes", KindNodes},
+	{"consensus.qset.", KindQSet},
+	{"consensus.pset.", KindPSet},
+	{"consensus.cset.", KindCSet},
+	{PendingTxPrefix, KindPendingTx},
+}
+
+// KindOf returns the kind of a key in store.
+func KindOf(store, key string) string {
+	if store == StoreBatches {
+		return KindBatch
+	}
+	if key == CommitKey {
+		return KindCommit
+	}
+	for _, p := range statePrefixes {
+		if strings.HasPrefix(key, p.prefix) {
+			return p.kind
+		}
+	}
+	return KindUnknown
+}
+
+// Decoder decodes a value of kind with the types of the RBFT core, it returns
+// nil if it doesn't know the kind and an error if the value is not of the
+// type of the kind.
+type Decoder func(kind, key string, value []byte) (interface{}, error)
+
+// Entry is a decoded key, Raw is kept for export.
+type Entry struct {
+	Store string      `json:"store"`
+	Key   string      `json:"key"`
+	Kind  string      `json:"kind"`
+	Value interface{} `json:"value"`
+	Error string      `json:"error,omitempty"` // why the value is not decoded with the types of the core
+	Raw   []byte      `json:"raw,omitempty"`
+}
+
+// Inspect decodes all keys of s in key order, the state before the batches.
+// The values decode doesn't know, or fails to decode, are decoded by
+// DecodeValue, decode may be nil.
+func Inspect(s *Storage, decode Decoder) ([]*Entry, error) {
+	entries := make([]*Entry, 0)
+	stores := []struct {
+		name  string
+		store Store
+	}{
+		{StoreState, s.State},
+		{StoreBatches, s.Batches},
+	}
+	for _, st := range stores {
+		err := st.store.Iterate(nil, func(key, value []byte) bool {
+			kind := KindOf(st.name, string(key))
+			entry := &Entry{
+				Store: st.name,
+				Key:   string(key),
+				Kind:  kind,
+				Raw:   value,
+			}
+			if decode != nil {
+				v, err := decode(kind, string(key), value)
+				if err != nil {
+					entry.Error = err.Error()
+				} else {
+					entry.Value = v
+				}
+			}
+			if entry.Value == nil {
+				entry.Value = DecodeValue(kind, value)
+			}
+			entries = append(entries, entry)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// DecodeValue decodes value without the types of the RBFT core. Text is kept
+// as it is, numbers written as text are numbers, the commit record is JSON, a
+// pending transaction is a transaction and anything else is decoded as protobuf by field number if it can be, or
+// printed in hex.
+func DecodeValue(kind string, value []byte) interface{} {
+	if kind == KindCommit && json.Valid(value) {
+		return json.RawMessage(value)
+	}
+	if kind == KindPendingTx {
+		tx := &pb.Transaction{}
+		if err := tx.Unmarshal(value); err == nil {
+			return tx
+		}
+	}
+	if isText(value) {
+		if n, err := strconv.ParseUint(string(value), 10, 64); err == nil {
+			return n
+		}
+		return string(value)
+	}
+	if fields, ok := DecodeWire(value); ok && len(fields) != 0 {
+		return fields
+	}
+	return hex.EncodeToString(value)
+}
+
+// DecodeWire decodes protobuf encoded data without the message type, the
+// fields are keyed by their numbers and the repeated ones are lists. Length
+// delimited fields are text, nested messages or hex, whichever fits first.
+func DecodeWire(data []byte) (map[string]interface{}, bool) {
+	fields := make(map[string]interface{})
+	add := func(num uint64, v interface{}) {
+		key := strconv.FormatUint(num, 10)
+		switch old := fields[key].(type) {
+		case nil:
+			fields[key] = v
+		case []interface{}:
+			fields[key] = append(old, v)
+		default:
+			fields[key] = []interface{}{old, v}
+		}
+	}
+
+	for i := 0; i < len(data); {
+		tag, n := binary.Uvarint(data[i:])
+		if n <= 0 || tag>>3 == 0 {
+			return nil, false
+		}
+		i += n
+		num := tag >> 3
+		switch tag & 0x7 {
+		case 0:
+			v, n := binary.Uvarint(data[i:])
+			if n <= 0 {
+				return nil, false
+			}
+			i += n
+			add(num, v)
+		case 1:
+			if i+8 > len(data) {
+				return nil, false
+			}
+			add(num, binary.LittleEndian.Uint64(data[i:]))
+			i += 8
+		case 5:
+			if i+4 > len(data) {
+				return nil, false
+			}
+			add(num, binary.LittleEndian.Uint32(data[i:]))
+			i += 4
+		case 2:
+			l, n := binary.Uvarint(data[i:])
+			if n <= 0 || l > uint64(len(data)-i-n) {
+				return nil, false
+			}
+			i += n
+			field := data[i : i+int(l)]
+			i += int(l)
+			if isText(field) {
+				add(num, string(field))
+			} else if nested, ok := DecodeWire(field); ok && len(nested) != 0 {
+				add(num, nested)
+			} else {
+				add(num, hex.EncodeToString(field))
+			}
+		default:
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
+func isText(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package rbftstorage
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		store string
+		key   string
+		kind  string
+	}{
+		{StoreState, "consensus.view", KindView},
+		{StoreState, "consensus.new-view", KindNewView},
+		{StoreState, "consensus.chkpt.10", KindCheckpoint},
+		{StoreState, "consensus.rbft.h", KindWatermark},
+		{StoreState, "consensus.qset.0.1.digest", KindQSet},
+		{StoreState, "consensus.pset.0.1.digest", KindPSet},
+		{StoreState, "consensus.cset.0.1.digest", KindCSet},
+		{StoreState, CommitKey, KindCommit},
+		{StoreState, PendingTxPrefix + "hash", KindPendingTx},
+		{StoreState, "consensus.other", KindUnknown},
+		{StoreBatches, "digest", KindBatch},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.kind, KindOf(test.store, test.key), test.key)
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	require.Equal(t, uint64(10), DecodeValue(KindView, []byte("10")))
+	require.Equal(t, "digest", DecodeValue(KindCheckpoint, []byte("digest")))
+	require.Equal(t, json.RawMessage(`{"seq":1}`), DecodeValue(KindCommit, []byte(`{"seq":1}`)))
+	require.Equal(t, "ff00", DecodeValue(KindUnknown, []byte{0xff, 0x00}))
+
+	tx := &pb.Transaction{Nonce: 7, Payload: []byte("payload")}
+	data, err := tx.Marshal()
+	require.Nil(t, err)
+	decoded, ok := DecodeValue(KindPendingTx, data).(*pb.Transaction)
+	require.True(t, ok)
+	require.Equal(t, uint64(7), decoded.Nonce)
+
+	header := &pb.BlockHeader{Number: 2, Timestamp: 3, TxRoot: types.NewHash([]byte("root"))}
+	block := &pb.Block{BlockHeader: header, Extra: []byte("extra data")}
+	data, err = proto.Marshal(block)
+	require.Nil(t, err)
+	fields, ok := DecodeValue(KindBatch, data).(map[string]interface{})
+	require.True(t, ok)
+	nested, ok := fields["1"].(map[string]interface{})
+	require.True(t, ok)
+	require.Equal(t, uint64(2), nested["1"])
+	require.Equal(t, "extra data", fields["5"])
+
+	_, ok = DecodeWire([]byte{0x0a, 0x05, 0x01})
+	require.False(t, ok)
+}
+
+func TestInspect(t *testing.T) {
+	storage, err := Open(rbftconfig.StorageMemory, "")
+	require.Nil(t, err)
+	defer storage.Close()
+
+	b := &Batch{}
+	b.PutState([]byte("consensus.view"), []byte("1"))
+	b.PutState([]byte("consensus.pset.1.2.digest"), []byte{0x08, 0x01})
+	b.PutBatch([]byte("digest"), []byte("txs"))
+	require.Nil(t, storage.Write(b, false))
+
+	decode := func(kind, key string, value []byte) (interface{}, error) {
+		switch kind {
+		case KindPSet:
+			return "decoded", nil
+		case KindView:
+			return nil, errors.New("not a view")
+		}
+		return nil, nil
+	}
+	entries, err := Inspect(storage, decode)
+	require.Nil(t, err)
+	require.Equal(t, 4, len(entries))
+	require.Equal(t, &Entry{Store: StoreState, Key: "consensus.pset.1.2.digest", Kind: KindPSet, Value: "decoded", Raw: []byte{0x08, 0x01}}, entries[0])
+	require.Equal(t, uint64(1), entries[1].Value)
+	require.Equal(t, "not a view", entries[1].Error)
+	require.Equal(t, KindCommit, entries[2].Kind)
+	require.Equal(t, &Entry{Store: StoreBatches, Key: "digest", Kind: KindBatch, Value: "txs", Raw: []byte("txs")}, entries[3])
+}
@@ -0,0 +1,201 @@
+package rbftstorage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/meshplus/bitxhub-kit/storage/minifile"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// openLevelDB keeps the layout of the storage before the backends became
+// pluggable, the state in a LevelDB at path and every batch in a file of
+// path/file.
+func openLevelDB(path string) (*Storage, error) {
+	state, err := NewLevelDBStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	batches, err := NewFileStore(filepath.Join(path, "file"))
+	if err != nil {
+		_ = state.Close()
+		return nil, err
+	}
+
+	return &Storage{
+		State:   state,
+		Batches: batches,
+	}, nil
+}
+
+type levelDBStore struct {
+	db *leveldb.DB
+}
+
+// NewLevelDBStore opens a LevelDB at path.
+func NewLevelDBStore(path string) (Store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &levelDBStore{db: db}, nil
+}
+
+func (s *levelDBStore) Get(key []byte) ([]byte, error) {
+	return s.db.Get(key, nil)
+}
+
+func (s *levelDBStore) Put(key, value []byte) error {
+	return s.db.Put(key, value, nil)
+}
+
+func (s *levelDBStore) Delete(key []byte) error {
+	return s.db.Delete(key, nil)
+}
+
+func (s *levelDBStore) Write(ops []Op, sync bool) error {
+	batch := new(leveldb.Batch)
+	for _, op := range ops {
+		if op.Delete {
+			batch.Delete(op.Key)
+		} else {
+			batch.Put(op.Key, op.Value)
+		}
+	}
+	return s.db.Write(batch, &opt.WriteOptions{Sync: sync})
+}
+
+func (s *levelDBStore) Iterate(prefix []byte, fn func(key, value []byte) bool) error {
+	it := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+
+	for it.Next() {
+		if !fn(copyBytes(it.Key()), copyBytes(it.Value())) {
+			break
+		}
+	}
+	return it.Error()
+}
+
+func (s *levelDBStore) Close() error {
+	return s.db.Close()
+}
+
+// fileStore keeps every value in a file named by the key, the keys must be
+// valid file names.
+type fileStore struct {
+	path string
+	file *minifile.MiniFile
+}
+
+// NewFileStore opens a directory of files at path.
+func NewFileStore(path string) (Store, error) {
+	file, err := minifile.New(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileStore{path: path, file: file}, nil
+}
+
+func (s *fileStore) Get(key []byte) ([]byte, error) {
+	value, err := s.file.Get(string(key))
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *fileStore) Put(key, value []byte) error {
+	return s.file.Put(string(key), value)
+}
+
+func (s *fileStore) Delete(key []byte) error {
+	return s.file.Delete(string(key))
+}
+
+// Write is not atomic, every file is written on its own. With sync the files
+// and the directory are synced after all ops are applied.
+func (s *fileStore) Write(ops []Op, sync bool) error {
+	for _, op := range ops {
+		var err error
+		if op.Delete {
+			err = s.Delete(op.Key)
+		} else {
+			err = s.Put(op.Key, op.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !sync {
+		return nil
+	}
+
+	for _, op := range ops {
+		if op.Delete {
+			continue
+		}
+		if err := syncPath(filepath.Join(s.path, string(op.Key))); err != nil {
+			return err
+		}
+	}
+	return syncPath(s.path)
+}
+
+func (s *fileStore) Iterate(prefix []byte, fn func(key, value []byte) bool) error {
+	all, err := s.file.GetAll()
+	if err != nil {
+		return err
+	}
+	return iterateMap(all, prefix, fn)
+}
+
+func (s *fileStore) Close() error {
+	return s.file.Close()
+}
+
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", path, err)
+	}
+	return nil
+}
+
+// iterateMap calls fn with the pairs of m whose key starts with prefix in key
+// order.
+func iterateMap(m map[string][]byte, prefix []byte, fn func(key, value []byte) bool) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		if len(key) >= len(prefix) && key[:len(prefix)] == string(prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !fn([]byte(key), copyBytes(m[key])) {
+			break
+		}
+	}
+	return nil
+}
+
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
@@ -0,0 +1,95 @@
+package rbftstorage
+
+import (
+	"fmt"
+	"sync"
+)
+
+// memoryStore keeps everything in memory, it is lost on restart.
+type memoryStore struct {
+	lock   sync.RWMutex
+	values map[string][]byte
+	closed bool
+}
+
+// NewMemoryStore returns an empty store in memory.
+func NewMemoryStore() Store {
+	return &memoryStore{values: make(map[string][]byte)}
+}
+
+func (s *memoryStore) Get(key []byte) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return nil, fmt.Errorf("memory store is closed")
+	}
+
+	value, ok := s.values[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyBytes(value), nil
+}
+
+func (s *memoryStore) Put(key, value []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return fmt.Errorf("memory store is closed")
+	}
+
+	s.values[string(key)] = copyBytes(value)
+	return nil
+}
+
+func (s *memoryStore) Delete(key []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return fmt.Errorf("memory store is closed")
+	}
+
+	delete(s.values, string(key))
+	return nil
+}
+
+func (s *memoryStore) Write(ops []Op, sync bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return fmt.Errorf("memory store is closed")
+	}
+
+	for _, op := range ops {
+		if op.Delete {
+			delete(s.values, string(op.Key))
+		} else {
+			s.values[string(op.Key)] = copyBytes(op.Value)
+		}
+	}
+	return nil
+}
+
+// Iterate works on a snapshot, fn may write the store.
+func (s *memoryStore) Iterate(prefix []byte, fn func(key, value []byte) bool) error {
+	s.lock.RLock()
+	if s.closed {
+		s.lock.RUnlock()
+		return fmt.Errorf("memory store is closed")
+	}
+	snapshot := make(map[string][]byte, len(s.values))
+	for key, value := range s.values {
+		snapshot[key] = value
+	}
+	s.lock.RUnlock()
+
+	return iterateMap(snapshot, prefix, fn)
+}
+
+func (s *memoryStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	s.values = nil
+	return nil
+}
@@ -0,0 +1,195 @@
+package rbftstorage
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Prefixes of the state and the batches in the same Pebble.
+var (
+	statePrefix = []byte("state.")
+	batchPrefix = []byte("batch.")
+)
+
+// openPebble keeps the state and the batches in one Pebble at path, separated
+// by the prefixes of their keys.
+func openPebble(path string) (*Storage, error) {
+	db, err := NewPebbleStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Storage{
+		State:   &prefixStore{store: db, prefix: statePrefix, owner: true},
+		Batches: &prefixStore{store: db, prefix: batchPrefix},
+	}, nil
+}
+
+// pebbleStore fails after closed, Pebble itself panics.
+type pebbleStore struct {
+	lock   sync.RWMutex
+	db     *pebble.DB
+	closed bool
+}
+
+var errPebbleClosed = errors.New("pebble store is closed")
+
+// NewPebbleStore opens a Pebble at path.
+func NewPebbleStore(path string) (Store, error) {
+	db, err := pebble.Open(path, &pebble.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pebbleStore{db: db}, nil
+}
+
+func (s *pebbleStore) Get(key []byte) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return nil, errPebbleClosed
+	}
+
+	value, closer, err := s.db.Get(key)
+	if err == pebble.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	return copyBytes(value), nil
+}
+
+func (s *pebbleStore) Put(key, value []byte) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return errPebbleClosed
+	}
+
+	return s.db.Set(key, value, pebble.NoSync)
+}
+
+func (s *pebbleStore) Delete(key []byte) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return errPebbleClosed
+	}
+
+	return s.db.Delete(key, pebble.NoSync)
+}
+
+func (s *pebbleStore) Write(ops []Op, sync bool) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return errPebbleClosed
+	}
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	for _, op := range ops {
+		var err error
+		if op.Delete {
+			err = batch.Delete(op.Key, nil)
+		} else {
+			err = batch.Set(op.Key, op.Value, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if sync {
+		return batch.Commit(pebble.Sync)
+	}
+	return batch.Commit(pebble.NoSync)
+}
+
+func (s *pebbleStore) Iterate(prefix []byte, fn func(key, value []byte) bool) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return errPebbleClosed
+	}
+
+	r := util.BytesPrefix(prefix)
+	it := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: r.Start,
+		UpperBound: r.Limit,
+	})
+
+	for valid := it.First(); valid; valid = it.Next() {
+		if !fn(copyBytes(it.Key()), copyBytes(it.Value())) {
+			break
+		}
+	}
+	return it.Close()
+}
+
+func (s *pebbleStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.db.Close()
+}
+
+// prefixStore is the part of a store under prefix, only the owner closes the
+// underlying store.
+type prefixStore struct {
+	store  Store
+	prefix []byte
+	owner  bool
+}
+
+func (s *prefixStore) key(key []byte) []byte {
+	return append(copyBytes(s.prefix), key...)
+}
+
+func (s *prefixStore) Get(key []byte) ([]byte, error) {
+	return s.store.Get(s.key(key))
+}
+
+func (s *prefixStore) Put(key, value []byte) error {
+	return s.store.Put(s.key(key), value)
+}
+
+func (s *prefixStore) Delete(key []byte) error {
+	return s.store.Delete(s.key(key))
+}
+
+func (s *prefixStore) Write(ops []Op, sync bool) error {
+	return s.store.Write(s.ops(ops), sync)
+}
+
+// ops returns ops with the keys prefixed.
+func (s *prefixStore) ops(ops []Op) []Op {
+	ret := make([]Op, 0, len(ops))
+	for _, op := range ops {
+		ret = append(ret, Op{Key: s.key(op.Key), Value: op.Value, Delete: op.Delete})
+	}
+	return ret
+}
+
+func (s *prefixStore) Iterate(prefix []byte, fn func(key, value []byte) bool) error {
+	return s.store.Iterate(s.key(prefix), func(key, value []byte) bool {
+		return fn(key[len(s.prefix):], value)
+	})
+}
+
+func (s *prefixStore) Close() error {
+	if !s.owner {
+		return nil
+	}
+	return s.store.Close()
+}
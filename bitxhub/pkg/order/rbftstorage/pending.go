@@ -0,0 +1,189 @@
+package rbftstorage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/meshplus/bitxhub-model/pb"
+)
+
+// PendingTxPrefix is the state key prefix of the transactions a node has
+// accepted and not seen committed, it is out of the namespace of the RBFT
+// core.
+const PendingTxPrefix = "pending."
+
+// PendingTxs tracks the transactions a node has accepted from its clients
+// until they are committed, so they survive a restart. Save writes what has
+// changed since the last save, the transactions are keyed by hash. The
+// methods of a nil PendingTxs do nothing.
+type PendingTxs struct {
+	saveLock sync.Mutex // serializes Save and Load
+
+	lock  sync.Mutex
+	txs   map[string]*pb.Transaction
+	saved map[string]bool // hashes in the storage
+}
+
+func NewPendingTxs() *PendingTxs {
+	return &PendingTxs{
+		txs:   make(map[string]*pb.Transaction),
+		saved: make(map[string]bool),
+	}
+}
+
+// Add tracks the transactions, the ones without a hash are ignored.
+func (p *PendingTxs) Add(txs ...*pb.Transaction) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, tx := range txs {
+		if tx != nil && tx.TransactionHash != nil {
+			p.txs[tx.TransactionHash.String()] = tx
+		}
+	}
+}
+
+// Remove stops tracking the transactions of the hashes.
+func (p *PendingTxs) Remove(hashes ...string) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, hash := range hashes {
+		delete(p.txs, hash)
+	}
+}
+
+// Prune removes the transactions stale reports and returns how many.
+func (p *PendingTxs) Prune(stale func(tx *pb.Transaction) bool) int {
+	if p == nil {
+		return 0
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	pruned := 0
+	for hash, tx := range p.txs {
+		if stale(tx) {
+			delete(p.txs, hash)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+func (p *PendingTxs) Len() int {
+	if p == nil {
+		return 0
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.txs)
+}
+
+// Save writes the transactions added and deletes the ones removed since the
+// last save at once.
+func (p *PendingTxs) Save(s *Storage, sync bool) error {
+	if p == nil {
+		return nil
+	}
+	p.saveLock.Lock()
+	defer p.saveLock.Unlock()
+
+	var (
+		b       Batch
+		added   []string
+		removed []string
+	)
+	p.lock.Lock()
+	for hash, tx := range p.txs {
+		if p.saved[hash] {
+			continue
+		}
+		data, err := tx.Marshal()
+		if err != nil {
+			p.lock.Unlock()
+			return fmt.Errorf("marshal pending transaction %s: %w", hash, err)
+		}
+		b.PutState([]byte(PendingTxPrefix+hash), data)
+		added = append(added, hash)
+	}
+	for hash := range p.saved {
+		if _, ok := p.txs[hash]; !ok {
+			b.DeleteState([]byte(PendingTxPrefix + hash))
+			removed = append(removed, hash)
+		}
+	}
+	p.lock.Unlock()
+
+	if b.Len() == 0 {
+		return nil
+	}
+	if err := s.Write(&b, sync); err != nil {
+		return fmt.Errorf("save pending transactions: %w", err)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, hash := range added {
+		p.saved[hash] = true
+	}
+	for _, hash := range removed {
+		delete(p.saved, hash)
+	}
+	return nil
+}
+
+// Load tracks the transactions saved in the storage and returns them ordered
+// by account and nonce, the ones which can't be decoded are deleted by the
+// next save.
+func (p *PendingTxs) Load(s *Storage) ([]*pb.Transaction, error) {
+	if p == nil {
+		return nil, nil
+	}
+	p.saveLock.Lock()
+	defer p.saveLock.Unlock()
+
+	var (
+		txs    []*pb.Transaction
+		hashes []string
+	)
+	err := s.State.Iterate([]byte(PendingTxPrefix), func(key, value []byte) bool {
+		hashes = append(hashes, string(key[len(PendingTxPrefix):]))
+		tx := &pb.Transaction{}
+		if err := tx.Unmarshal(value); err != nil || tx.TransactionHash == nil {
+			return true
+		}
+		txs = append(txs, tx)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load pending transactions: %w", err)
+	}
+
+	p.lock.Lock()
+	for _, hash := range hashes {
+		p.saved[hash] = true
+	}
+	p.lock.Unlock()
+	p.Add(txs...)
+
+	sort.SliceStable(txs, func(i, j int) bool {
+		from, other := account(txs[i]), account(txs[j])
+		if from != other {
+			return from < other
+		}
+		return txs[i].Nonce < txs[j].Nonce
+	})
+	return txs, nil
+}
+
+func account(tx *pb.Transaction) string {
+	if tx.From == nil {
+		return ""
+	}
+	return tx.From.String()
+}
@@ -0,0 +1,89 @@
+package rbftstorage
+
+import (
+	"testing"
+
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/stretchr/testify/require"
+)
+
+func newPendingTx(from string, nonce uint64) *pb.Transaction {
+	tx := &pb.Transaction{
+		From:  types.NewAddressByStr(from),
+		To:    types.NewAddressByStr(from),
+		Nonce: nonce,
+	}
+	tx.TransactionHash = tx.Hash()
+	return tx
+}
+
+func TestPendingTxs(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			storage, err := Open(backend, dir)
+			require.Nil(t, err)
+
+			a := "0x0000000000000000000000000000000000000001"
+			b := "0x0000000000000000000000000000000000000002"
+			txs := []*pb.Transaction{newPendingTx(b, 1), newPendingTx(a, 2), newPendingTx(a, 1), newPendingTx(a, 3)}
+			pending := NewPendingTxs()
+			pending.Add(txs...)
+			require.Equal(t, 4, pending.Len())
+			require.Nil(t, pending.Save(storage, true))
+			require.Len(t, collect(t, storage.State, PendingTxPrefix), 4)
+
+			// a committed transaction is deleted by the next save
+			pending.Remove(txs[2].TransactionHash.String())
+			require.Equal(t, 1, pending.Prune(func(tx *pb.Transaction) bool {
+				return tx.Nonce == 3
+			}))
+			require.Nil(t, pending.Save(storage, false))
+			require.Len(t, collect(t, storage.State, PendingTxPrefix), 2)
+			require.Nil(t, storage.Verify())
+
+			require.Nil(t, storage.Close())
+			if backend == rbftconfig.StorageMemory {
+				// nothing survives the memory storage
+				return
+			}
+			storage, err = Open(backend, dir)
+			require.Nil(t, err)
+			defer storage.Close()
+
+			restored := NewPendingTxs()
+			loaded, err := restored.Load(storage)
+			require.Nil(t, err)
+			require.Len(t, loaded, 2)
+			require.Equal(t, txs[1].TransactionHash.String(), loaded[0].TransactionHash.String())
+			require.Equal(t, txs[0].TransactionHash.String(), loaded[1].TransactionHash.String())
+			require.Equal(t, 2, restored.Len())
+
+			// the loaded transactions are not written again
+			restored.Remove(txs[0].TransactionHash.String())
+			require.Nil(t, restored.Save(storage, true))
+			require.Equal(t, []string{PendingTxPrefix + txs[1].TransactionHash.String()}, keys(collect(t, storage.State, PendingTxPrefix)))
+		})
+	}
+}
+
+func TestPendingTxsNil(t *testing.T) {
+	var pending *PendingTxs
+	pending.Add(newPendingTx("0x0000000000000000000000000000000000000001", 1))
+	pending.Remove("hash")
+	require.Equal(t, 0, pending.Len())
+	require.Nil(t, pending.Save(nil, true))
+	txs, err := pending.Load(nil)
+	require.Nil(t, err)
+	require.Nil(t, txs)
+}
+
+func keys(m map[string]string) []string {
+	ret := make([]string, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+	return ret
+}
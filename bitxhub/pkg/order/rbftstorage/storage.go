@@ -0,0 +1,139 @@
+// Package rbftstorage stores the consensus state of the RBFT order plugin. The
+// backend is chosen by rbft.storage.backend in order.toml, the bitxhub command
+// opens the same backends to migrate the state between them.
+package rbftstorage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+)
+
+// ErrNotFound is returned by Get for a missing key. It is the error of
+// goleveldb, the RBFT core has always been given it.
+var ErrNotFound = errors.ErrNotFound
+
+// Store is a key value store of one kind of consensus state.
+type Store interface {
+	// Get returns ErrNotFound if key is missing.
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	// Delete doesn't fail if key is missing.
+	Delete(key []byte) error
+	// Write applies ops in order and all at once, sync flushes them to the
+	// disk before returning.
+	Write(ops []Op, sync bool) error
+	// Iterate calls fn with the pairs whose key starts with prefix in key
+	// order until fn returns false.
+	Iterate(prefix []byte, fn func(key, value []byte) bool) error
+	Close() error
+}
+
+// Storage holds the consensus state of a node, the state keys written by the
+// RBFT core and the batches it persists.
+type Storage struct {
+	Backend string
+	State   Store
+	Batches Store
+
+	lock sync.Mutex
+	seq  uint64 // sequence of the last commit record
+}
+
+// Open opens the storage of backend at path, the memory backend ignores the
+// path and starts empty.
+func Open(backend, path string) (*Storage, error) {
+	var (
+		storage *Storage
+		err     error
+	)
+	switch backend {
+	case rbftconfig.StorageLevelDB:
+		storage, err = openLevelDB(path)
+	case rbftconfig.StoragePebble:
+		storage, err = openPebble(path)
+	case rbftconfig.StorageMemory:
+		storage = &Storage{
+			State:   NewMemoryStore(),
+			Batches: NewMemoryStore(),
+		}
+	default:
+		return nil, fmt.Errorf("storage backend %q is not supported", backend)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open %s storage at %s: %w", backend, path, err)
+	}
+
+	storage.Backend = backend
+	// a broken record is reported by Verify, the storage is still opened
+	// to be inspected and repaired
+	if record, err := storage.readCommitRecord(); err == nil && record != nil {
+		storage.seq = record.Seq
+	}
+	return storage, nil
+}
+
+// Close closes both stores, the first error is returned.
+func (s *Storage) Close() error {
+	batchErr := s.Batches.Close()
+	if err := s.State.Close(); err != nil {
+		return err
+	}
+	return batchErr
+}
+
+// Migrate copies all consensus state from src to dst and returns how many
+// keys are copied. The destination must be empty, a half migrated storage is
+// never merged with another one.
+func Migrate(src, dst *Storage) (int, error) {
+	stores := []struct {
+		name string
+		src  Store
+		dst  Store
+	}{
+		{"state", src.State, dst.State},
+		{"batches", src.Batches, dst.Batches},
+	}
+
+	for _, s := range stores {
+		empty, err := isEmpty(s.dst)
+		if err != nil {
+			return 0, fmt.Errorf("check destination %s: %w", s.name, err)
+		}
+		if !empty {
+			return 0, fmt.Errorf("destination %s is not empty", s.name)
+		}
+	}
+
+	count := 0
+	for _, s := range stores {
+		var putErr error
+		err := s.src.Iterate(nil, func(key, value []byte) bool {
+			putErr = s.dst.Put(key, value)
+			if putErr != nil {
+				return false
+			}
+			count++
+			return true
+		})
+		if err == nil {
+			err = putErr
+		}
+		if err != nil {
+			return count, fmt.Errorf("migrate %s: %w", s.name, err)
+		}
+	}
+
+	return count, nil
+}
+
+func isEmpty(store Store) (bool, error) {
+	empty := true
+	err := store.Iterate(nil, func(key, value []byte) bool {
+		empty = false
+		return false
+	})
+	return empty, err
+}
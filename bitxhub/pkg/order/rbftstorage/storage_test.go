@@ -0,0 +1,147 @@
+package rbftstorage
+
+import (
+	"testing"
+
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/stretchr/testify/require"
+)
+
+var backends = []string{rbftconfig.StorageLevelDB, rbftconfig.StoragePebble, rbftconfig.StorageMemory}
+
+func collect(t *testing.T, store Store, prefix string) map[string]string {
+	ret := make(map[string]string)
+	err := store.Iterate([]byte(prefix), func(key, value []byte) bool {
+		ret[string(key)] = string(value)
+		return true
+	})
+	require.Nil(t, err)
+	return ret
+}
+
+func TestStore(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			storage, err := Open(backend, t.TempDir())
+			require.Nil(t, err)
+			defer storage.Close()
+
+			for _, store := range []Store{storage.State, storage.Batches} {
+				_, err = store.Get([]byte("a.1"))
+				require.Equal(t, ErrNotFound, err)
+
+				require.Nil(t, store.Put([]byte("a.1"), []byte("1")))
+				require.Nil(t, store.Put([]byte("a.2"), []byte("2")))
+				require.Nil(t, store.Put([]byte("b.1"), []byte("3")))
+
+				value, err := store.Get([]byte("a.2"))
+				require.Nil(t, err)
+				require.Equal(t, []byte("2"), value)
+				require.Equal(t, map[string]string{"a.1": "1", "a.2": "2"}, collect(t, store, "a."))
+
+				var keys []string
+				err = store.Iterate(nil, func(key, value []byte) bool {
+					keys = append(keys, string(key))
+					return len(keys) < 2
+				})
+				require.Nil(t, err)
+				require.Equal(t, []string{"a.1", "a.2"}, keys)
+
+				require.Nil(t, store.Delete([]byte("a.1")))
+				require.Nil(t, store.Delete([]byte("not_exist")))
+				_, err = store.Get([]byte("a.1"))
+				require.Equal(t, ErrNotFound, err)
+				require.Equal(t, map[string]string{"a.2": "2", "b.1": "3"}, collect(t, store, ""))
+			}
+		})
+	}
+}
+
+func TestStorageSeparated(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			storage, err := Open(backend, t.TempDir())
+			require.Nil(t, err)
+			defer storage.Close()
+
+			require.Nil(t, storage.State.Put([]byte("key"), []byte("state")))
+			require.Nil(t, storage.Batches.Put([]byte("key"), []byte("batch")))
+			require.Equal(t, map[string]string{"key": "state"}, collect(t, storage.State, ""))
+			require.Equal(t, map[string]string{"key": "batch"}, collect(t, storage.Batches, ""))
+		})
+	}
+}
+
+func TestStorageReopen(t *testing.T) {
+	for _, backend := range []string{rbftconfig.StorageLevelDB, rbftconfig.StoragePebble} {
+		t.Run(backend, func(t *testing.T) {
+			path := t.TempDir()
+			storage, err := Open(backend, path)
+			require.Nil(t, err)
+			require.Nil(t, storage.State.Put([]byte("consensus.view"), []byte("1")))
+			require.Nil(t, storage.Batches.Put([]byte("batch-1"), []byte("txs")))
+			require.Nil(t, storage.Close())
+
+			storage, err = Open(backend, path)
+			require.Nil(t, err)
+			defer storage.Close()
+			require.Equal(t, map[string]string{"consensus.view": "1"}, collect(t, storage.State, ""))
+			require.Equal(t, map[string]string{"batch-1": "txs"}, collect(t, storage.Batches, ""))
+		})
+	}
+}
+
+func TestOpenUnsupported(t *testing.T) {
+	_, err := Open("rocksdb", t.TempDir())
+	require.NotNil(t, err)
+}
+
+func TestMigrate(t *testing.T) {
+	src, err := Open(rbftconfig.StorageLevelDB, t.TempDir())
+	require.Nil(t, err)
+	defer src.Close()
+	require.Nil(t, src.State.Put([]byte("consensus.view"), []byte("1")))
+	require.Nil(t, src.State.Put([]byte("consensus.chkpt.10"), []byte("digest")))
+	require.Nil(t, src.Batches.Put([]byte("batch-1"), []byte("txs")))
+
+	dst, err := Open(rbftconfig.StoragePebble, t.TempDir())
+	require.Nil(t, err)
+	defer dst.Close()
+	count, err := Migrate(src, dst)
+	require.Nil(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, collect(t, src.State, ""), collect(t, dst.State, ""))
+	require.Equal(t, collect(t, src.Batches, ""), collect(t, dst.Batches, ""))
+
+	_, err = Migrate(src, dst)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "not empty")
+
+	mem, err := Open(rbftconfig.StorageMemory, "")
+	require.Nil(t, err)
+	defer mem.Close()
+	count, err = Migrate(dst, mem)
+	require.Nil(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, collect(t, src.Batches, ""), collect(t, mem.Batches, ""))
+}
+
+func TestStoreClosed(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			storage, err := Open(backend, t.TempDir())
+			require.Nil(t, err)
+			require.Nil(t, storage.Close())
+
+			for _, store := range []Store{storage.State, storage.Batches} {
+				_, err = store.Get([]byte("key"))
+				require.NotNil(t, err)
+				require.NotNil(t, store.Put([]byte("key"), []byte("value")))
+				require.NotNil(t, store.Write([]Op{{Key: []byte("key"), Delete: true}}, false))
+			}
+			b := &Batch{}
+			b.PutState([]byte("key"), []byte("value"))
+			require.NotNil(t, storage.Write(b, false))
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package rbftstorage
+
+import (
+	"fmt"
+
+	"github.com/meshplus/bitxhub-model/pb"
+)
+
+// UnappliedPrefix is the state key prefix of the blocks the RBFT core
+// executed and the host never applied, it is out of the namespace of the RBFT
+// core.
+const UnappliedPrefix = "unapplied."
+
+func unappliedKey(height uint64) []byte {
+	// zero padded, so the keys iterate in height order
+	return []byte(fmt.Sprintf("%s%020d", UnappliedPrefix, height))
+}
+
+// SaveUnapplied writes the blocks at once, keyed by height.
+func SaveUnapplied(s *Storage, events []*pb.CommitEvent, sync bool) error {
+	var b Batch
+	for _, ev := range events {
+		data, err := ev.Marshal()
+		if err != nil {
+			return fmt.Errorf("marshal unapplied block %d: %w", ev.Block.Height(), err)
+		}
+		b.PutState(unappliedKey(ev.Block.Height()), data)
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	if err := s.Write(&b, sync); err != nil {
+		return fmt.Errorf("save unapplied blocks: %w", err)
+	}
+	return nil
+}
+
+// LoadUnapplied returns the saved blocks ordered by height, the ones which
+// can't be decoded are skipped.
+func LoadUnapplied(s *Storage) ([]*pb.CommitEvent, error) {
+	var events []*pb.CommitEvent
+	err := s.State.Iterate([]byte(UnappliedPrefix), func(key, value []byte) bool {
+		ev := &pb.CommitEvent{}
+		if err := ev.Unmarshal(value); err != nil || ev.Block == nil || ev.Block.BlockHeader == nil {
+			return true
+		}
+		events = append(events, ev)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load unapplied blocks: %w", err)
+	}
+	return events, nil
+}
+
+// DeleteUnapplied deletes the saved blocks up to height at once.
+func DeleteUnapplied(s *Storage, height uint64, sync bool) error {
+	var b Batch
+	err := s.State.Iterate([]byte(UnappliedPrefix), func(key, value []byte) bool {
+		if string(key) > string(unappliedKey(height)) {
+			return false
+		}
+		b.DeleteState(key)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("iterate unapplied blocks: %w", err)
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	if err := s.Write(&b, sync); err != nil {
+		return fmt.Errorf("delete unapplied blocks: %w", err)
+	}
+	return nil
+}
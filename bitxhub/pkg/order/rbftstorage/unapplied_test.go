@@ -0,0 +1,64 @@
+package rbftstorage
+
+import (
+	"testing"
+
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/stretchr/testify/require"
+)
+
+func newUnapplied(height uint64, txs ...*pb.Transaction) *pb.CommitEvent {
+	local := make([]bool, len(txs))
+	return &pb.CommitEvent{
+		Block: &pb.Block{
+			BlockHeader:  &pb.BlockHeader{Number: height, Timestamp: int64(height)},
+			Transactions: txs,
+		},
+		LocalList: local,
+	}
+}
+
+func TestUnapplied(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			storage, err := Open(backend, dir)
+			require.Nil(t, err)
+
+			a := "0x0000000000000000000000000000000000000001"
+			// 10 sorts before 9 unless the keys are padded
+			events := []*pb.CommitEvent{
+				newUnapplied(10, newPendingTx(a, 2)),
+				newUnapplied(9, newPendingTx(a, 1)),
+				newUnapplied(11),
+			}
+			require.Nil(t, SaveUnapplied(storage, events, true))
+			require.Nil(t, storage.Verify())
+
+			require.Nil(t, storage.Close())
+			if backend == rbftconfig.StorageMemory {
+				// nothing survives the memory storage
+				return
+			}
+			storage, err = Open(backend, dir)
+			require.Nil(t, err)
+			defer storage.Close()
+
+			loaded, err := LoadUnapplied(storage)
+			require.Nil(t, err)
+			require.Len(t, loaded, 3)
+			for i, height := range []uint64{9, 10, 11} {
+				require.Equal(t, height, loaded[i].Block.Height())
+				require.Equal(t, int64(height), loaded[i].Block.BlockHeader.Timestamp)
+			}
+			require.Equal(t, events[1].Block.Transactions[0].TransactionHash.String(), loaded[0].Block.Transactions[0].TransactionHash.String())
+
+			require.Nil(t, DeleteUnapplied(storage, 10, true))
+			loaded, err = LoadUnapplied(storage)
+			require.Nil(t, err)
+			require.Len(t, loaded, 1)
+			require.Equal(t, uint64(11), loaded[0].Block.Height())
+		})
+	}
+}
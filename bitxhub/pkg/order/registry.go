@@ -0,0 +1,51 @@
+package order
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// NewNodeFunc creates an order engine, it has the signature of the NewNode
+// exported by an order plugin.
+type NewNodeFunc func(opts ...Option) (Order, error)
+
+var (
+	enginesLock sync.RWMutex
+	engines     = make(map[string]NewNodeFunc)
+)
+
+// Register links the order engine newNode into the binary under name, the
+// package of the engine calls it in its init. Registering a name twice
+// panics, it is a mistake in the build.
+func Register(name string, newNode NewNodeFunc) {
+	enginesLock.Lock()
+	defer enginesLock.Unlock()
+	if newNode == nil {
+		panic(fmt.Sprintf("order: register nil engine %q", name))
+	}
+	if _, ok := engines[name]; ok {
+		panic(fmt.Sprintf("order: engine %q is registered twice", name))
+	}
+	engines[name] = newNode
+}
+
+// Lookup returns the order engine registered under name.
+func Lookup(name string) (NewNodeFunc, bool) {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+	newNode, ok := engines[name]
+	return newNode, ok
+}
+
+// Engines returns the sorted names of the registered order engines.
+func Engines() []string {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
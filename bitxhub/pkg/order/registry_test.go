@@ -0,0 +1,33 @@
+package order
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	newNode := func(opts ...Option) (Order, error) {
+		return nil, fmt.Errorf("test engine")
+	}
+	Register("test-b", newNode)
+	Register("test-a", newNode)
+
+	registered, ok := Lookup("test-a")
+	require.True(t, ok)
+	_, err := registered()
+	require.EqualError(t, err, "test engine")
+	_, ok = Lookup("test-c")
+	require.False(t, ok)
+
+	names := Engines()
+	require.Contains(t, names, "test-a")
+	require.Contains(t, names, "test-b")
+	for i := 1; i < len(names); i++ {
+		require.Less(t, names[i-1], names[i])
+	}
+
+	require.Panics(t, func() { Register("test-a", newNode) })
+	require.Panics(t, func() { Register("test-nil", nil) })
+}
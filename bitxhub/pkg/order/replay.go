@@ -0,0 +1,121 @@
+package order
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+)
+
+const filterHeightDbKey = "bloom_filter_height"
+
+// ReplayFilter rejects the transactions which have been executed, keyed by
+// transaction hash. The bloom filter answers the common case of a new
+// transaction, its positive answers are confirmed by executed, so a false
+// positive never rejects a transaction and every node decides the same.
+//
+// The hashes of the blocks which are executed but not checkpointed yet are
+// kept in memory, since they are not in the ledger until the blocks are
+// persisted. The methods of a nil ReplayFilter do nothing.
+type ReplayFilter struct {
+	lookUp   *ReqLookUp
+	executed func(hash *types.Hash) bool
+
+	lock   sync.RWMutex
+	recent map[string]uint64 // hash -> height of the blocks not checkpointed
+}
+
+// NewReplayFilter creates a ReplayFilter on top of lookUp, executed reports
+// whether a transaction is in the ledger.
+func NewReplayFilter(lookUp *ReqLookUp, executed func(hash *types.Hash) bool) *ReplayFilter {
+	return &ReplayFilter{
+		lookUp:   lookUp,
+		executed: executed,
+		recent:   make(map[string]uint64),
+	}
+}
+
+// Check returns an error wrapping ErrReplayed if the transaction has been
+// executed.
+func (f *ReplayFilter) Check(hash *types.Hash) error {
+	if f == nil {
+		return nil
+	}
+	key := hash.String()
+	f.lock.RLock()
+	_, ok := f.recent[key]
+	f.lock.RUnlock()
+
+	if ok || (f.lookUp.Test([]byte(key)) && f.executed(hash)) {
+		return fmt.Errorf("transaction %s: %w", key, ErrReplayed)
+	}
+	return nil
+}
+
+// Add records the transaction executed in the block of height.
+func (f *ReplayFilter) Add(height uint64, hash *types.Hash) {
+	if f == nil {
+		return
+	}
+	key := hash.String()
+	f.lookUp.Add([]byte(key))
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.recent[key] = height
+}
+
+// Checkpoint persists the filter after the block of height is persisted.
+func (f *ReplayFilter) Checkpoint(height uint64) error {
+	if f == nil {
+		return nil
+	}
+	if err := f.lookUp.Build(); err != nil {
+		return fmt.Errorf("build bloom filter: %w", err)
+	}
+	// the height is written after the filter, a crash in between only makes
+	// Rebuild add some hashes again
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, height)
+	f.lookUp.storage.Put([]byte(filterHeightDbKey), buf)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for key, h := range f.recent {
+		if h <= height {
+			delete(f.recent, key)
+		}
+	}
+	return nil
+}
+
+// Height returns the height of the last checkpoint.
+func (f *ReplayFilter) Height() uint64 {
+	data := f.lookUp.storage.Get([]byte(filterHeightDbKey))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// Rebuild adds the transactions of the blocks after the last checkpoint up
+// to height, they were executed after the filter was persisted last time.
+func (f *ReplayFilter) Rebuild(height uint64, getBlock func(height uint64) (*pb.Block, error)) error {
+	from := f.Height() + 1
+	if from > height {
+		return nil
+	}
+	for i := from; i <= height; i++ {
+		block, err := getBlock(i)
+		if err != nil {
+			return fmt.Errorf("get block %d: %w", i, err)
+		}
+		for _, tx := range block.Transactions {
+			f.lookUp.Add([]byte(tx.TransactionHash.String()))
+		}
+	}
+	f.lookUp.logger.Infof("Rebuild replay filter from block %d to %d", from, height)
+	return f.Checkpoint(height)
+}
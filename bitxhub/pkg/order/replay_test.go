@@ -0,0 +1,87 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/meshplus/bitxhub-kit/log"
+	"github.com/meshplus/bitxhub-kit/storage/leveldb"
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func newReplayFilter(t *testing.T, path string, ledger map[string]bool) *ReplayFilter {
+	storage, err := leveldb.New(path)
+	require.Nil(t, err)
+	t.Cleanup(func() { storage.Close() })
+	lookUp, err := NewReqLookUp(storage, log.NewWithModule("replay_filter"))
+	require.Nil(t, err)
+	return NewReplayFilter(lookUp, func(hash *types.Hash) bool {
+		return ledger[hash.String()]
+	})
+}
+
+func TestReplayFilter(t *testing.T) {
+	path := t.TempDir()
+	ledger := make(map[string]bool)
+	f := newReplayFilter(t, path, ledger)
+
+	tx1 := types.NewHash([]byte("tx1"))
+	tx2 := types.NewHash([]byte("tx2"))
+	require.Nil(t, f.Check(tx1))
+
+	// executed but not persisted yet
+	f.Add(2, tx1)
+	require.True(t, errors.Is(f.Check(tx1), ErrReplayed))
+	require.Nil(t, f.Check(tx2))
+
+	// persisted, the ledger confirms the bloom filter
+	ledger[tx1.String()] = true
+	require.Nil(t, f.Checkpoint(2))
+	require.Equal(t, uint64(2), f.Height())
+	require.True(t, errors.Is(f.Check(tx1), ErrReplayed))
+
+	// a positive answer of the bloom filter is not enough
+	delete(ledger, tx1.String())
+	require.Nil(t, f.Check(tx1))
+	ledger[tx1.String()] = true
+
+	var nilFilter *ReplayFilter
+	require.Nil(t, nilFilter.Check(tx1))
+}
+
+func TestReplayFilterRebuild(t *testing.T) {
+	path := t.TempDir()
+	ledger := make(map[string]bool)
+	blocks := make(map[uint64]*pb.Block)
+	for i := uint64(1); i <= 5; i++ {
+		tx := &pb.Transaction{TransactionHash: types.NewHash([]byte(fmt.Sprintf("tx%d", i)))}
+		blocks[i] = &pb.Block{Transactions: []*pb.Transaction{tx}}
+		ledger[tx.TransactionHash.String()] = true
+	}
+	getBlock := func(height uint64) (*pb.Block, error) {
+		block, ok := blocks[height]
+		if !ok {
+			return nil, fmt.Errorf("block %d not found", height)
+		}
+		return block, nil
+	}
+
+	f := newReplayFilter(t, path, ledger)
+	require.Nil(t, f.Rebuild(3, getBlock))
+	require.Equal(t, uint64(3), f.Height())
+	require.True(t, errors.Is(f.Check(blocks[3].Transactions[0].TransactionHash), ErrReplayed))
+	require.Nil(t, f.Check(blocks[4].Transactions[0].TransactionHash))
+	f.lookUp.storage.Close()
+
+	// reopen, the checkpointed filter is loaded and the rest is rebuilt
+	f = newReplayFilter(t, path, ledger)
+	require.Equal(t, uint64(3), f.Height())
+	require.Nil(t, f.Rebuild(5, getBlock))
+	for i := uint64(1); i <= 5; i++ {
+		require.True(t, errors.Is(f.Check(blocks[i].Transactions[0].TransactionHash), ErrReplayed))
+	}
+	require.NotNil(t, f.Rebuild(6, getBlock))
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
 	"github.com/meshplus/bitxhub/pkg/order"
@@ -30,6 +32,8 @@ type Node struct {
 	blockTick time.Duration       // block packed period
 	peerMgr   peermgr.PeerManager // network manager
 
+	consensusFeed order.EventFeed // solo never changes its view, nothing is published
+
 	ctx    context.Context
 	cancel context.CancelFunc
 	sync.RWMutex
@@ -53,12 +57,25 @@ func (n *Node) DelNode(delID uint64) error {
 	return nil
 }
 
+func (n *Node) AddNode(vpInfo *pb.VpInfo) error {
+	return fmt.Errorf("add vp node in solo: %w", order.ErrUnsupported)
+}
+
+func (n *Node) SubscribeConsensusEvent(ch chan<- order.ConsensusEvent) event.Subscription {
+	return n.consensusFeed.Subscribe(ch)
+}
+
 func (n *Node) Prepare(tx *pb.Transaction) error {
 	if err := n.Ready(); err != nil {
 		return err
 	}
-	n.txCache.RecvTxC <- tx
-	return nil
+	// the api handlers call Prepare, it never waits for the cache
+	select {
+	case n.txCache.RecvTxC <- tx:
+		return nil
+	default:
+		return fmt.Errorf("transaction cache is full: %w", order.ErrBusy)
+	}
 }
 
 func (n *Node) Commit() chan *pb.CommitEvent {
@@ -86,6 +103,26 @@ func (n *Node) Quorum() uint64 {
 	return 1
 }
 
+// Status reports this node as the only validator, solo never changes its view.
+func (n *Node) Status() *order.OrderStatus {
+	pending, parked := n.mempool.TxCounts()
+	return &order.OrderStatus{
+		Engine:        "solo",
+		NodeID:        n.ID,
+		Role:          order.RoleSolo,
+		Primary:       n.ID,
+		Status:        "Normal",
+		LastCommitted: atomic.LoadUint64(&n.lastExec),
+		PendingTxs:    pending,
+		ParkedTxs:     parked,
+		Validators:    []uint64{n.ID},
+	}
+}
+
+func init() {
+	order.Register("solo", NewNode)
+}
+
 func NewNode(opts ...order.Option) (order.Order, error) {
 	config, err := order.GenerateConfig(opts...)
 	if err != nil {
@@ -103,6 +140,7 @@ func NewNode(opts ...order.Option) (order.Order, error) {
 		Logger:          config.Logger,
 		StoragePath:     config.StoragePath,
 		GetAccountNonce: config.GetAccountNonce,
+		TxStatus:        config.TxStatus,
 
 		BatchSize:      memConfig.BatchSize,
 		PoolSize:       memConfig.PoolSize,
@@ -146,8 +184,9 @@ func (n *Node) listenReadyBlock() {
 					"tx_count":        len(proposal.TxList),
 				}).Debugf("Receive proposal from mempool")
 
-				if proposal.Height != n.lastExec+1 {
-					n.logger.Warningf("Expects to execute seq=%d, but get seq=%d, ignore it", n.lastExec+1, proposal.Height)
+				lastExec := atomic.LoadUint64(&n.lastExec)
+				if proposal.Height != lastExec+1 {
+					n.logger.Warningf("Expects to execute seq=%d, but get seq=%d, ignore it", lastExec+1, proposal.Height)
 					return
 				}
 				n.logger.Infof("======== Call execute, height=%d", proposal.Height)
@@ -168,7 +207,7 @@ func (n *Node) listenReadyBlock() {
 					LocalList: localList,
 				}
 				n.commitC <- executeEvent
-				n.lastExec++
+				atomic.AddUint64(&n.lastExec, 1)
 			}
 		}
 	}()
@@ -1,6 +1,7 @@
 package solo
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -15,6 +16,7 @@ import (
 	"github.com/meshplus/bitxhub-model/pb"
 	"github.com/meshplus/bitxhub/internal/repo"
 	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/mempool"
 	"github.com/meshplus/bitxhub/pkg/peermgr/mock_peermgr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -96,5 +98,27 @@ func TestNode_Start(t *testing.T) {
 	txHashList := make([]*types.Hash, 0)
 	txHashList = append(txHashList, tx.TransactionHash)
 	order.ReportState(commitEvent.Block.Height(), commitEvent.Block.BlockHash, txHashList)
+
+	status := order.Status()
+	require.Equal(t, "solo", status.Engine)
+	require.Equal(t, "solo", status.Role)
+	require.Equal(t, status.NodeID, status.Primary)
+	require.Equal(t, []uint64{status.NodeID}, status.Validators)
+	require.True(t, status.LastCommitted >= 1)
 	order.Stop()
 }
+
+func TestNode_PrepareBusy(t *testing.T) {
+	// nobody reads the cache
+	node := &Node{txCache: &mempool.TxCache{RecvTxC: make(chan *pb.Transaction)}}
+	done := make(chan error, 1)
+	go func() {
+		done <- node.Prepare(&pb.Transaction{Nonce: 1})
+	}()
+	select {
+	case err := <-done:
+		require.True(t, errors.Is(err, order.ErrBusy))
+	case <-time.After(5 * time.Second):
+		t.Fatal("prepare blocks on a full cache")
+	}
+}
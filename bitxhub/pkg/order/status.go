@@ -0,0 +1,67 @@
+package order
+
+import (
+	"sort"
+
+	"github.com/meshplus/bitxhub-model/pb"
+)
+
+// Roles of a node reported by OrderStatus.
+const (
+	RolePrimary   = "primary"
+	RoleReplica   = "replica"
+	RoleLeader    = "leader"
+	RoleFollower  = "follower"
+	RoleCandidate = "candidate"
+	RoleSolo      = "solo"
+)
+
+// OrderStatus is a snapshot of the consensus state of this node, the fields
+// an engine doesn't have are left zero.
+type OrderStatus struct {
+	Engine  string `json:"engine"`
+	NodeID  uint64 `json:"node_id"`
+	Role    string `json:"role"`
+	Primary uint64 `json:"primary"` // primary of rbft or leader of raft, 0 if unknown
+	View    uint64 `json:"view"`    // view of rbft or term of raft
+	Status  string `json:"status"`  // "Normal" if the order accepts transactions
+
+	LastCommitted uint64 `json:"last_committed"` // height of the last executed block
+	LowWatermark  uint64 `json:"low_watermark"`
+	HighWatermark uint64 `json:"high_watermark"`
+
+	PendingTxs uint64 `json:"pending_txs"` // received transactions waiting for a batch
+	ParkedTxs  uint64 `json:"parked_txs"`  // transactions waiting for the missing nonces
+
+	Validators     []uint64 `json:"validators"`
+	ConnectedPeers uint64   `json:"connected_peers"`
+
+	StateUpdate *StateUpdateProgress `json:"state_update,omitempty"` // nil unless the node is catching up
+}
+
+// Phases of a state update reported by StateUpdateProgress.
+const (
+	StateUpdateFetching = "fetching" // the blocks are fetched from the peers
+	StateUpdateApplying = "applying" // the blocks are fetched, the executor is behind
+)
+
+// StateUpdateProgress is a state update in progress, the node catches up with
+// the blocks of its peers.
+type StateUpdateProgress struct {
+	Phase   string `json:"phase"`
+	Target  uint64 `json:"target"`  // height the node updates to
+	Fetched uint64 `json:"fetched"` // height of the last block fetched and handed to the executor
+	Applied uint64 `json:"applied"` // height of the last block the executor has reported
+}
+
+// SortedIDs returns the ids of the routing table in ascending order.
+func SortedIDs(peers map[uint64]*pb.VpInfo) []uint64 {
+	ids := make([]uint64, 0, len(peers))
+	for id := range peers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
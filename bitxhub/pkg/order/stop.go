@@ -0,0 +1,60 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Steps of a graceful stop, in the order they are taken.
+const (
+	StopAccepting = "stop accepting transactions"
+	StopDrain     = "hand the agreed blocks over"
+	StopExecutor  = "wait for the executor"
+	StopCore      = "stop the consensus core"
+	StopStorage   = "close the storage"
+)
+
+// GracefulStopper is an optional capability of the order. The host stops such
+// an order before its executor, so the blocks the order has agreed on are
+// executed before the node goes down.
+type GracefulStopper interface {
+	// GracefulStop takes the steps of a graceful stop in order, a step not
+	// finished before the deadline of ctx is cut short and the rest are taken
+	// at once. It can be called more than once, later calls return the
+	// report of the first one.
+	GracefulStop(ctx context.Context) *StopReport
+}
+
+// StopReport tells what a graceful stop has done and abandoned.
+type StopReport struct {
+	Steps         []string `json:"steps"`                 // steps taken, in order
+	TimedOut      []string `json:"timed_out,omitempty"`   // steps cut short by the deadline
+	LastDelivered uint64   `json:"last_delivered"`        // height of the last block handed to the executor
+	LastReported  uint64   `json:"last_reported"`         // height of the last block the executor has reported
+	Undelivered   []uint64 `json:"undelivered,omitempty"` // heights of the agreed blocks never handed over
+	PendingTxs    int      `json:"pending_txs"`           // accepted transactions saved to be proposed after restart
+	Errors        []string `json:"errors,omitempty"`
+}
+
+// Abandoned reports whether the stop has left blocks unexecuted or failed.
+func (r *StopReport) Abandoned() bool {
+	return len(r.TimedOut) != 0 || len(r.Undelivered) != 0 || len(r.Errors) != 0 ||
+		r.LastReported < r.LastDelivered
+}
+
+func (r *StopReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "steps [%s], delivered %d, reported %d, pending txs %d",
+		strings.Join(r.Steps, ", "), r.LastDelivered, r.LastReported, r.PendingTxs)
+	if len(r.TimedOut) != 0 {
+		fmt.Fprintf(&b, ", timed out [%s]", strings.Join(r.TimedOut, ", "))
+	}
+	if len(r.Undelivered) != 0 {
+		fmt.Fprintf(&b, ", undelivered %v", r.Undelivered)
+	}
+	if len(r.Errors) != 0 {
+		fmt.Fprintf(&b, ", errors [%s]", strings.Join(r.Errors, "; "))
+	}
+	return b.String()
+}
@@ -0,0 +1,28 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopReport(t *testing.T) {
+	report := &StopReport{
+		Steps:         []string{StopAccepting, StopDrain, StopExecutor, StopCore, StopStorage},
+		LastDelivered: 5,
+		LastReported:  5,
+	}
+	require.False(t, report.Abandoned())
+
+	report.LastReported = 4
+	require.True(t, report.Abandoned(), "a delivered block is not executed")
+
+	report.LastReported = 5
+	report.TimedOut = []string{StopDrain}
+	report.Undelivered = []uint64{6, 7}
+	report.PendingTxs = 3
+	require.True(t, report.Abandoned())
+	require.Equal(t, "steps [stop accepting transactions, hand the agreed blocks over, wait for the executor, "+
+		"stop the consensus core, close the storage], delivered 5, reported 5, pending txs 3, "+
+		"timed out [hand the agreed blocks over], undelivered [6 7]", report.String())
+}
@@ -1,13 +1,16 @@
 package syncer
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/Rican7/retry"
 	"github.com/Rican7/retry/strategy"
+	"github.com/cbergoon/merkletree"
 	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
 	"github.com/meshplus/bitxhub/pkg/peermgr"
@@ -77,6 +80,8 @@ func (s *StateSyncer) SyncCFTBlocks(begin, end uint64, blockCh chan *pb.Block) e
 				return err
 			}
 			for _, block := range blocks {
+				// the executor skips the proofs of a block with Extra
+				block.Extra = nil
 				blockCh <- block
 			}
 			return nil
@@ -119,6 +124,31 @@ func (s *StateSyncer) SyncBFTBlocks(begin, end uint64, metaHash *types.Hash, blo
 	return nil
 }
 
+// QuorumBlockHeader returns the header of the block at the height served by
+// the quorum of the peers.
+func (s *StateSyncer) QuorumBlockHeader(height uint64) (*pb.BlockHeader, error) {
+	counter := make(map[string]uint64)
+	for _, id := range s.peerIds {
+		if s.isBadPeer(id) {
+			continue
+		}
+		headers, err := s.fetchBlockHeaders(id, height, height)
+		if err != nil || len(headers) != 1 || headers[0].Number != height {
+			s.logger.WithFields(logrus.Fields{
+				"height":  height,
+				"peer_id": id,
+			}).Warn("fetch block header failed")
+			continue
+		}
+		hash := (&pb.Block{BlockHeader: headers[0]}).Hash().String()
+		counter[hash]++
+		if counter[hash] >= s.quorum {
+			return headers[0], nil
+		}
+	}
+	return nil, fmt.Errorf("the peers don't agree on the block header at %d", height)
+}
+
 func (s *StateSyncer) syncQuorumRangeBlockHeaders(rangeHeight *rangeHeight, parentBlockHash *types.Hash) []*pb.BlockHeader {
 	var isQuorum bool
 	var hash string
@@ -149,7 +179,9 @@ func (s *StateSyncer) syncQuorumRangeBlockHeaders(rangeHeight *rangeHeight, pare
 	}
 
 	for _, id := range s.peerIds {
-
+		if s.isBadPeer(id) {
+			continue
+		}
 		fetchAndVerifyBlockHeaders(id)
 		for latestHash, counter := range latestBlockHeaderCounter {
 			if counter >= s.quorum {
@@ -187,6 +219,11 @@ func (s *StateSyncer) syncRangeBlocks(headers []*pb.BlockHeader) []*pb.Block {
 			s.logger.Errorf("fetch block headers error:%w", err)
 			return
 		}
+		if len(fetchBlocks) != len(headers) {
+			s.badPeers.Store(id, nil)
+			s.logger.Errorf("fetch blocks error: expect %d blocks, but got %d", len(headers), len(fetchBlocks))
+			return
+		}
 		for i, block := range fetchBlocks {
 			err := s.verifyBlock(headers[i], block)
 			if err != nil {
@@ -201,16 +238,23 @@ func (s *StateSyncer) syncRangeBlocks(headers []*pb.BlockHeader) []*pb.Block {
 		if blocks != nil {
 			break
 		}
+		if s.isBadPeer(id) {
+			continue
+		}
 		fetchAndVerifyBlocks(id)
 	}
 	return blocks
 }
 
+func (s *StateSyncer) isBadPeer(id uint64) bool {
+	_, ok := s.badPeers.Load(id)
+	return ok
+}
+
 func (s *StateSyncer) randPeers() (uint64, error) {
 	ids := make([]uint64, 0)
 	for _, id := range s.peerIds {
-		_, ok := s.badPeers.Load(id)
-		if ok {
+		if s.isBadPeer(id) {
 			continue
 		}
 		ids = append(ids, id)
@@ -327,10 +371,99 @@ func (s *StateSyncer) verifyBlock(header *pb.BlockHeader, block *pb.Block) error
 	}
 	originBlock := &pb.Block{BlockHeader: header}
 	hash := originBlock.Hash()
-	//todo(jz): need to calc txs merkle root and compare with block's tx root
 	ok, _ := hash.Equals(block.BlockHash)
 	if !ok {
 		return fmt.Errorf("block hash is not equals, number is %d", block.Height())
 	}
+	if block.BlockHeader == nil {
+		return fmt.Errorf("block header is nil, number is %d", header.Number)
+	}
+	ok, _ = hash.Equals(block.Hash())
+	if !ok {
+		return fmt.Errorf("block header is not equals, number is %d", block.Height())
+	}
+
+	// the block hash covers the header only, the transactions are checked
+	// against the tx root with the interchain meta served in Extra
+	meta := &pb.InterchainMeta{}
+	if block.Extra != nil {
+		if err := meta.Unmarshal(block.Extra); err != nil {
+			return fmt.Errorf("unmarshal interchain meta error: %w, number is %d", err, block.Height())
+		}
+	}
+	txRoot, err := CalcTxRoot(block.Transactions, meta.Counter)
+	if err != nil {
+		return fmt.Errorf("calc tx root error: %w, number is %d", err, block.Height())
+	}
+	if header.TxRoot == nil {
+		return fmt.Errorf("block tx root is nil, number is %d", block.Height())
+	}
+	ok, _ = txRoot.Equals(header.TxRoot)
+	if !ok {
+		return fmt.Errorf("block transactions are not equals, number is %d", block.Height())
+	}
+	block.Extra = nil
 	return nil
 }
+
+// CalcTxRoot calculates the tx root of the transactions like the executor,
+// counter is the interchain counter of the block. Every destination of the
+// interchain transactions and the normal transactions have a tree, whose
+// sorted roots are the leaves of the tx root.
+func CalcTxRoot(txs []*pb.Transaction, counter map[string]*pb.VerifiedIndexSlice) (*types.Hash, error) {
+	interchain := make(map[uint64]bool)
+	l2Roots := make([]types.Hash, 0, len(counter)+1)
+	for _, indexes := range counter {
+		verifiedTxs := make([]merkletree.Content, 0, len(indexes.Slice))
+		for _, index := range indexes.Slice {
+			if index.Index >= uint64(len(txs)) {
+				return nil, fmt.Errorf("interchain tx index %d is out of %d txs", index.Index, len(txs))
+			}
+			interchain[index.Index] = true
+			verifiedTxs = append(verifiedTxs, &pb.VerifiedTx{
+				Tx:    txs[index.Index],
+				Valid: index.Valid,
+			})
+		}
+		root, err := calcMerkleRoot(verifiedTxs)
+		if err != nil {
+			return nil, err
+		}
+		l2Roots = append(l2Roots, *root)
+	}
+
+	txHashes := make([]merkletree.Content, 0, len(txs)-len(interchain))
+	for i, tx := range txs {
+		if !interchain[uint64(i)] {
+			txHashes = append(txHashes, tx.TransactionHash)
+		}
+	}
+	root, err := calcMerkleRoot(txHashes)
+	if err != nil {
+		return nil, err
+	}
+	l2Roots = append(l2Roots, *root)
+
+	sort.Slice(l2Roots, func(i, j int) bool {
+		return bytes.Compare(l2Roots[i].Bytes(), l2Roots[j].Bytes()) < 0
+	})
+	contents := make([]merkletree.Content, 0, len(l2Roots))
+	for _, l2Root := range l2Roots {
+		r := l2Root
+		contents = append(contents, &r)
+	}
+	return calcMerkleRoot(contents)
+}
+
+func calcMerkleRoot(contents []merkletree.Content) (*types.Hash, error) {
+	if len(contents) == 0 {
+		return &types.Hash{}, nil
+	}
+
+	tree, err := merkletree.NewTree(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewHash(tree.MerkleRoot()), nil
+}
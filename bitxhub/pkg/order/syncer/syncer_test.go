@@ -14,6 +14,34 @@ import (
 )
 
 func preparePeerMgr(t *testing.T) peermgr.PeerManager {
+	return preparePeerMgrWithBadPeer(t, 0, nil)
+}
+
+// forgeHeader keeps the block hash but forges the header
+func forgeHeader(block *pb.Block) *pb.Block {
+	return &pb.Block{
+		BlockHeader: &pb.BlockHeader{
+			Number:     block.BlockHeader.Number,
+			StateRoot:  types.NewHashByStr("0x0000000000000000000000000000000000000000000000000000000000000001"),
+			ParentHash: block.BlockHeader.ParentHash,
+		},
+		BlockHash:    block.BlockHash,
+		Transactions: block.Transactions,
+	}
+}
+
+// forgeTxs keeps the valid header but tampers the transactions
+func forgeTxs(block *pb.Block) *pb.Block {
+	return &pb.Block{
+		BlockHeader:  block.BlockHeader,
+		BlockHash:    block.BlockHash,
+		Transactions: []*pb.Transaction{genTx(block.BlockHeader.Number + 10000)},
+	}
+}
+
+// preparePeerMgrWithBadPeer returns a peer manager whose peer badID serves
+// blocks forged by forge.
+func preparePeerMgrWithBadPeer(t *testing.T, badID uint64, forge func(*pb.Block) *pb.Block) peermgr.PeerManager {
 	ctrl := gomock.NewController(t)
 	mockPeerMgr := mock_peermgr.NewMockPeerManager(ctrl)
 	genblocks := genBlocks(1024)
@@ -43,7 +71,11 @@ func preparePeerMgr(t *testing.T) peermgr.PeerManager {
 			res := &pb.GetBlocksResponse{}
 			blocks := make([]*pb.Block, 0)
 			for i := req.Start; i <= req.End; i++ {
-				blocks = append(blocks, genblocks[i-1])
+				block := genblocks[i-1]
+				if id == badID {
+					block = forge(block)
+				}
+				blocks = append(blocks, block)
 			}
 			res.Blocks = blocks
 			v, err := res.Marshal()
@@ -108,6 +140,93 @@ func TestStateSyncer_SyncBFTBlocks(t *testing.T) {
 	require.Equal(t, len(blocks), end-begin+1)
 }
 
+func TestStateSyncer_SyncBFTBlocksWithBadPeer(t *testing.T) {
+	testSyncBFTBlocksWithBadPeer(t, forgeHeader)
+}
+
+func TestStateSyncer_SyncBFTBlocksWithForgedTxs(t *testing.T) {
+	testSyncBFTBlocksWithBadPeer(t, forgeTxs)
+}
+
+func testSyncBFTBlocksWithBadPeer(t *testing.T, forge func(*pb.Block) *pb.Block) {
+	mockPeerMgr := preparePeerMgrWithBadPeer(t, 2, forge)
+	peerIds := []uint64{2, 3, 4}
+	logger := log.NewWithModule("syncer")
+	syncer, err := New(10, mockPeerMgr, 2, peerIds, logger)
+	require.Nil(t, err)
+
+	begin := 2
+	end := 100
+	blockCh := make(chan *pb.Block, 1024)
+
+	metaHash := types.NewHashByStr("0xbC1C6897f97782F3161492d5CcfBE0691502f15894A0b2f2f40069C995E33cCB")
+	go syncer.SyncBFTBlocks(uint64(begin), uint64(end), metaHash, blockCh)
+
+	blocks := make([]*pb.Block, 0)
+	for block := range blockCh {
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+
+	require.Equal(t, len(blocks), end-begin+1)
+	genblocks := genBlocks(end)
+	for _, block := range blocks {
+		require.Equal(t, block.BlockHash.String(), block.Hash().String())
+		require.Equal(t, genblocks[block.Height()-1].Transactions, block.Transactions)
+	}
+	require.True(t, syncer.isBadPeer(2))
+	require.False(t, syncer.isBadPeer(3))
+}
+
+func TestStateSyncer_QuorumBlockHeader(t *testing.T) {
+	mockPeerMgr := preparePeerMgr(t)
+	peerIds := []uint64{2, 3, 4}
+	logger := log.NewWithModule("syncer")
+	syncer, err := New(10, mockPeerMgr, 3, peerIds, logger)
+	require.Nil(t, err)
+
+	header, err := syncer.QuorumBlockHeader(50)
+	require.Nil(t, err)
+	require.Equal(t, genBlocks(50)[49].BlockHash.String(), (&pb.Block{BlockHeader: header}).Hash().String())
+
+	syncer, err = New(10, mockPeerMgr, 4, peerIds, logger)
+	require.Nil(t, err)
+	_, err = syncer.QuorumBlockHeader(50)
+	require.NotNil(t, err)
+}
+
+func TestStateSyncer_VerifyBlockWithInterchainMeta(t *testing.T) {
+	logger := log.NewWithModule("syncer")
+	syncer, err := New(10, preparePeerMgr(t), 2, []uint64{2, 3, 4}, logger)
+	require.Nil(t, err)
+
+	txs := []*pb.Transaction{genTx(1), genTx(2)}
+	meta := &pb.InterchainMeta{
+		Counter: map[string]*pb.VerifiedIndexSlice{
+			"dst": {Slice: []*pb.VerifiedIndex{{Index: 1, Valid: true}}},
+		},
+	}
+	txRoot, err := CalcTxRoot(txs, meta.Counter)
+	require.Nil(t, err)
+	header := &pb.BlockHeader{Number: 2, TxRoot: txRoot}
+	extra, err := meta.Marshal()
+	require.Nil(t, err)
+
+	block := &pb.Block{BlockHeader: header, Transactions: txs, Extra: extra}
+	block.BlockHash = block.Hash()
+	require.Nil(t, syncer.verifyBlock(header, block))
+	require.Nil(t, block.Extra)
+
+	// without the interchain meta the transactions are all normal ones
+	require.NotNil(t, syncer.verifyBlock(header, block))
+
+	meta.Counter["dst"].Slice[0].Index = 2
+	_, err = CalcTxRoot(txs, meta.Counter)
+	require.NotNil(t, err)
+}
+
 func genBlocks(count int) []*pb.Block {
 	blocks := make([]*pb.Block, 0, count)
 	for height := 1; height <= count; height++ {
@@ -124,10 +243,15 @@ func genBlocks(count int) []*pb.Block {
 			}
 			block.BlockHash = types.NewHashByStr("0xbC1C6897f97782F3161492d5CcfBE0691502f15894A0b2f2f40069C995E33cCB")
 		} else {
+			block.Transactions = []*pb.Transaction{genTx(uint64(height))}
+			txRoot, err := CalcTxRoot(block.Transactions, nil)
+			if err != nil {
+				panic(err)
+			}
 			block.BlockHeader = &pb.BlockHeader{
 				Number:      uint64(height),
 				StateRoot:   blocks[len(blocks)-1].BlockHeader.StateRoot,
-				TxRoot:      nil,
+				TxRoot:      txRoot,
 				ReceiptRoot: nil,
 				ParentHash:  blocks[len(blocks)-1].BlockHash,
 				Timestamp:   0,
@@ -139,3 +263,13 @@ func genBlocks(count int) []*pb.Block {
 	}
 	return blocks
 }
+
+func genTx(nonce uint64) *pb.Transaction {
+	tx := &pb.Transaction{
+		From:  types.NewAddressByStr("0x3f9d18f7c3a6e5e4c0b877fe3e688ab08840b997"),
+		To:    types.NewAddressByStr("0x000000000000000000000000000000000000000a"),
+		Nonce: nonce,
+	}
+	tx.TransactionHash = tx.Hash()
+	return tx
+}
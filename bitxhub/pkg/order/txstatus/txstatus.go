@@ -0,0 +1,183 @@
+// Package txstatus tracks the lifecycle of the transactions submitted to this
+// node, from the order receiving them to the executor committing them. It is
+// fed by the api, the order (plugin or mempool) and the executor, and queried
+// by clients instead of polling receipts.
+package txstatus
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long a status is kept after its last update.
+const DefaultTTL = 10 * time.Minute
+
+// State is a stage of the transaction lifecycle.
+type State int
+
+const (
+	Unknown State = iota
+	// Received means the order accepted the transaction from the api.
+	Received
+	// Pooled means the transaction is in the pool of the consensus engine.
+	Pooled
+	// Batched means the transaction is in a batch proposed for a block.
+	Batched
+	// Executed means the block containing the transaction is persisted.
+	Executed
+	// Rejected means the transaction was dropped, see the reason.
+	Rejected
+)
+
+func (s State) String() string {
+	switch s {
+	case Received:
+		return "received"
+	case Pooled:
+		return "pooled"
+	case Batched:
+		return "batched"
+	case Executed:
+		return "executed"
+	case Rejected:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Status is the latest known state of a transaction.
+type Status struct {
+	Hash      string `json:"hash"`
+	State     State  `json:"state"`
+	Reason    string `json:"reason,omitempty"` // why the transaction was rejected
+	Height    uint64 `json:"height,omitempty"` // height of the batch or the block
+	UpdatedAt int64  `json:"updated_at"`       // unix nano
+}
+
+// Index keeps the statuses of the recent transactions in memory, a status
+// expires ttl after its last update. The methods of a nil Index do nothing,
+// so the feeders don't need to check whether tracking is enabled.
+type Index struct {
+	lock      sync.RWMutex
+	ttl       time.Duration
+	statuses  map[string]*Status
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New creates an Index, DefaultTTL is used if ttl is not positive.
+func New(ttl time.Duration) *Index {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Index{
+		ttl:       ttl,
+		statuses:  make(map[string]*Status),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Set moves the transactions to state, height is the batch or block height
+// for Batched and Executed. Stale updates are ignored, see canMove.
+func (idx *Index) Set(state State, height uint64, hashes ...string) {
+	if idx == nil {
+		return
+	}
+	idx.update(hashes, state, height, "")
+}
+
+// Reject marks the transactions as rejected for the given reason.
+func (idx *Index) Reject(reason string, hashes ...string) {
+	if idx == nil {
+		return
+	}
+	idx.update(hashes, Rejected, 0, reason)
+}
+
+// Get returns the status of the transaction, false if it is unknown or
+// expired.
+func (idx *Index) Get(hash string) (*Status, bool) {
+	if idx == nil {
+		return nil, false
+	}
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	status, ok := idx.statuses[hash]
+	if !ok || idx.expired(status, idx.now()) {
+		return nil, false
+	}
+	ret := *status
+	return &ret, true
+}
+
+// Len returns the number of statuses kept, including the expired ones which
+// are not swept yet.
+func (idx *Index) Len() int {
+	if idx == nil {
+		return 0
+	}
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	return len(idx.statuses)
+}
+
+func (idx *Index) update(hashes []string, state State, height uint64, reason string) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	now := idx.now()
+	for _, hash := range hashes {
+		if old, ok := idx.statuses[hash]; ok && !idx.expired(old, now) && !canMove(old.State, state) {
+			continue
+		}
+		idx.statuses[hash] = &Status{
+			Hash:      hash,
+			State:     state,
+			Reason:    reason,
+			Height:    height,
+			UpdatedAt: now.UnixNano(),
+		}
+	}
+
+	if now.Sub(idx.lastSweep) >= idx.ttl {
+		idx.sweep(now)
+	}
+}
+
+func (idx *Index) sweep(now time.Time) {
+	for hash, status := range idx.statuses {
+		if idx.expired(status, now) {
+			delete(idx.statuses, hash)
+		}
+	}
+	idx.lastSweep = now
+}
+
+func (idx *Index) expired(status *Status, now time.Time) bool {
+	return now.UnixNano()-status.UpdatedAt >= int64(idx.ttl)
+}
+
+// canMove reports whether a transaction in state from can move to state to.
+// The feeders run in different goroutines, so the updates of a transaction
+// may arrive out of order, and a resubmitted transaction is reported again by
+// the api. Executed is final, a transaction is received again only after it
+// was rejected, and the other states only move forward.
+func canMove(from, to State) bool {
+	switch {
+	case from == Executed:
+		return false
+	case to == Executed, to == Rejected:
+		return true
+	case to == Received:
+		return from == Rejected
+	default:
+		return from != Rejected && to > from
+	}
+}
@@ -0,0 +1,89 @@
+package txstatus
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle(t *testing.T) {
+	idx := New(time.Minute)
+
+	_, ok := idx.Get("tx1")
+	require.False(t, ok)
+
+	idx.Set(Received, 0, "tx1", "tx2")
+	idx.Set(Pooled, 0, "tx1")
+	idx.Set(Batched, 5, "tx1")
+	status, ok := idx.Get("tx1")
+	require.True(t, ok)
+	require.Equal(t, Batched, status.State)
+	require.Equal(t, uint64(5), status.Height)
+
+	// late updates don't move the state backward
+	idx.Set(Pooled, 0, "tx1")
+	idx.Set(Received, 0, "tx1")
+	status, _ = idx.Get("tx1")
+	require.Equal(t, Batched, status.State)
+
+	idx.Set(Executed, 5, "tx1")
+	idx.Reject("duplicate", "tx1")
+	status, _ = idx.Get("tx1")
+	require.Equal(t, Executed, status.State)
+
+	// a rejected transaction can be received again
+	idx.Reject("transaction pool is full", "tx2")
+	status, _ = idx.Get("tx2")
+	require.Equal(t, Rejected, status.State)
+	require.Equal(t, "transaction pool is full", status.Reason)
+	idx.Set(Pooled, 0, "tx2")
+	status, _ = idx.Get("tx2")
+	require.Equal(t, Rejected, status.State)
+	idx.Set(Received, 0, "tx2")
+	status, _ = idx.Get("tx2")
+	require.Equal(t, Received, status.State)
+	require.Equal(t, "", status.Reason)
+
+	data, err := json.Marshal(status)
+	require.Nil(t, err)
+	require.Contains(t, string(data), `"state":"received"`)
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Now()
+	idx := New(time.Minute)
+	idx.now = func() time.Time { return now }
+
+	idx.Set(Received, 0, "tx1")
+	now = now.Add(30 * time.Second)
+	idx.Set(Received, 0, "tx2")
+	_, ok := idx.Get("tx1")
+	require.True(t, ok)
+
+	now = now.Add(30 * time.Second)
+	_, ok = idx.Get("tx1")
+	require.False(t, ok)
+	_, ok = idx.Get("tx2")
+	require.True(t, ok)
+
+	// an expired status is replaced even by a backward state
+	idx.Set(Received, 0, "tx1")
+	_, ok = idx.Get("tx1")
+	require.True(t, ok)
+
+	// the expired statuses are swept by the updates
+	now = now.Add(2 * time.Minute)
+	idx.Set(Received, 0, "tx3")
+	require.Equal(t, 1, idx.Len())
+}
+
+func TestNilIndex(t *testing.T) {
+	var idx *Index
+	idx.Set(Received, 0, "tx1")
+	idx.Reject("busy", "tx1")
+	_, ok := idx.Get("tx1")
+	require.False(t, ok)
+	require.Equal(t, 0, idx.Len())
+}
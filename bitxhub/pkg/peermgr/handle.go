@@ -291,6 +291,15 @@ func (swarm *Swarm) handleGetBlocksPack(s network.Stream, msg *pb.Message) error
 		if err != nil {
 			return err
 		}
+		// the syncer needs the interchain meta to check the transactions
+		// against the tx root
+		meta, err := swarm.ledger.GetInterchainMeta(i)
+		if err != nil {
+			return err
+		}
+		if block.Extra, err = meta.Marshal(); err != nil {
+			return err
+		}
 		blocks = append(blocks, block)
 	}
 	res.Blocks = blocks
@@ -105,6 +105,7 @@ func NewSwarms(t *testing.T, peerCnt int) []*Swarm {
 	data, err := json.Marshal(aer)
 	require.Nil(t, err)
 
+	mockLedger.EXPECT().GetInterchainMeta(gomock.Any()).Return(&pb.InterchainMeta{}, nil).AnyTimes()
 	mockLedger.EXPECT().GetBlockSign(gomock.Any()).Return([]byte("sign"), nil).AnyTimes()
 	mockLedger.EXPECT().GetState(gomock.Any(), gomock.Any()).Return(true, data).AnyTimes()
 
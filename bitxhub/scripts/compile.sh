@@ -2,7 +2,7 @@
 
 go env -w GO111MODULE=on
 go env -w GOPROXY=https://goproxy.cn,direct
-go get -u github.com/gobuffalo/packr/packr
+go install github.com/gobuffalo/packr/packr@v1.30.1
 cd /code/bitxhub || exit
 make install
 cd internal/plugins || exit
@@ -9,14 +9,14 @@ source x.sh
 case $1 in
 linux-amd64)
   print_blue "Compile for linux/amd64"
-  if [ -z "$(docker image inspect golang:1.13)" ]; then
-    docker pull golang:1.13
+  if [ -z "$(docker image inspect golang:1.18)" ]; then
+    docker pull golang:1.18
   else
-    print_blue "golang:1.13 image already exist"
+    print_blue "golang:1.18 image already exist"
   fi
 
   if [ "$(docker container ls -a | grep -c bitxhub_linux)" -ge 1 ];then
-    print_blue "golang:1.13 container already exist"
+    print_blue "golang:1.18 container already exist"
     rm -f "${BIN_PATH}"/bitxhub_linux-amd64
     docker restart bitxhub_linux
     docker logs bitxhub_linux -f --tail "0"
@@ -26,7 +26,7 @@ linux-amd64)
       -v ~/.ssh:/root/.ssh \
       -v ~/.gitconfig:/root/.gitconfig \
       -v $GOPATH/pkg/mod:$GOPATH/pkg/mod \
-      golang:1.13 \
+      golang:1.18 \
       /bin/bash /code/bitxhub/scripts/compile.sh
   fi
   ;;
@@ -9,20 +9,17 @@ function print_blue() {
 
 print_blue "===> 1. Install packr"
 if ! type packr >/dev/null 2>&1; then
-  go get -u github.com/gobuffalo/packr/packr
+  go install github.com/gobuffalo/packr/packr@v1.30.1
 fi
 
 print_blue "===> 2. Install golangci-lint"
 if ! type golanci-lint >/dev/null 2>&1; then
-  go get github.com/golangci/golangci-lint/cmd/golangci-lint@v1.23.0
+  go install github.com/golangci/golangci-lint/cmd/golangci-lint@v1.45.2
 fi
 
 print_blue "===> 3. Install go mock tool"
-if ! type gomock >/dev/null 2>&1; then
-  go get github.com/golang/mock/gomock
-fi
 if ! type mockgen >/dev/null 2>&1; then
-  go get github.com/golang/mock/mockgen
+  go install github.com/golang/mock/mockgen@v1.6.0
 fi
 
 function Get_PM_Name()
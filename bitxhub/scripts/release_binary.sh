@@ -10,7 +10,7 @@ APP_VERSION=$(if [ `git rev-parse --abbrev-ref HEAD` == 'HEAD' ];then git descri
 
 print_blue "===> 1. Install packr"
 if ! type packr >/dev/null 2>&1; then
-  go get -u github.com/gobuffalo/packr/packr
+  go install github.com/gobuffalo/packr/packr@v1.30.1
 fi
 
 print_blue "===> 2. build bitxhub"
@@ -0,0 +1,264 @@
+package rbft
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/stretchr/testify/require"
+	"github.com/ultramesh/rbft/rbftpb"
+)
+
+// replayLag is how many messages a replaying node sends before it starts to
+// send its old messages again, they are from old views once the primary has
+// rotated.
+const replayLag = 20
+
+// byzantineFaults describes how a faulty node misbehaves, message types are
+// the names of rbftpb.Type.
+type byzantineFaults struct {
+	equivocate  bool     // as primary, send a conflicting pre-prepare to half of the peers
+	silent      bool     // withhold all messages
+	withhold    []string // withhold the messages of these types
+	corruptSigs bool     // sign with broken signatures
+	replayOld   bool     // broadcast old messages again
+	forgeBlocks bool     // serve forged blocks to the nodes in state update
+	tamperTxs   bool     // serve the real headers with forged transactions in state update
+
+	injected uint64 // faults injected so far, a scenario without any proves nothing
+}
+
+func (f *byzantineFaults) inject() {
+	atomic.AddUint64(&f.injected, 1)
+}
+
+func (f *byzantineFaults) injectedFaults() uint64 {
+	return atomic.LoadUint64(&f.injected)
+}
+
+// byzantineStack wraps the Stack given to the RBFT core of a faulty node, the
+// core of the node is honest but what it sends is tampered with.
+type byzantineStack struct {
+	*Stack
+	faults *byzantineFaults
+
+	lock     sync.Mutex
+	sent     []*rbftpb.ConsensusMessage
+	replayed int
+}
+
+func newByzantineStack(s *Stack, faults *byzantineFaults) *byzantineStack {
+	return &byzantineStack{
+		Stack:  s,
+		faults: faults,
+	}
+}
+
+func (b *byzantineStack) Broadcast(msg *rbftpb.ConsensusMessage) error {
+	if b.withheld(msg) {
+		return nil
+	}
+	if b.faults.equivocate && msg.Type == rbftpb.Type_PRE_PREPARE {
+		forged, err := equivocation(msg)
+		if err != nil {
+			return err
+		}
+		b.faults.inject()
+		for id := range b.peerMgr.Peers() {
+			if id == b.localID {
+				continue
+			}
+			m := msg
+			if id%2 == 0 {
+				m = forged
+			}
+			if err := b.Stack.Unicast(m, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := b.Stack.Broadcast(msg); err != nil {
+		return err
+	}
+	b.replay(msg)
+	return nil
+}
+
+func (b *byzantineStack) Unicast(msg *rbftpb.ConsensusMessage, to uint64) error {
+	if b.withheld(msg) {
+		return nil
+	}
+	return b.Stack.Unicast(msg, to)
+}
+
+func (b *byzantineStack) Sign(msg []byte) ([]byte, error) {
+	sig, err := b.Stack.Sign(msg)
+	if err != nil || !b.faults.corruptSigs {
+		return sig, err
+	}
+	// keep the algorithm tag, the receivers fail to verify the rest
+	sig[len(sig)-1] ^= 0xff
+	b.faults.inject()
+	return sig, nil
+}
+
+func (b *byzantineStack) withheld(msg *rbftpb.ConsensusMessage) bool {
+	if b.faults.silent {
+		b.faults.inject()
+		return true
+	}
+	for _, typ := range b.faults.withhold {
+		if msg.Type.String() == typ {
+			b.faults.inject()
+			return true
+		}
+	}
+	return false
+}
+
+// replay records msg and broadcasts the oldest message not replayed yet once
+// enough newer messages have been sent.
+func (b *byzantineStack) replay(msg *rbftpb.ConsensusMessage) {
+	if !b.faults.replayOld {
+		return
+	}
+	b.lock.Lock()
+	b.sent = append(b.sent, msg)
+	if len(b.sent)-b.replayed <= replayLag {
+		b.lock.Unlock()
+		return
+	}
+	old := b.sent[b.replayed]
+	b.replayed++
+	b.lock.Unlock()
+
+	if err := b.Stack.Broadcast(old); err != nil {
+		b.logger.Warningf("Replay message failed: %s", err)
+		return
+	}
+	b.faults.inject()
+}
+
+// equivocation returns a copy of the pre-prepare msg for another batch
+// digest.
+func equivocation(msg *rbftpb.ConsensusMessage) (*rbftpb.ConsensusMessage, error) {
+	prePrepare := &rbftpb.PrePrepare{}
+	if err := prePrepare.Unmarshal(msg.Payload); err != nil {
+		return nil, fmt.Errorf("unmarshal pre-prepare: %w", err)
+	}
+	if prePrepare.BatchDigest == "" {
+		return nil, fmt.Errorf("pre-prepare of sequence %d has no batch digest", prePrepare.SequenceNumber)
+	}
+	digest := []byte(prePrepare.BatchDigest)
+	digest[0] ^= 0x01
+	prePrepare.BatchDigest = string(digest)
+	payload, err := prePrepare.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	forged := *msg
+	forged.Payload = payload
+	return &forged, nil
+}
+
+// forgeBlocks returns blocks with a random tx root in place of the real ones,
+// the forged blocks are well formed and only the headers of the other nodes
+// can tell them.
+func forgeBlocks(getBlock func(height uint64) (*pb.Block, error), faults *byzantineFaults) func(height uint64) (*pb.Block, error) {
+	return func(height uint64) (*pb.Block, error) {
+		block, err := getBlock(height)
+		if err != nil {
+			return nil, err
+		}
+		root := make([]byte, types.HashLength)
+		if _, err := rand.Read(root); err != nil {
+			return nil, err
+		}
+		header := *block.BlockHeader
+		header.TxRoot = types.NewHash(root)
+		forged := &pb.Block{
+			BlockHeader:  &header,
+			Transactions: block.Transactions,
+		}
+		forged.BlockHash = forged.Hash()
+		faults.inject()
+		return forged, nil
+	}
+}
+
+// tamperTxs returns the real blocks with a forged transaction added, the
+// headers and the block hashes match the ones of the other nodes and only
+// the tx root can tell the forged blocks.
+func tamperTxs(getBlock func(height uint64) (*pb.Block, error), faults *byzantineFaults) func(height uint64) (*pb.Block, error) {
+	return func(height uint64) (*pb.Block, error) {
+		block, err := getBlock(height)
+		if err != nil {
+			return nil, err
+		}
+		hash := make([]byte, types.HashLength)
+		if _, err := rand.Read(hash); err != nil {
+			return nil, err
+		}
+		txs := make([]*pb.Transaction, 0, len(block.Transactions)+1)
+		txs = append(txs, block.Transactions...)
+		txs = append(txs, &pb.Transaction{
+			From:            types.NewAddress(hash[:types.AddressLength]),
+			Nonce:           1,
+			TransactionHash: types.NewHash(hash),
+		})
+		faults.inject()
+		return &pb.Block{
+			BlockHeader:  block.BlockHeader,
+			Transactions: txs,
+			BlockHash:    block.BlockHash,
+		}, nil
+	}
+}
+
+// TestClusterByzantine runs a cluster of 4 nodes with one faulty node, the
+// honest nodes keep committing the same blocks while the faults are injected.
+func TestClusterByzantine(t *testing.T) {
+	tests := []struct {
+		name     string
+		faulty   uint64
+		faults   *byzantineFaults
+		vcPeriod uint64
+		blocks   int
+		lagging  uint64 // an honest node isolated while the blocks are committed
+	}{
+		{name: "equivocating primary", faulty: 1, faults: &byzantineFaults{equivocate: true}, blocks: 3},
+		{name: "silent primary", faulty: 1, faults: &byzantineFaults{silent: true}, blocks: 3},
+		{name: "withholding commits", faulty: 2, faults: &byzantineFaults{withhold: []string{"COMMIT"}}, blocks: 3},
+		{name: "corrupt signatures", faulty: 3, faults: &byzantineFaults{corruptSigs: true}, blocks: 3},
+		{name: "replaying old views", faulty: 2, faults: &byzantineFaults{replayOld: true}, vcPeriod: 1, blocks: 3 * checkpointPeriod},
+		{name: "forged blocks in state update", faulty: 2, faults: &byzantineFaults{forgeBlocks: true}, blocks: 2*checkpointPeriod + 5, lagging: 4},
+		{name: "tampered transactions in state update", faulty: 2, faults: &byzantineFaults{tamperTxs: true}, blocks: 2*checkpointPeriod + 5, lagging: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCluster(t, 4, withFaults(tt.faulty, tt.faults), withVCPeriod(tt.vcPeriod))
+			c.start()
+
+			honest := c.honest()
+			live := honest
+			if tt.lagging != 0 {
+				c.net.isolate(tt.lagging)
+				live = c.honest(tt.lagging)
+			}
+			c.commitBlocks(live[0], tt.blocks, live...)
+
+			if tt.lagging != 0 {
+				c.net.heal()
+				c.commitBlocks(live[0], checkpointPeriod, live...)
+				c.waitHeight(c.node(live[0]).ledger.height(), tt.lagging)
+			}
+			c.requireConsistent(honest...)
+			require.NotZero(t, tt.faults.injectedFaults(), "no fault has been injected")
+		})
+	}
+}
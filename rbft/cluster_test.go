@@ -0,0 +1,493 @@
+package rbft
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/log"
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftaudit"
+	"github.com/stretchr/testify/require"
+	"github.com/ultramesh/rbft"
+)
+
+// clusterOrderConfig shortens the timeouts, so view changes and state updates
+// happen within seconds. The vc_period is given by the cluster, the nodes
+// never restart so the consensus state is kept in memory.
+const clusterOrderConfig = `
+[rbft]
+set_size      = 25
+batch_size    = 500
+pool_size     = 50000
+tx_cache_size = 10000
+vc_period     = %d
+
+    [rbft.timeout]
+        sync_state        = "1s"
+        sync_interval     = "5s"
+        recovery          = "2s"
+        first_request     = "30s"
+        batch             = "0.1s"
+        request           = "2s"
+        null_request      = "3s"
+        viewchange        = "2s"
+        resend_viewchange = "3s"
+        clean_viewchange  = "60s"
+        update            = "2s"
+        set               = "0.05s"
+
+    [rbft.syncer]
+        sync_blocks = 5
+
+    [rbft.storage]
+        backend = "memory"
+`
+
+const clusterTimeout = 30 * time.Second
+
+const clusterStopTimeout = 2 * time.Second
+
+// clusterAuditFileSize is small, so a recorded node rotates its audit files.
+const clusterAuditFileSize = 64 << 10
+
+type testCluster struct {
+	t        *testing.T
+	net      *simNetwork
+	nodes    map[uint64]*clusterNode
+	faults   map[uint64]*byzantineFaults
+	audits   map[uint64]string // audit log directories of the recorded nodes
+	vcPeriod uint64
+	repoRoot string
+	vpInfos  map[uint64]*pb.VpInfo
+	priv     crypto.PrivateKey // key of the account sending the transactions
+	nonce    uint64
+	done     chan struct{}
+}
+
+type clusterOption func(*testCluster)
+
+// withFaults makes node id misbehave as the faults describe.
+func withFaults(id uint64, faults *byzantineFaults) clusterOption {
+	return func(c *testCluster) {
+		c.faults[id] = faults
+	}
+}
+
+// withAudit records the consensus messages of node id to dir.
+func withAudit(id uint64, dir string) clusterOption {
+	return func(c *testCluster) {
+		c.audits[id] = dir
+	}
+}
+
+// withVCPeriod makes the primary rotate every period checkpoints.
+func withVCPeriod(period uint64) clusterOption {
+	return func(c *testCluster) {
+		c.vcPeriod = period
+	}
+}
+
+type clusterNode struct {
+	id      uint64
+	order   *Node
+	ledger  *simLedger
+	peerMgr *simPeerManager
+
+	lock   sync.Mutex
+	events map[order.ConsensusEventType]int
+}
+
+// newTestCluster creates n nodes on a simulated network, they share the
+// genesis block at height 1.
+func newTestCluster(t *testing.T, n int, opts ...clusterOption) *testCluster {
+	c := &testCluster{
+		t:      t,
+		net:    newSimNetwork(simSeed(t)),
+		nodes:  make(map[uint64]*clusterNode),
+		faults: make(map[uint64]*byzantineFaults),
+		audits: make(map[uint64]string),
+		priv:   genPrivKey(),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	repoRoot := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(repoRoot, "order.toml"), []byte(fmt.Sprintf(clusterOrderConfig, c.vcPeriod)), 0644)
+	require.Nil(t, err)
+
+	privs := make(map[uint64]crypto.PrivateKey)
+	nodes := make(map[uint64]*pb.VpInfo)
+	for id := uint64(1); id <= uint64(n); id++ {
+		privs[id] = genPrivKey()
+		account, err := privs[id].PublicKey().Address()
+		require.Nil(t, err)
+		nodes[id] = &pb.VpInfo{Id: id, Account: account.String(), Pid: fmt.Sprintf("node%d", id)}
+	}
+	c.repoRoot = repoRoot
+	c.vpInfos = nodes
+
+	genesis := constructBlock("genesis", uint64(1))
+	for id := range nodes {
+		ledger := newSimLedger(genesis)
+		getBlock := ledger.getBlock
+		if faults, ok := c.faults[id]; ok && faults.forgeBlocks {
+			getBlock = forgeBlocks(ledger.getBlock, faults)
+		}
+		if faults, ok := c.faults[id]; ok && faults.tamperTxs {
+			getBlock = tamperTxs(ledger.getBlock, faults)
+		}
+		peerMgr := c.net.join(id, nodes, getBlock)
+		vpInfos := make(map[uint64]*pb.VpInfo, len(nodes))
+		for nodeID, vpInfo := range nodes {
+			vpInfos[nodeID] = vpInfo
+		}
+		config, err := order.GenerateConfig(
+			order.WithID(id),
+			order.WithIsNew(false),
+			order.WithRepoRoot(repoRoot),
+			order.WithStoragePath(filepath.Join(repoRoot, fmt.Sprintf("storage%d", id))),
+			order.WithPeerManager(peerMgr),
+			order.WithPrivKey(privs[id]),
+			order.WithLogger(log.NewWithModule(fmt.Sprintf("order%d", id))),
+			order.WithNodes(vpInfos),
+			order.WithApplied(genesis.Height()),
+			order.WithDigest(genesis.BlockHash.String()),
+			order.WithGetChainMetaFunc(ledger.chainMeta),
+			order.WithGetBlockByHeightFunc(ledger.getBlock),
+			order.WithGetAccountNonceFunc(ledger.nonce),
+		)
+		require.Nil(t, err)
+		node, err := newNode(config, c.setExternal(id))
+		require.Nil(t, err)
+		if dir, ok := c.audits[id]; ok {
+			// the nodes share the order.toml, the recorder is opened here
+			node.stack.audit, err = rbftaudit.NewRecorder(dir, clusterAuditFileSize, 1024)
+			require.Nil(t, err)
+		}
+		peerMgr.setStep(node.Step)
+		c.nodes[id] = &clusterNode{
+			id:      id,
+			order:   node,
+			ledger:  ledger,
+			peerMgr: peerMgr,
+			events:  make(map[order.ConsensusEventType]int),
+		}
+	}
+	t.Cleanup(c.stop)
+	return c
+}
+
+// setExternal gives the RBFT core of node id a byzantine stack if the node
+// is faulty.
+func (c *testCluster) setExternal(id uint64) func(rbftConfig *rbft.Config, s *Stack) {
+	return func(rbftConfig *rbft.Config, s *Stack) {
+		if faults, ok := c.faults[id]; ok {
+			rbftConfig.External = newByzantineStack(s, faults)
+			return
+		}
+		rbftConfig.External = s
+	}
+}
+
+// start starts all nodes and waits for the honest ones to finish the
+// recovery.
+func (c *testCluster) start() {
+	for _, node := range c.nodes {
+		go c.execute(node)
+		go c.watchEvents(node)
+		require.Nil(c.t, node.order.Start())
+	}
+	c.waitReady(c.honest()...)
+}
+
+// stop stops the nodes while their executors run, a lagging node never sees
+// its last blocks executed and gives up after clusterStopTimeout.
+func (c *testCluster) stop() {
+	for _, node := range c.nodes {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterStopTimeout)
+		node.order.GracefulStop(ctx)
+		cancel()
+	}
+	close(c.done)
+	c.net.close()
+}
+
+// execute plays the executor of the host, the blocks are appended to the
+// ledger and reported back to the order.
+func (c *testCluster) execute(node *clusterNode) {
+	for {
+		select {
+		case ev := <-node.order.Commit():
+			block := ev.Block
+			if !node.ledger.execute(block) {
+				continue
+			}
+			hashes := make([]*types.Hash, 0, len(block.Transactions))
+			for _, tx := range block.Transactions {
+				hashes = append(hashes, tx.TransactionHash)
+			}
+			node.order.ReportState(block.Height(), block.BlockHash, hashes)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// watchEvents counts the consensus events, the feed blocks the RBFT core
+// until every subscriber has received an event.
+func (c *testCluster) watchEvents(node *clusterNode) {
+	ch := make(chan order.ConsensusEvent, 16)
+	sub := node.order.SubscribeConsensusEvent(ch)
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case ev := <-ch:
+			node.lock.Lock()
+			node.events[ev.Type]++
+			node.lock.Unlock()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *testCluster) node(id uint64) *clusterNode {
+	node, ok := c.nodes[id]
+	require.True(c.t, ok, "node %d not found", id)
+	return node
+}
+
+// ids returns the sorted ids of the nodes except the excluded ones.
+func (c *testCluster) ids(excluded ...uint64) []uint64 {
+	ids := make([]uint64, 0, len(c.nodes))
+	for id := range c.nodes {
+		skip := false
+		for _, ex := range excluded {
+			if id == ex {
+				skip = true
+			}
+		}
+		if !skip {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// honest returns the sorted ids of the nodes without faults.
+func (c *testCluster) honest(excluded ...uint64) []uint64 {
+	for id := range c.faults {
+		excluded = append(excluded, id)
+	}
+	return c.ids(excluded...)
+}
+
+func (c *testCluster) newTx() *pb.Transaction {
+	c.nonce++
+	from, err := c.priv.PublicKey().Address()
+	require.Nil(c.t, err)
+	tx := &pb.Transaction{
+		From:      from,
+		To:        from,
+		Nonce:     c.nonce,
+		Timestamp: time.Now().UnixNano(),
+	}
+	tx.Signature, err = c.priv.Sign(tx.SignHash().Bytes())
+	require.Nil(c.t, err)
+	tx.TransactionHash = tx.Hash()
+	return tx
+}
+
+// sendTxs sends count transactions to node id, a transaction refused by a
+// node not ready is sent again.
+func (c *testCluster) sendTxs(id uint64, count int) {
+	node := c.node(id)
+	for i := 0; i < count; i++ {
+		tx := c.newTx()
+		require.Eventually(c.t, func() bool {
+			return node.order.Prepare(tx) == nil
+		}, clusterTimeout, 50*time.Millisecond, "node %d refuses transaction %d", id, tx.Nonce)
+	}
+}
+
+// commitBlocks sends transactions to node id one by one, every transaction
+// is waited to be committed in a block by the nodes ids.
+func (c *testCluster) commitBlocks(id uint64, count int, ids ...uint64) {
+	for i := 0; i < count; i++ {
+		height := c.node(id).ledger.height()
+		c.sendTxs(id, 1)
+		c.waitHeight(height+1, ids...)
+	}
+}
+
+func (c *testCluster) waitReady(ids ...uint64) {
+	require.Eventually(c.t, func() bool {
+		for _, id := range ids {
+			if c.node(id).order.Ready() != nil {
+				return false
+			}
+		}
+		return true
+	}, clusterTimeout, 100*time.Millisecond, "nodes %v are not ready", ids)
+}
+
+func (c *testCluster) waitHeight(height uint64, ids ...uint64) {
+	require.Eventually(c.t, func() bool {
+		for _, id := range ids {
+			if c.node(id).ledger.height() < height {
+				return false
+			}
+		}
+		return true
+	}, clusterTimeout, 50*time.Millisecond, "nodes %v don't reach height %d", ids, height)
+}
+
+func (c *testCluster) waitEvent(id uint64, typ order.ConsensusEventType) {
+	node := c.node(id)
+	require.Eventually(c.t, func() bool {
+		node.lock.Lock()
+		defer node.lock.Unlock()
+		return node.events[typ] > 0
+	}, clusterTimeout, 50*time.Millisecond, "node %d doesn't receive %s", id, typ)
+}
+
+// requireConsistent checks the nodes ids have the same blocks up to the
+// lowest height among them.
+func (c *testCluster) requireConsistent(ids ...uint64) {
+	lowest := c.node(ids[0]).ledger.height()
+	for _, id := range ids[1:] {
+		if height := c.node(id).ledger.height(); height < lowest {
+			lowest = height
+		}
+	}
+	for height := uint64(2); height <= lowest; height++ {
+		expected, err := c.node(ids[0]).ledger.getBlock(height)
+		require.Nil(c.t, err)
+		for _, id := range ids[1:] {
+			block, err := c.node(id).ledger.getBlock(height)
+			require.Nil(c.t, err)
+			require.Equal(c.t, expected.BlockHash.String(), block.BlockHash.String(),
+				"block %d of node %d differs from node %d", height, id, ids[0])
+		}
+	}
+}
+
+func TestClusterCommit(t *testing.T) {
+	tests := []struct {
+		name        string
+		latency     time.Duration
+		dropRate    float64
+		reorderRate float64
+	}{
+		{name: "reliable"},
+		{name: "latency and reordering", latency: 10 * time.Millisecond, reorderRate: 0.2},
+		{name: "lossy", latency: 5 * time.Millisecond, dropRate: 0.02},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCluster(t, 4)
+			c.start()
+			c.net.setLatency(tt.latency)
+			c.net.setDropRate(tt.dropRate)
+			c.net.setReorderRate(tt.reorderRate)
+
+			c.commitBlocks(1, 3, c.ids()...)
+			c.sendTxs(2, 20)
+			c.waitHeight(c.node(2).ledger.height()+1, c.ids()...)
+			c.requireConsistent(c.ids()...)
+
+			status := c.node(1).order.Status()
+			require.Equal(t, c.node(1).ledger.height(), status.LastCommitted)
+			require.Equal(t, uint64(3), status.ConnectedPeers)
+		})
+	}
+}
+
+func TestClusterViewChange(t *testing.T) {
+	c := newTestCluster(t, 4)
+	c.start()
+	c.commitBlocks(1, 1, c.ids()...)
+	require.Equal(t, uint64(1), c.node(2).order.Status().Primary)
+
+	// the replicas don't hear from the primary and elect another one
+	c.net.isolate(1)
+	replicas := c.ids(1)
+	height := c.node(2).ledger.height()
+	c.sendTxs(2, 1)
+	c.waitHeight(height+1, replicas...)
+	c.waitEvent(2, order.ViewChanged)
+	for _, id := range replicas {
+		status := c.node(id).order.Status()
+		require.NotEqual(t, uint64(0), status.View)
+		require.NotEqual(t, uint64(1), status.Primary)
+	}
+	require.Equal(t, height, c.node(1).ledger.height())
+
+	// the old primary catches up after the partition is healed
+	c.net.heal()
+	c.commitBlocks(2, 1, replicas...)
+	c.waitReady(1)
+	c.waitHeight(c.node(2).ledger.height(), 1)
+	c.requireConsistent(c.ids()...)
+}
+
+func TestClusterRemoveNode(t *testing.T) {
+	delay := removeDelay
+	removeDelay = 100 * time.Millisecond
+	defer func() {
+		removeDelay = delay
+	}()
+
+	c := newTestCluster(t, 4)
+	c.start()
+	c.commitBlocks(1, 1, c.ids()...)
+
+	require.Nil(t, c.node(1).order.DelNode(4))
+	c.waitEvent(4, order.NodeRemoved)
+	remaining := c.ids(4)
+	for _, id := range remaining {
+		id := id
+		require.Eventually(t, func() bool {
+			_, ok := c.node(id).peerMgr.Peers()[4]
+			return !ok
+		}, clusterTimeout, 50*time.Millisecond, "node 4 is still a peer of node %d", id)
+	}
+
+	height := c.node(4).ledger.height()
+	c.waitReady(remaining...)
+	c.commitBlocks(1, 2, remaining...)
+	c.requireConsistent(remaining...)
+	require.Equal(t, height, c.node(4).ledger.height())
+}
+
+func TestClusterRecoverLaggingNode(t *testing.T) {
+	c := newTestCluster(t, 4)
+	c.start()
+
+	// node 4 misses more than two checkpoints
+	c.net.isolate(4)
+	others := c.ids(4)
+	c.commitBlocks(1, 2*checkpointPeriod+5, others...)
+	require.Equal(t, uint64(1), c.node(4).ledger.height())
+
+	// it fetches the missing blocks from the others by state update
+	c.net.heal()
+	c.commitBlocks(1, checkpointPeriod, others...)
+	c.waitHeight(c.node(1).ledger.height(), 4)
+	c.waitReady(4)
+	c.requireConsistent(c.ids()...)
+}
@@ -1,77 +1,40 @@
-package main
+package rbft
 
 import (
 	"sort"
-	"time"
 
 	"github.com/meshplus/bitxhub-model/pb"
 	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
 	"github.com/sirupsen/logrus"
 	"github.com/ultramesh/rbft"
 	"github.com/ultramesh/rbft/mempool"
 	"github.com/ultramesh/rbft/rbftpb"
 )
 
-type RBFTConfig struct {
-	Rbft RBFT
-}
-
-type RBFT struct {
-	SetSize          int           `mapstructure:"set_size"`
-	BatchSize        uint64        `mapstructure:"batch_size"`
-	PoolSize         uint64        `mapstructure:"pool_size"`
-	CheckInterval    time.Duration `mapstructure:"check_interval"`
-	ToleranceTime    time.Duration `mapstructure:"tolerance_time"`
-	BatchMemLimit    bool          `mapstructure:"batch_mem_limit"`
-	BatchMaxMem      uint64        `mapstructure:"batch_max_mem"`
-	VCPeriod         uint64        `mapstructure:"vc_period"`
-	GetBlockByHeight func(height uint64) (*pb.Block, error)
-	Timeout
-}
-
-type Timeout struct {
-	SyncState        time.Duration `mapstructure:"sync_state"`
-	SyncInterval     time.Duration `mapstructure:"sync_interval"`
-	Recovery         time.Duration `mapstructure:"recovery"`
-	FirstRequest     time.Duration `mapstructure:"first_request"`
-	Batch            time.Duration `mapstructure:"batch"`
-	Request          time.Duration `mapstructure:"request"`
-	NullRequest      time.Duration `mapstructure:"null_request"`
-	ViewChange       time.Duration `mapstructure:"viewchange"`
-	ResendViewChange time.Duration `mapstructure:"resend_viewchange"`
-	CleanViewChange  time.Duration `mapstructure:"clean_viewchange"`
-	Update           time.Duration `mapstructure:"update"`
-	Set              time.Duration `mapstructure:"set"`
-}
+// checkpointPeriod is the number of blocks between two checkpoints, the
+// watermarks move by it and span logMultiplier periods.
+const (
+	checkpointPeriod = 10
+	logMultiplier    = 4
+)
 
+// defaultRbftConfig returns the values which are not read from order.toml,
+// defaults of the others are given by rbftconfig.DefaultConfig.
 func defaultRbftConfig() rbft.Config {
 	return rbft.Config{
-		SetSize:                 1000,
-		IsNew:                   false,
-		K:                       10,
-		LogMultiplier:           4,
-		VCPeriod:                0,
-		CheckPoolTimeout:        100 * time.Second,
-		SyncStateTimeout:        3 * time.Second,
-		SyncStateRestartTimeout: 40 * time.Second,
-		RecoveryTimeout:         10 * time.Second,
-		FirstRequestTimeout:     30 * time.Second,
-		BatchTimeout:            200 * time.Millisecond,
-		RequestTimeout:          6 * time.Second,
-		NullRequestTimeout:      9 * time.Second,
-		NewViewTimeout:          1 * time.Second,
-		VcResendTimeout:         8 * time.Second,
-		CleanVCTimeout:          60 * time.Second,
-		UpdateTimeout:           4 * time.Second,
-		SetTimeout:              100 * time.Millisecond,
+		IsNew:         false,
+		K:             checkpointPeriod,
+		LogMultiplier: logMultiplier,
 	}
 }
 
 func generateRbftConfig(repoRoot string, config *order.Config) (rbft.Config, error) {
-	readConfig, err := readConfig(repoRoot)
+	readConfig, err := rbftconfig.Load(repoRoot)
 	if err != nil {
-		return rbft.Config{}, nil
+		return rbft.Config{}, err
 	}
+	config.Logger.Infof("RBFT effective config:\n%s", readConfig)
 
 	defaultConfig := defaultRbftConfig()
 	defaultConfig.ID = config.ID
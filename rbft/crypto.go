@@ -0,0 +1,234 @@
+package rbft
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/crypto/asym"
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/x509"
+)
+
+// SignAlgorithm is carried in the first byte of every consensus message
+// signature, so the receiver knows how to verify the rest of it.
+type SignAlgorithm byte
+
+const (
+	SignSecp256k1 SignAlgorithm = iota + 1
+	SignECDSAP256
+	SignECDSAP384
+	SignECDSAP521
+	SignEd25519
+	SignSM2
+)
+
+var signAlgorithms = map[string]SignAlgorithm{
+	rbftconfig.Secp256k1: SignSecp256k1,
+	rbftconfig.ECDSAP256: SignECDSAP256,
+	rbftconfig.ECDSAP384: SignECDSAP384,
+	rbftconfig.ECDSAP521: SignECDSAP521,
+	rbftconfig.Ed25519:   SignEd25519,
+	rbftconfig.SM2:       SignSM2,
+}
+
+// kitKeyTypes are the algorithms supported by bitxhub-kit, they sign with the
+// node key and verify against the account of the peer.
+var kitKeyTypes = map[SignAlgorithm]crypto.KeyType{
+	SignSecp256k1: crypto.Secp256k1,
+	SignECDSAP256: crypto.ECDSA_P256,
+	SignECDSAP384: crypto.ECDSA_P384,
+	SignECDSAP521: crypto.ECDSA_P521,
+}
+
+// signScheme signs consensus messages with the local key and verifies the
+// signatures of other nodes, signatures here don't contain the algorithm tag.
+type signScheme interface {
+	sign(msg []byte) ([]byte, error)
+	verify(peer *pb.VpInfo, sig []byte, msg []byte) error
+}
+
+type kitScheme struct {
+	keyType crypto.KeyType
+	priv    crypto.PrivateKey
+}
+
+func (k *kitScheme) sign(msg []byte) ([]byte, error) {
+	h := sha256.Sum256(msg)
+	return k.priv.Sign(h[:])
+}
+
+func (k *kitScheme) verify(peer *pb.VpInfo, sig []byte, msg []byte) error {
+	h := sha256.Sum256(msg)
+	addr := types.NewAddressByStr(peer.Account)
+	ret, err := asym.Verify(k.keyType, sig, h[:], *addr)
+	if err != nil {
+		return err
+	}
+	if !ret {
+		return fmt.Errorf("verify error")
+	}
+	return nil
+}
+
+type ed25519Scheme struct {
+	priv    ed25519.PrivateKey
+	pubKeys map[uint64]ed25519.PublicKey
+}
+
+func (e *ed25519Scheme) sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(e.priv, msg), nil
+}
+
+func (e *ed25519Scheme) verify(peer *pb.VpInfo, sig []byte, msg []byte) error {
+	pubKey, ok := e.pubKeys[peer.Id]
+	if !ok {
+		return fmt.Errorf("ed25519 public key of node %d is not configured", peer.Id)
+	}
+	if !ed25519.Verify(pubKey, msg, sig) {
+		return fmt.Errorf("verify error")
+	}
+	return nil
+}
+
+type sm2Scheme struct {
+	priv    *sm2.PrivateKey
+	pubKeys map[uint64]*sm2.PublicKey
+}
+
+func (s *sm2Scheme) sign(msg []byte) ([]byte, error) {
+	return s.priv.Sign(rand.Reader, msg, nil)
+}
+
+func (s *sm2Scheme) verify(peer *pb.VpInfo, sig []byte, msg []byte) error {
+	pubKey, ok := s.pubKeys[peer.Id]
+	if !ok {
+		return fmt.Errorf("sm2 public key of node %d is not configured", peer.Id)
+	}
+	if !pubKey.Verify(msg, sig) {
+		return fmt.Errorf("verify error")
+	}
+	return nil
+}
+
+// initSignSchemes selects the algorithm used to sign and verify consensus
+// messages by the crypto config, the signatures of any other algorithm are
+// refused. Ed25519 and SM2 use a dedicated consensus key, as the node key of
+// bitxhub is always a bitxhub-kit key.
+func (s *Stack) initSignSchemes(repoRoot string, config *rbftconfig.CryptoConfig) error {
+	name := strings.ToLower(config.Algorithm)
+	if name == "" {
+		name = rbftconfig.Secp256k1
+	}
+	alg, ok := signAlgorithms[name]
+	if !ok {
+		return fmt.Errorf("unsupported sign algorithm: %s", config.Algorithm)
+	}
+
+	if keyType, ok := kitKeyTypes[alg]; ok {
+		if s.priv.Type() != keyType {
+			return fmt.Errorf("sign algorithm %s doesn't match the node key type %d", name, s.priv.Type())
+		}
+		s.signAlgorithm = alg
+		s.scheme = &kitScheme{
+			keyType: keyType,
+			priv:    s.priv,
+		}
+		return nil
+	}
+
+	if config.KeyFile == "" {
+		return fmt.Errorf("key_file is required by sign algorithm %s", name)
+	}
+	keyPath := config.KeyFile
+	if !filepath.IsAbs(keyPath) {
+		keyPath = filepath.Join(repoRoot, keyPath)
+	}
+	keyData, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("read consensus key: %w", err)
+	}
+	keyHex := strings.TrimSpace(string(keyData))
+
+	pubKeys := make(map[uint64]string, len(config.PublicKeys))
+	for idStr, pubKey := range config.PublicKeys {
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			return fmt.Errorf("wrong node id %s in public_keys: %w", idStr, err)
+		}
+		pubKeys[id] = pubKey
+	}
+
+	var scheme signScheme
+	switch alg {
+	case SignEd25519:
+		scheme, err = newEd25519Scheme(keyHex, pubKeys)
+	case SignSM2:
+		scheme, err = newSM2Scheme(keyHex, pubKeys)
+	}
+	if err != nil {
+		return err
+	}
+	s.signAlgorithm = alg
+	s.scheme = scheme
+	return nil
+}
+
+func newEd25519Scheme(keyHex string, pubKeys map[uint64]string) (*ed25519Scheme, error) {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("decode ed25519 key: %w", err)
+	}
+	var priv ed25519.PrivateKey
+	switch len(key) {
+	case ed25519.SeedSize:
+		priv = ed25519.NewKeyFromSeed(key)
+	case ed25519.PrivateKeySize:
+		priv = key
+	default:
+		return nil, fmt.Errorf("wrong ed25519 key size %d", len(key))
+	}
+
+	scheme := &ed25519Scheme{
+		priv:    priv,
+		pubKeys: make(map[uint64]ed25519.PublicKey, len(pubKeys)),
+	}
+	for id, pubHex := range pubKeys {
+		pubKey, err := hex.DecodeString(pubHex)
+		if err != nil || len(pubKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("wrong ed25519 public key of node %d", id)
+		}
+		scheme.pubKeys[id] = pubKey
+	}
+	return scheme, nil
+}
+
+func newSM2Scheme(keyHex string, pubKeys map[uint64]string) (*sm2Scheme, error) {
+	priv, err := x509.ReadPrivateKeyFromHex(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("decode sm2 key: %w", err)
+	}
+
+	scheme := &sm2Scheme{
+		priv:    priv,
+		pubKeys: make(map[uint64]*sm2.PublicKey, len(pubKeys)),
+	}
+	for id, pubHex := range pubKeys {
+		pubKey, err := x509.ReadPublicKeyFromHex(pubHex)
+		if err != nil {
+			return nil, fmt.Errorf("wrong sm2 public key of node %d: %w", id, err)
+		}
+		scheme.pubKeys[id] = pubKey
+	}
+	return scheme, nil
+}
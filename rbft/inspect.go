@@ -0,0 +1,34 @@
+package rbft
+
+import (
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
+	"github.com/ultramesh/rbft/rbftpb"
+)
+
+// stateTypes create the rbftpb message each kind of the consensus state is
+// encoded by.
+var stateTypes = map[string]func() proto.Message{
+	rbftstorage.KindQSet:    func() proto.Message { return &rbftpb.PrePrepare{} },
+	rbftstorage.KindPSet:    func() proto.Message { return &rbftpb.Pset{} },
+	rbftstorage.KindCSet:    func() proto.Message { return &rbftpb.Cset{} },
+	rbftstorage.KindNewView: func() proto.Message { return &rbftpb.NewView{} },
+	rbftstorage.KindBatch:   func() proto.Message { return &rbftpb.RequestBatch{} },
+}
+
+// DecodeState is looked up by bitxhub order inspect to decode the consensus
+// state with the rbftpb types, a kind without a type is left to the generic
+// decoder.
+func DecodeState(kind, key string, value []byte) (interface{}, error) {
+	newMsg, ok := stateTypes[kind]
+	if !ok {
+		return nil, nil
+	}
+	msg := newMsg()
+	if err := proto.Unmarshal(value, msg); err != nil {
+		return nil, fmt.Errorf("decode %s as %s: %w", key, proto.MessageName(msg), err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,139 @@
+package rbft
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	viewGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "view",
+		Help:      "The current view of the node",
+	})
+	primaryGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "primary_id",
+		Help:      "The primary id of the current view",
+	})
+	statusGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "status",
+		Help:      "The current status of the node, only the current status is set to 1",
+	}, []string{"status"})
+	txCacheDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "tx_cache_depth",
+		Help:      "The number of transactions waiting in the transaction cache",
+	})
+	txCacheCapacity = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "tx_cache_capacity",
+		Help:      "The number of transactions the transaction cache could hold",
+	})
+	txSetDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "tx_set_depth",
+		Help:      "The number of transactions collected for the next broadcast",
+	})
+	prepareRejectedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "prepare_rejected_total",
+		Help:      "The number of transactions rejected by Prepare",
+	}, []string{"reason"})
+	readyCBacklog = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "ready_backlog",
+		Help:      "The number of executed batches waiting to be built into blocks",
+	})
+	blockCBacklog = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "block_backlog",
+		Help:      "The number of blocks waiting to be committed by the executor",
+	})
+	sentMessageCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "sent_messages_total",
+		Help:      "The number of consensus messages sent",
+	}, []string{"method", "type"})
+	sentMessageBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "sent_message_bytes_total",
+		Help:      "The total size of consensus messages sent",
+	}, []string{"method", "type"})
+	signDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "sign_duration_seconds",
+		Help:      "The latency of consensus message signing",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 2, 14),
+	})
+	verifyDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "verify_duration_seconds",
+		Help:      "The latency of consensus message verification",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 2, 14),
+	})
+	verifyCacheCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "verify_cache_total",
+		Help:      "The number of signature verification cache lookups, by hit or miss",
+	}, []string{"result"})
+	verifyBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "verify_batch_size",
+		Help:      "The number of signatures verified by a batch",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+	stateUpdateDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "state_update_duration_seconds",
+		Help:      "The total latency of state update",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	})
+	stateUpdateBlocks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "state_update_blocks_total",
+		Help:      "The number of blocks fetched by state update",
+	})
+	consensusEventsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "bitxhub",
+		Subsystem: "rbft",
+		Name:      "consensus_events_dropped_total",
+		Help:      "The number of consensus events dropped for slow subscribers",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(viewGauge)
+	prometheus.MustRegister(primaryGauge)
+	prometheus.MustRegister(statusGauge)
+	prometheus.MustRegister(txCacheDepth)
+	prometheus.MustRegister(txCacheCapacity)
+	prometheus.MustRegister(txSetDepth)
+	prometheus.MustRegister(prepareRejectedCounter)
+	prometheus.MustRegister(readyCBacklog)
+	prometheus.MustRegister(blockCBacklog)
+	prometheus.MustRegister(sentMessageCounter)
+	prometheus.MustRegister(sentMessageBytes)
+	prometheus.MustRegister(signDuration)
+	prometheus.MustRegister(verifyDuration)
+	prometheus.MustRegister(verifyCacheCounter)
+	prometheus.MustRegister(verifyBatchSize)
+	prometheus.MustRegister(stateUpdateDuration)
+	prometheus.MustRegister(stateUpdateBlocks)
+	prometheus.MustRegister(consensusEventsDropped)
+}
@@ -1,22 +1,29 @@
-package main
+package rbft
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/gogo/protobuf/proto"
 	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
 	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftaudit"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 	"github.com/ultramesh/rbft"
 	"github.com/ultramesh/rbft/rbftpb"
 )
 
+const metricsReportInterval = time.Second
+
 type Node struct {
 	id     uint64
 	n      rbft.Node
@@ -24,8 +31,34 @@ type Node struct {
 	blockC chan *pb.CommitEvent
 	logger logrus.FieldLogger
 
-	ctx     context.Context
-	txCache *TxCache
+	ctx           context.Context
+	txCache       *TxCache
+	pool          *poolCounter
+	stopOnce      sync.Once
+	stopReport    *order.StopReport
+	stopping      uint32        // set once the stop begins, accessed atomically
+	quitC         chan struct{} // stops the loop of Start
+	loopDone      chan struct{}
+	held          *ready   // the batch the loop failed to hand over when it quit
+	abandoned     []*ready // the batches the stop failed to hand over
+	replayed      uint64   // height of the last block replayed after the restart
+	unappliedTo   uint64   // height of the last unapplied block in the storage, accessed atomically
+	lastDelivered uint64   // height of the last block handed to the host, accessed atomically
+	lastCommitted uint64   // height of the last executed block, accessed atomically
+	replayFilter  *order.ReplayFilter
+
+	pendingTxs      *rbftstorage.PendingTxs // accepted from the clients and not committed yet
+	getAccountNonce func(address *types.Address) uint64
+
+	repoRoot     string
+	config       *rbftconfig.Config // running order config, changed by ReConfig
+	reconfigLock sync.Mutex
+}
+
+// the engine is linked into bitxhub by the rbft build tag, or into the host
+// by the plugin in rbft/plugin, it is selected by order.engine = "rbft"
+func init() {
+	order.Register("rbft", NewNode)
 }
 
 func NewNode(opts ...order.Option) (order.Order, error) {
@@ -34,10 +67,33 @@ func NewNode(opts ...order.Option) (order.Order, error) {
 		return nil, fmt.Errorf("generate config: %w", err)
 	}
 
-	store, err := NewStorage(config.StoragePath)
+	node, err := newNode(config, func(rbftConfig *rbft.Config, s *Stack) {
+		rbftConfig.External = s
+	})
 	if err != nil {
 		return nil, err
 	}
+	return node, nil
+}
+
+// newNode creates the node, setExternal hands the stack to the RBFT core as
+// its external services. The fault injection tests wrap the stack there.
+func newNode(config *order.Config, setExternal func(rbftConfig *rbft.Config, s *Stack)) (*Node, error) {
+	orderConfig, err := rbftconfig.Load(config.RepoRoot)
+	if err != nil {
+		return nil, err
+	}
+	store, err := rbftstorage.Open(orderConfig.Rbft.StorageConfig.Backend, config.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	if orderConfig.Rbft.StorageConfig.Backend == rbftconfig.StorageMemory {
+		config.Logger.Warning("Consensus state is kept in memory, it is lost on restart")
+	}
+	if err := store.Verify(); err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("refuse to start on consensus storage %s, inspect and repair it with bitxhub order inspect: %w", config.StoragePath, err)
+	}
 	rbftConfig, err := generateRbftConfig(config.RepoRoot, config)
 	if err != nil {
 		return nil, err
@@ -49,54 +105,111 @@ func NewNode(opts ...order.Option) (order.Order, error) {
 	if err != nil {
 		return nil, err
 	}
-	rbftConfig.External = s
+	if err := s.initSignSchemes(config.RepoRoot, &orderConfig.Rbft.CryptoConfig); err != nil {
+		return nil, fmt.Errorf("init sign schemes: %w", err)
+	}
+	if err := s.initVerifyCache(&orderConfig.Rbft.CryptoConfig); err != nil {
+		return nil, err
+	}
+	s.setSyncCritical(orderConfig.Rbft.StorageConfig.SyncCritical)
+	if audit := orderConfig.Rbft.AuditConfig; audit.Enable {
+		dir := audit.Dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(config.RepoRoot, dir)
+		}
+		if s.audit, err = rbftaudit.NewRecorder(dir, audit.MaxFileSizeMB<<20, audit.MaxFiles); err != nil {
+			return nil, fmt.Errorf("open consensus audit log: %w", err)
+		}
+		config.Logger.Infof("Record consensus messages to %s", dir)
+	}
+	setExternal(&rbftConfig, s)
 
 	n, err := rbft.NewNode(rbftConfig)
 	if err != nil {
 		return nil, err
 	}
 	s.applyConfChange = n.ApplyConfChange
+	s.stateUpdated = n.ReportStateUpdated
+	s.nodeView = func() uint64 {
+		return n.Status().View
+	}
+	s.syncBlocks = orderConfig.Rbft.SyncerConfig.SyncBlocks
 
 	n.ReportExecuted(&rbftpb.ServiceState{
 		Applied: config.Applied,
 		Digest:  config.Digest,
 	})
-	return &Node{
-		id:      rbftConfig.ID,
-		n:       n,
-		logger:  config.Logger,
-		stack:   s,
-		blockC:  blockC,
-		ctx:     ctx,
-		txCache: newTxCache(0, 0, config.Logger),
-	}, nil
+	node := &Node{
+		id:       rbftConfig.ID,
+		n:        n,
+		logger:   config.Logger,
+		stack:    s,
+		blockC:   blockC,
+		ctx:      ctx,
+		txCache:  newTxCache(orderConfig.Rbft.Timeout.Set, uint64(orderConfig.Rbft.SetSize), orderConfig.Rbft.TxCacheSize, config.Logger),
+		pool:     newPoolCounter(),
+		repoRoot: config.RepoRoot,
+		config:   orderConfig,
+
+		lastCommitted: config.Applied,
+		replayFilter:  config.ReplayFilter,
+
+		pendingTxs:      rbftstorage.NewPendingTxs(),
+		getAccountNonce: config.GetAccountNonce,
+	}
+	s.stopNode = node.Stop
+	return node, nil
 }
 
 func (n *Node) Start() error {
 	go n.txCache.listenEvent()
+	n.restorePendingTxs()
+	replay := n.loadUnapplied()
+	n.quitC = make(chan struct{})
+	n.loopDone = make(chan struct{})
 	go func() {
+		defer close(n.loopDone)
+		for _, ev := range replay {
+			select {
+			case n.blockC <- ev:
+				atomic.StoreUint64(&n.lastDelivered, ev.Block.Height())
+			case <-n.quitC:
+				return
+			}
+		}
+		ticker := time.NewTicker(metricsReportInterval)
+		defer ticker.Stop()
+		saveTicker := time.NewTicker(pendingTxsSaveInterval)
+		defer saveTicker.Stop()
 		for {
 			select {
+			case <-ticker.C:
+				n.reportMetrics()
+
+			case <-saveTicker.C:
+				n.savePendingTxs(false)
+
 			case r := <-n.stack.readyC:
-				block := &pb.Block{
-					BlockHeader: &pb.BlockHeader{
-						Version:   []byte("1.0.0"),
-						Number:    r.height,
-						Timestamp: time.Now().UnixNano(),
-					},
-					Transactions: r.txs,
+				if n.isReplayed(r) {
+					continue
 				}
-				commitEvent := &pb.CommitEvent{
-					Block:     block,
-					LocalList: r.localList,
+				select {
+				case n.blockC <- r.commitEvent():
+					atomic.StoreUint64(&n.lastDelivered, r.height)
+				case <-n.quitC:
+					n.held = r
+					return
 				}
-				n.blockC <- commitEvent
 
 			case txSet := <-n.txCache.txSetC:
-				_ = n.n.Propose(txSet)
+				n.propose(txSet)
+
+			case <-n.quitC:
+				return
 
 			case <-n.ctx.Done():
-				n.Stop()
+				// Stop waits for this loop to quit
+				go n.Stop()
 				return
 			}
 		}
@@ -106,24 +219,51 @@ func (n *Node) Start() error {
 	return n.n.Start()
 }
 
-func (n *Node) Stop() {
-	if n.txCache.close != nil {
-		close(n.txCache.close)
-	}
-	n.n.Stop()
-}
-
+// Prepare never blocks, it returns an error wrapping order.ErrBusy if the
+// transaction can't be accepted for now, the client may send it again later,
+// and an error wrapping order.ErrReplayed if the transaction was executed.
 func (n *Node) Prepare(tx *pb.Transaction) error {
-	if err := n.Ready(); err != nil {
+	if n.isStopping() {
+		prepareRejectedCounter.WithLabelValues("stopping").Inc()
+		return fmt.Errorf("order is stopping: %w", order.ErrBusy)
+	}
+	// the pool forgets a transaction once it is committed, the filter keeps
+	// rejecting it after that
+	if err := n.replayFilter.Check(tx.TransactionHash); err != nil {
+		prepareRejectedCounter.WithLabelValues("replayed").Inc()
 		return err
 	}
-	if n.txCache.IsFull() && n.n.Status().Status == rbft.PoolFull {
-		return errors.New("transaction cache are full, we will drop this transaction")
+	status := n.n.Status().Status
+	if status == rbft.PoolFull {
+		prepareRejectedCounter.WithLabelValues("pool_full").Inc()
+		return fmt.Errorf("transaction pool is full: %w", order.ErrBusy)
+	}
+	if status != rbft.Normal {
+		prepareRejectedCounter.WithLabelValues("not_ready").Inc()
+		return fmt.Errorf("%s: %w", status2String(status), order.ErrBusy)
+	}
+	// tracked before it is handed over, it may be committed at once
+	n.pendingTxs.Add(tx)
+	if !n.txCache.push(tx) {
+		n.pendingTxs.Remove(txHashes([]*pb.Transaction{tx})...)
+		prepareRejectedCounter.WithLabelValues("cache_full").Inc()
+		return fmt.Errorf("transaction cache is full: %w", order.ErrBusy)
 	}
-	n.txCache.recvTxC <- tx
 	return nil
 }
 
+// propose sends a transaction set from the cache to the pool of the RBFT core.
+func (n *Node) propose(txs []*pb.Transaction) {
+	if err := n.n.Propose(txs); err != nil {
+		n.logger.Warningf("Propose transactions failed: %s", err)
+		n.stack.txStatus.Reject(err.Error(), txHashes(txs)...)
+		n.pendingTxs.Remove(txHashes(txs)...)
+		return
+	}
+	n.stack.txStatus.Set(txstatus.Pooled, 0, txHashes(txs)...)
+	n.pool.add(txs)
+}
+
 func (n *Node) Commit() chan *pb.CommitEvent {
 	return n.blockC
 }
@@ -131,9 +271,12 @@ func (n *Node) Commit() chan *pb.CommitEvent {
 func (n *Node) Step(msg []byte) error {
 	m := &rbftpb.ConsensusMessage{}
 	if err := proto.Unmarshal(msg, m); err != nil {
+		n.stack.record(rbftaudit.Inbound, 0, msg)
 		return err
 	}
+	n.stack.record(rbftaudit.Inbound, m.From, msg)
 
+	n.stack.verifyBundle(m)
 	n.n.Step(m)
 
 	return nil
@@ -143,7 +286,7 @@ func (n *Node) Ready() error {
 	status := n.n.Status().Status
 	isNormal := status == rbft.Normal
 	if !isNormal {
-		return fmt.Errorf("%s", status2String(status))
+		return fmt.Errorf("%s: %w", status2String(status), order.ErrBusy)
 	}
 	return nil
 }
@@ -164,18 +307,55 @@ func (n *Node) DelNode(delID uint64) error {
 	return nil
 }
 
+func (n *Node) AddNode(vpInfo *pb.VpInfo) error {
+	vpInfoBytes, err := vpInfo.Marshal()
+	if err != nil {
+		return err
+	}
+	peer := &rbftpb.Peer{
+		Id:      vpInfo.Id,
+		Context: vpInfoBytes,
+	}
+	peerBytes, err := peer.Marshal()
+	if err != nil {
+		return err
+	}
+	cc := &rbftpb.ConfChange{
+		NodeID:  vpInfo.Id,
+		Type:    rbftpb.ConfChangeType_ConfChangeAddNode,
+		Context: peerBytes,
+	}
+	if err := n.n.ProposeConfChange(cc); err != nil {
+		n.logger.Errorf("ProposeConfChange for add vp failed, err: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (n *Node) SubscribeConsensusEvent(ch chan<- order.ConsensusEvent) event.Subscription {
+	return n.stack.consensusFeed.Subscribe(ch)
+}
+
 func (n *Node) ReportState(height uint64, blockHash *types.Hash, txHashList []*types.Hash) {
-	if n.stack.stateUpdating && n.stack.stateUpdateHeight != height {
-		return
+	n.pool.remove(txHashList)
+	committed := make([]string, 0, len(txHashList))
+	for _, hash := range txHashList {
+		if hash != nil {
+			committed = append(committed, hash.String())
+		}
 	}
+	n.pendingTxs.Remove(committed...)
+	n.pruneUnapplied(height)
 
-	if n.stack.stateUpdating {
-		state := &rbftpb.ServiceState{
-			Applied: height,
-			Digest:  blockHash.String(),
+	// a state update is reported to the core once its target is executed
+	if updating, reached := n.stack.stateUpdate.report(height); updating {
+		if reached {
+			n.n.ReportStateUpdated(&rbftpb.ServiceState{
+				Applied: height,
+				Digest:  blockHash.String(),
+			})
+			atomic.StoreUint64(&n.lastCommitted, height)
 		}
-		n.n.ReportStateUpdated(state)
-		n.stack.stateUpdating = false
 		return
 	}
 
@@ -189,28 +369,65 @@ func (n *Node) ReportState(height uint64, blockHash *types.Hash, txHashList []*t
 		Digest:  blockHash.String(),
 	}
 	n.n.ReportExecuted(state)
+	atomic.StoreUint64(&n.lastCommitted, height)
+}
+
+// Status reports the view and the primary of the RBFT core. The watermarks
+// are the ones of the stable checkpoint the core has persisted, the high one
+// is logMultiplier checkpoint periods above the low one. The pending
+// transactions are the cached ones and the ones the pool may batch, the pool
+// parks the others by the pending nonce of their account.
+func (n *Node) Status() *order.OrderStatus {
+	status := n.n.Status()
+	primary := n.primary(status.View)
+	role := order.RoleReplica
+	if primary == n.id {
+		role = order.RolePrimary
+	}
+	low := atomic.LoadUint64(&n.stack.lowWatermark)
+	ready, parked := n.pool.counts(n.n.GetPendingNonceByAccount)
+
+	return &order.OrderStatus{
+		Engine:         "rbft",
+		NodeID:         n.id,
+		Role:           role,
+		Primary:        primary,
+		View:           status.View,
+		Status:         status2String(status.Status),
+		LastCommitted:  atomic.LoadUint64(&n.lastCommitted),
+		LowWatermark:   low,
+		HighWatermark:  low + checkpointPeriod*logMultiplier,
+		PendingTxs:     uint64(len(n.txCache.recvTxC)) + ready,
+		ParkedTxs:      parked,
+		Validators:     order.SortedIDs(n.stack.peerMgr.Peers()),
+		ConnectedPeers: n.stack.peerMgr.CountConnectedPeers(),
+		StateUpdate:    n.stack.stateUpdate.progress(),
+	}
 }
 
 func (n *Node) Quorum() uint64 {
-	N := uint64(len(n.stack.nodes))
+	N := n.stack.nodeCount()
 	f := (N - 1) / 3
 	return (N + f + 2) / 2
 }
 
-func readConfig(repoRoot string) (*RBFTConfig, error) {
-	v := viper.New()
-	v.SetConfigFile(filepath.Join(repoRoot, "order.toml"))
-	v.SetConfigType("toml")
-	if err := v.ReadInConfig(); err != nil {
-		return nil, err
-	}
-
-	config := &RBFTConfig{}
-	if err := v.Unmarshal(config); err != nil {
-		return nil, err
-	}
+// reportMetrics samples the node status and the queue backlogs.
+func (n *Node) reportMetrics() {
+	status := n.n.Status()
+	viewGauge.Set(float64(status.View))
+	primaryGauge.Set(float64(n.primary(status.View)))
+	statusGauge.Reset()
+	statusGauge.WithLabelValues(status2String(status.Status)).Set(1)
+	txCacheDepth.Set(float64(len(n.txCache.recvTxC)))
+	txCacheCapacity.Set(float64(cap(n.txCache.recvTxC)))
+	readyCBacklog.Set(float64(len(n.stack.readyC)))
+	blockCBacklog.Set(float64(len(n.blockC)))
+}
 
-	return config, nil
+// primary returns the primary id of the given view from the router of the
+// core.
+func (n *Node) primary(view uint64) uint64 {
+	return n.stack.primary(view)
 }
 
 // status2String returns a long description of SystemStatus
@@ -1,12 +1,21 @@
-package main
+package rbft
 
 import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/meshplus/bitxhub-kit/log"
+	"github.com/meshplus/bitxhub-kit/storage/leveldb"
+	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/ultramesh/rbft"
 	"github.com/ultramesh/rbft/mempool"
@@ -17,23 +26,48 @@ func TestPrepare(t *testing.T){
 	defer cleanData()
 	ast := assert.New(t)
 	ctrl := gomock.NewController(t)
-	order := mockOrder(ctrl)
+	node := mockOrder(ctrl)
 	tx1 := mempool.ConstructTx("account1")
 	tx1.Nonce = uint64(1)
-	err := order.Prepare(tx1)
+	err := node.Prepare(tx1)
 	ast.NotNil(err)
-	ast.Equal("system is in pending state",err.Error())
+	ast.True(errors.Is(err, order.ErrBusy))
+	ast.Equal("system is in pending state: order is busy",err.Error())
 
-	err = order.Start()
+	err = node.Start()
 	ast.Nil(err)
-	err = order.Prepare(tx1)
+	err = node.Prepare(tx1)
 	ast.NotNil(err)
-	ast.Equal("system is in recovery",err.Error())
+	ast.True(errors.Is(err, order.ErrBusy))
+	ast.Equal("system is in recovery: order is busy",err.Error())
 
-	pendingNonce := order.GetPendingNonceByAccount(tx1.Account())
+	pendingNonce := node.GetPendingNonceByAccount(tx1.Account())
 	ast.Equal(uint64(1), pendingNonce)
 }
 
+func TestPrepareReplayed(t *testing.T) {
+	defer cleanData()
+	ast := assert.New(t)
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+
+	store, err := leveldb.New(t.TempDir())
+	ast.Nil(err)
+	defer store.Close()
+	lookUp, err := order.NewReqLookUp(store, log.NewWithModule("replay_filter"))
+	ast.Nil(err)
+	node.replayFilter = order.NewReplayFilter(lookUp, func(hash *types.Hash) bool {
+		return true
+	})
+
+	tx := mempool.ConstructTx("account1")
+	tx.TransactionHash = tx.Hash()
+	node.replayFilter.Add(uint64(2), tx.TransactionHash)
+	err = node.Prepare(tx)
+	ast.True(errors.Is(err, order.ErrReplayed))
+	ast.Equal(float64(1), testutil.ToFloat64(prepareRejectedCounter.WithLabelValues("replayed")))
+}
+
 func TestStop(t *testing.T) {
 	ast := assert.New(t)
 	defer cleanData()
@@ -71,6 +105,71 @@ func TestReadConfig(t *testing.T) {
 	ast.Equal(5*time.Minute, rbftConf.PoolConfig.ToleranceTime)
 }
 
+func TestReadWrongConfig(t *testing.T) {
+	ast := assert.New(t)
+	ctrl := gomock.NewController(t)
+	logger := log.NewWithModule("order")
+	repoRoot := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(repoRoot, "order.toml"), []byte("[rbft.timeout]\nrequest = \"0.1s\""), 0644)
+	ast.Nil(err)
+	_, err = generateRbftConfig(repoRoot, mockOrderConfig(logger, ctrl))
+	ast.NotNil(err)
+	ast.Contains(err.Error(), "rbft.timeout.request")
+
+	_, err = generateRbftConfig(filepath.Join(repoRoot, "not_exist"), mockOrderConfig(logger, ctrl))
+	ast.NotNil(err)
+}
+
+func TestReConfig(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+
+	repoRoot := t.TempDir()
+	writeConfig := func(content string) {
+		err := ioutil.WriteFile(filepath.Join(repoRoot, "order.toml"), []byte(content), 0644)
+		ast.Nil(err)
+	}
+	writeConfig("[rbft]\nset_size = 25\n")
+	config, err := rbftconfig.Load(repoRoot)
+	ast.Nil(err)
+	node.repoRoot = repoRoot
+	node.config = config
+
+	writeConfig(`
+[rbft]
+set_size = 5
+batch_size = 100
+
+    [rbft.timeout]
+        set = "1s"
+
+    [rbft.syncer]
+        sync_blocks = 3
+
+    [rbft.crypto]
+        verify_workers = 2
+`)
+	err = node.ReConfig()
+	ast.NotNil(err)
+	ast.Contains(err.Error(), "rbft.batch_size")
+	ast.Contains(err.Error(), coreReason)
+	ast.NotContains(err.Error(), "rbft.set_size")
+	ast.Equal(uint64(5), node.txCache.txSetSize)
+	ast.Equal(time.Second, node.txCache.txSetTick)
+	ast.Equal(uint64(3), node.stack.syncBlocks)
+	ast.Equal(int64(2), node.stack.verifyWorkers)
+	ast.Equal(5, node.config.Rbft.SetSize)
+	ast.Equal(uint64(500), node.config.Rbft.BatchSize)
+
+	// wrong config is refused as a whole
+	writeConfig("[rbft]\nset_size = 0\n")
+	err = node.ReConfig()
+	ast.NotNil(err)
+	ast.Equal(5, node.config.Rbft.SetSize)
+}
+
 func TestStep(t *testing.T) {
 	ast := assert.New(t)
 	defer cleanData()
@@ -93,6 +192,15 @@ func TestDelNode(t *testing.T){
 	ast.Nil(err)
 }
 
+func TestAddVPNode(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	err := node.AddNode(&pb.VpInfo{Id: uint64(4), Pid: "pid4", Hosts: []string{"/ip4/127.0.0.1/tcp/4004/p2p/"}})
+	ast.Nil(err)
+}
+
 func TestReportState(t *testing.T) {
 	ast := assert.New(t)
 	defer cleanData()
@@ -100,10 +208,9 @@ func TestReportState(t *testing.T) {
 	node := mockNode(ctrl)
 
     block := constructBlock("blockHash", uint64(20))
-	node.stack.stateUpdating = true
-	node.stack.stateUpdateHeight = 20
+	node.stack.stateUpdate.begin(20, 1)
 	node.ReportState(uint64(10), block.BlockHash, nil)
-	ast.Equal(true, node.stack.stateUpdating)
+	ast.NotNil(node.stack.stateUpdate.progress())
 
 	state := &rbftpb.ServiceState{
 		Applied: uint64(20),
@@ -111,10 +218,10 @@ func TestReportState(t *testing.T) {
 	}
 	node.n.ReportExecuted(state)
 	node.ReportState(uint64(20), block.BlockHash, nil)
-	ast.Equal(false, node.stack.stateUpdating)
+	ast.Nil(node.stack.stateUpdate.progress())
 
 	node.ReportState(uint64(21), block.BlockHash, nil)
-	ast.Equal(false, node.stack.stateUpdating)
+	ast.Nil(node.stack.stateUpdate.progress())
 }
 
 func TestQuorum(t *testing.T) {
@@ -161,3 +268,65 @@ func TestStatus2String(t *testing.T) {
 	statusStr = status2String(rbft.InSyncState)
 	ast.Equal("Unknown status", statusStr)
 }
+
+func TestReportMetrics(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+
+	// N = 3, primaries are elected in turn
+	ast.Equal(uint64(1), node.primary(0))
+	ast.Equal(uint64(2), node.primary(1))
+	ast.Equal(uint64(1), node.primary(3))
+
+	node.txCache.recvTxC <- &pb.Transaction{}
+	node.reportMetrics()
+	ast.Equal(float64(1), testutil.ToFloat64(txCacheDepth))
+	ast.Equal(float64(DefaultTxCacheSize), testutil.ToFloat64(txCacheCapacity))
+	ast.Equal(float64(0), testutil.ToFloat64(readyCBacklog))
+	ast.Equal(float64(1), testutil.ToFloat64(statusGauge.WithLabelValues(status2String(node.n.Status().Status))))
+}
+
+func TestStatus(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	node.stack.nodes = map[uint64]*pb.VpInfo{1: {Id: 1}, 2: {Id: 2}, 3: {Id: 3}}
+
+	block := constructBlock("blockHash", uint64(23))
+	node.ReportState(uint64(23), block.BlockHash, nil)
+	// the core persists its stable checkpoint, not the executed height
+	ast.Nil(node.stack.StoreState(watermarkKey, []byte("10")))
+	node.txCache.recvTxC <- &pb.Transaction{}
+	// the core demands nonce 1 of the account, the proposed ones are parked
+	tx2 := mempool.ConstructTx("account1")
+	tx2.Nonce = 2
+	tx3 := mempool.ConstructTx("account1")
+	tx3.Nonce = 3
+	node.pool.add([]*pb.Transaction{tx2, tx3})
+
+	status := node.Status()
+	ast.Equal("rbft", status.Engine)
+	ast.Equal(uint64(1), status.NodeID)
+	ast.Equal(node.primary(status.View), status.Primary)
+	ast.Equal(uint64(23), status.LastCommitted)
+	ast.Equal(uint64(10), status.LowWatermark)
+	ast.Equal(uint64(50), status.HighWatermark)
+	ast.Equal(uint64(1), status.PendingTxs)
+	ast.Equal(uint64(2), status.ParkedTxs)
+	ast.Equal([]uint64{1, 2, 3}, status.Validators)
+	ast.Equal(uint64(2), status.ConnectedPeers)
+	if status.Primary == node.id {
+		ast.Equal(order.RolePrimary, status.Role)
+	} else {
+		ast.Equal(order.RoleReplica, status.Role)
+	}
+
+	// the watermark is restored after a restart
+	ast.Nil(node.stack.flushState())
+	restarted, err := NewStack(node.stack.store, mockOrderConfig(node.logger, ctrl), node.blockC, func() {}, false)
+	ast.Nil(err)
+	ast.Equal(uint64(10), atomic.LoadUint64(&restarted.lowWatermark))
+}
@@ -1,4 +1,4 @@
-package main
+package rbft
 
 import (
 	"context"
@@ -16,6 +16,9 @@ import (
 	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
 	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
+	"github.com/meshplus/bitxhub/pkg/order/syncer"
 	"github.com/meshplus/bitxhub/pkg/peermgr/mock_peermgr"
 	"github.com/sirupsen/logrus"
 	"github.com/ultramesh/rbft"
@@ -39,19 +42,69 @@ func mockMiniPeerManager(ctrl *gomock.Controller) *mock_peermgr.MockPeerManager
 	mock.EXPECT().AddNode(gomock.Any(), gomock.Any()).Return().AnyTimes()
 	mock.EXPECT().DelNode(gomock.Any()).Return().AnyTimes()
 	mock.EXPECT().UpdateRouter(gomock.Any(), gomock.Any()).Return(false).AnyTimes()
-	block := constructBlock("block2", uint64(2))
-	blockBytes, _ := block.Marshal()
-	res := &pb.Message{Data: blockBytes}
-	mock.EXPECT().Send(gomock.Any(), gomock.Any()).Return(res, nil).AnyTimes()
+	blocks := genBlocks(10)
+	mock.EXPECT().Send(gomock.Any(), gomock.Any()).DoAndReturn(func(id uint64, m *pb.Message) (*pb.Message, error) {
+		return handleBlockRequest(m, func(height uint64) (*pb.Block, error) {
+			if height == 0 || height > uint64(len(blocks)) {
+				return nil, fmt.Errorf("block %d not found", height)
+			}
+			return blocks[height-1], nil
+		})
+	}).AnyTimes()
 	nodes := make(map[uint64]*pb.VpInfo)
 	nodes[1] = &pb.VpInfo{Id: uint64(1)}
 	nodes[2] = &pb.VpInfo{Id: uint64(2)}
 	nodes[3] = &pb.VpInfo{Id: uint64(3)}
 	mock.EXPECT().Peers().Return(nodes).AnyTimes()
+	mock.EXPECT().CountConnectedPeers().Return(uint64(2)).AnyTimes()
 	mock.EXPECT().Disconnect(gomock.Any()).Return().AnyTimes()
 	return mock
 }
 
+// handleBlockRequest answers the block requests of the state syncer like the
+// peer manager does, the blocks are read by getBlock.
+func handleBlockRequest(m *pb.Message, getBlock func(height uint64) (*pb.Block, error)) (*pb.Message, error) {
+	switch m.Type {
+	case pb.Message_GET_BLOCK_HEADERS:
+		req := &pb.GetBlockHeadersRequest{}
+		if err := req.Unmarshal(m.Data); err != nil {
+			return nil, err
+		}
+		res := &pb.GetBlockHeadersResponse{}
+		for i := req.Start; i <= req.End; i++ {
+			block, err := getBlock(i)
+			if err != nil {
+				return nil, err
+			}
+			res.BlockHeaders = append(res.BlockHeaders, block.BlockHeader)
+		}
+		data, err := res.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		return &pb.Message{Type: pb.Message_GET_BLOCK_HEADERS_ACK, Data: data}, nil
+	case pb.Message_GET_BLOCKS:
+		req := &pb.GetBlocksRequest{}
+		if err := req.Unmarshal(m.Data); err != nil {
+			return nil, err
+		}
+		res := &pb.GetBlocksResponse{}
+		for i := req.Start; i <= req.End; i++ {
+			block, err := getBlock(i)
+			if err != nil {
+				return nil, err
+			}
+			res.Blocks = append(res.Blocks, block)
+		}
+		data, err := res.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		return &pb.Message{Type: pb.Message_GET_BLOCKS_ACK, Data: data}, nil
+	}
+	return nil, fmt.Errorf("unsupported message type: %s", m.Type)
+}
+
 func withID() order.Option {
 	return func(config *order.Config) {
 		config.ID = uint64(1)
@@ -122,7 +175,7 @@ func mockNode(ctrl *gomock.Controller) *Node {
 	logger := log.NewWithModule("order")
 	rbftConf := mockRBFTConfig(ctrl, logger)
 	etcdNode, _ := rbft.NewNode(rbftConf)
-	store, _ := NewStorage(storagePath)
+	store, _ := rbftstorage.Open(rbftconfig.StorageLevelDB, storagePath)
 	blockC := make(chan *pb.CommitEvent, 1024)
 	ctx, cancel := context.WithCancel(context.Background())
 	stack, _ := NewStack(store, mockOrderConfig(logger, ctrl), blockC, cancel, rbftConf.IsNew)
@@ -133,9 +186,15 @@ func mockNode(ctrl *gomock.Controller) *Node {
 		stack:   stack,
 		blockC:  blockC,
 		ctx:     ctx,
-		txCache: newTxCache(0, 0, logger),
+		txCache: newTxCache(0, 0, 0, logger),
+		pool:    newPoolCounter(),
 	}
 	stack.applyConfChange = node.n.ApplyConfChange
+	stack.stateUpdated = node.n.ReportStateUpdated
+	stack.nodeView = func() uint64 {
+		return node.n.Status().View
+	}
+	stack.stopNode = node.Stop
 	return node
 }
 
@@ -239,6 +298,26 @@ func genPrivKey() crypto.PrivateKey {
 	return privKey
 }
 
+// genBlocks generates a chain of blocks on top of the block returned by getChainMetaFunc.
+func genBlocks(count int) []*pb.Block {
+	blocks := []*pb.Block{constructBlock("block1", uint64(1))}
+	// the blocks have no transactions
+	txRoot, _ := syncer.CalcTxRoot(nil, nil)
+	for height := 2; height <= count; height++ {
+		block := &pb.Block{
+			BlockHeader: &pb.BlockHeader{
+				Number:     uint64(height),
+				TxRoot:     txRoot,
+				ParentHash: blocks[len(blocks)-1].BlockHash,
+				Timestamp:  time.Now().UnixNano(),
+			},
+		}
+		block.BlockHash = block.Hash()
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
 func getChainMetaFunc() *pb.ChainMeta {
 	block := constructBlock("block1", uint64(1))
 	return &pb.ChainMeta{Height: uint64(1), BlockHash: block.BlockHash}
@@ -0,0 +1,95 @@
+package rbft
+
+import (
+	"time"
+
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
+	"github.com/ultramesh/rbft"
+)
+
+// The transactions a node accepts are kept in its cache and in the pool of the
+// RBFT core until they are committed, both are lost on restart. They are saved
+// to the order storage periodically and on stop, and proposed again once the
+// restarted node is ready. The ones committed or overtaken by the nonce of
+// their accounts in the meantime are dropped.
+
+const (
+	pendingTxsSaveInterval = 5 * time.Second
+	reproposeRetryInterval = 100 * time.Millisecond
+)
+
+// restorePendingTxs loads the transactions saved before the restart and
+// proposes them again in the background.
+func (n *Node) restorePendingTxs() {
+	txs, err := n.pendingTxs.Load(n.stack.store)
+	if err != nil {
+		n.logger.Errorf("Restore pending transactions failed: %s", err)
+		return
+	}
+	if len(txs) == 0 {
+		return
+	}
+	n.logger.Infof("Restore %d pending transactions", len(txs))
+	go n.repropose(txs)
+}
+
+// repropose hands the transactions over to the cache like Prepare does, once
+// the node has recovered and caught up with the ledger, so the stale ones can
+// be told.
+func (n *Node) repropose(txs []*pb.Transaction) {
+	var proposed, dropped int
+	for _, tx := range txs {
+		if n.isStopping() {
+			return
+		}
+		for n.n.Status().Status != rbft.Normal {
+			select {
+			case <-time.After(reproposeRetryInterval):
+			case <-n.ctx.Done():
+				return
+			}
+		}
+		if n.staleTx(tx) {
+			n.pendingTxs.Remove(tx.TransactionHash.String())
+			dropped++
+			continue
+		}
+		for !n.txCache.push(tx) {
+			select {
+			case <-time.After(reproposeRetryInterval):
+			case <-n.ctx.Done():
+				return
+			}
+		}
+		proposed++
+	}
+	n.logger.Infof("Proposed %d restored transactions again, dropped %d committed ones", proposed, dropped)
+}
+
+// staleTx tells if the transaction is committed, or its nonce is below the
+// account nonce of the ledger. The one at the account nonce is left to the
+// replay filter.
+func (n *Node) staleTx(tx *pb.Transaction) bool {
+	if n.replayFilter.Check(tx.TransactionHash) != nil {
+		return true
+	}
+	if n.getAccountNonce != nil && tx.From != nil && tx.Nonce < n.getAccountNonce(tx.From) {
+		return true
+	}
+	return false
+}
+
+// savePendingTxs writes the changes of the pending transactions, the ones
+// committed by the other nodes without this node seeing them are pruned.
+func (n *Node) savePendingTxs(sync bool) {
+	if pruned := n.pendingTxs.Prune(n.staleTx); pruned != 0 {
+		n.logger.Debugf("Prune %d committed pending transactions", pruned)
+	}
+	err := n.stack.withStore(func(store *rbftstorage.Storage) error {
+		return n.pendingTxs.Save(store, sync)
+	})
+	if err != nil {
+		n.logger.Errorf("Save pending transactions failed: %s", err)
+	}
+}
@@ -0,0 +1,49 @@
+package rbft
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/ultramesh/rbft/mempool"
+)
+
+func TestSavePendingTxs(t *testing.T) {
+	defer cleanData()
+	ast := assert.New(t)
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	node.pendingTxs = rbftstorage.NewPendingTxs()
+	node.getAccountNonce = func(address *types.Address) uint64 {
+		return 2
+	}
+
+	committed := mempool.ConstructTx("account1")
+	committed.Nonce = 1
+	committed.TransactionHash = committed.Hash()
+	pending := mempool.ConstructTx("account1")
+	pending.Nonce = 2
+	pending.TransactionHash = pending.Hash()
+	ast.True(node.staleTx(committed))
+	// the nonce is the account nonce, it isn't committed yet
+	ast.False(node.staleTx(pending))
+
+	node.pendingTxs.Add(committed, pending)
+	node.savePendingTxs(true)
+	ast.Equal(1, node.pendingTxs.Len())
+
+	restored := rbftstorage.NewPendingTxs()
+	txs, err := restored.Load(node.stack.store)
+	ast.Nil(err)
+	ast.Equal(1, len(txs))
+	ast.Equal(pending.TransactionHash.String(), txs[0].TransactionHash.String())
+
+	// the transaction is forgotten once it is committed
+	node.pendingTxs.Remove(pending.TransactionHash.String())
+	node.savePendingTxs(true)
+	txs, err = rbftstorage.NewPendingTxs().Load(node.stack.store)
+	ast.Nil(err)
+	ast.Equal(0, len(txs))
+}
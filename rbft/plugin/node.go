@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bitxhub-order-rbft/rbft"
+
+	"github.com/meshplus/bitxhub/pkg/order"
+)
+
+// Manifest is checked by bitxhub before NewNode is called.
+var Manifest = order.Manifest{
+	Name:             "rbft",
+	Version:          "1.0.0",
+	InterfaceVersion: order.InterfaceVersion,
+}
+
+func NewNode(opts ...order.Option) (order.Order, error) {
+	return rbft.NewNode(opts...)
+}
+
+// DecodeState is looked up by bitxhub order inspect.
+func DecodeState(kind, key string, value []byte) (interface{}, error) {
+	return rbft.DecodeState(kind, key, value)
+}
@@ -0,0 +1,97 @@
+package rbft
+
+import (
+	"sync"
+
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+)
+
+// poolCounter tracks the transactions this node proposed to the RBFT pool
+// until they are executed. The core only exposes the pending nonce of an
+// account, the transactions below it are ready for a batch and the others
+// are parked until the missing nonces arrive.
+type poolCounter struct {
+	lock     sync.Mutex
+	accounts map[string]map[uint64]string // account -> nonce -> tx hash
+	txs      map[string]pooledTx          // tx hash -> account and nonce
+}
+
+type pooledTx struct {
+	account string
+	nonce   uint64
+}
+
+func newPoolCounter() *poolCounter {
+	return &poolCounter{
+		accounts: make(map[string]map[uint64]string),
+		txs:      make(map[string]pooledTx),
+	}
+}
+
+// add records the transactions proposed to the core.
+func (pc *poolCounter) add(txs []*pb.Transaction) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	for _, tx := range txs {
+		hash := tx.Hash().String()
+		account := tx.Account()
+		nonces, ok := pc.accounts[account]
+		if !ok {
+			nonces = make(map[uint64]string)
+			pc.accounts[account] = nonces
+		}
+		if old, ok := nonces[tx.Nonce]; ok {
+			delete(pc.txs, old)
+		}
+		nonces[tx.Nonce] = hash
+		pc.txs[hash] = pooledTx{account: account, nonce: tx.Nonce}
+	}
+}
+
+// remove drops the executed transactions, along with the transactions of the
+// same account with a lower nonce, the pool won't batch them anymore.
+func (pc *poolCounter) remove(hashes []*types.Hash) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	for _, hash := range hashes {
+		if hash == nil {
+			continue
+		}
+		tx, ok := pc.txs[hash.String()]
+		if !ok {
+			continue
+		}
+		nonces := pc.accounts[tx.account]
+		for nonce, h := range nonces {
+			if nonce <= tx.nonce {
+				delete(nonces, nonce)
+				delete(pc.txs, h)
+			}
+		}
+		if len(nonces) == 0 {
+			delete(pc.accounts, tx.account)
+		}
+	}
+}
+
+// counts splits the tracked transactions by the pending nonce of their
+// account in the core.
+func (pc *poolCounter) counts(pendingNonce func(account string) uint64) (ready, parked uint64) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	for account, nonces := range pc.accounts {
+		next := pendingNonce(account)
+		for nonce := range nonces {
+			if nonce < next {
+				ready++
+			} else {
+				parked++
+			}
+		}
+	}
+	return ready, parked
+}
@@ -0,0 +1,39 @@
+package rbft
+
+import (
+	"testing"
+
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/ultramesh/rbft/mempool"
+)
+
+func TestPoolCounter(t *testing.T) {
+	ast := assert.New(t)
+	pc := newPoolCounter()
+
+	txs := make([]*pb.Transaction, 0)
+	for _, nonce := range []uint64{1, 2, 4} {
+		tx := mempool.ConstructTx("account1")
+		tx.Nonce = nonce
+		txs = append(txs, tx)
+	}
+	pc.add(txs)
+	pendingNonce := func(account string) uint64 {
+		return 3
+	}
+	ready, parked := pc.counts(pendingNonce)
+	ast.Equal(uint64(2), ready)
+	ast.Equal(uint64(1), parked)
+
+	// the execution of nonce 2 also drops nonce 1
+	pc.remove([]*types.Hash{txs[1].Hash(), nil})
+	ready, parked = pc.counts(pendingNonce)
+	ast.Equal(uint64(0), ready)
+	ast.Equal(uint64(1), parked)
+
+	pc.remove([]*types.Hash{txs[2].Hash()})
+	ast.Equal(0, len(pc.accounts))
+	ast.Equal(0, len(pc.txs))
+}
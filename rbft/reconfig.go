@@ -0,0 +1,103 @@
+package rbft
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+)
+
+// liveParams are the keys applied without restarting, they are used by this
+// plugin rather than the RBFT core, which reads its config on startup only.
+// The batch size, the batch timeout and the view change period are kept by
+// the core, whose node has no way to change them while it runs, they are
+// refused with coreReason.
+var liveParams = map[string]func(n *Node, config *rbftconfig.RBFT){
+	"rbft.set_size": func(n *Node, config *rbftconfig.RBFT) {
+		n.config.Rbft.SetSize = config.SetSize
+		n.txCache.setTxSetSize(uint64(config.SetSize))
+	},
+	"rbft.timeout.set": func(n *Node, config *rbftconfig.RBFT) {
+		n.config.Rbft.Timeout.Set = config.Timeout.Set
+		n.txCache.setTxSetTick(config.Timeout.Set)
+	},
+	"rbft.syncer.sync_blocks": func(n *Node, config *rbftconfig.RBFT) {
+		n.config.Rbft.SyncerConfig.SyncBlocks = config.SyncerConfig.SyncBlocks
+		atomic.StoreUint64(&n.stack.syncBlocks, config.SyncerConfig.SyncBlocks)
+	},
+	"rbft.crypto.verify_cache_size": func(n *Node, config *rbftconfig.RBFT) {
+		n.config.Rbft.CryptoConfig.VerifyCacheSize = config.CryptoConfig.VerifyCacheSize
+		n.stack.verifyCache.resize(config.CryptoConfig.VerifyCacheSize)
+	},
+	"rbft.crypto.verify_workers": func(n *Node, config *rbftconfig.RBFT) {
+		n.config.Rbft.CryptoConfig.VerifyWorkers = config.CryptoConfig.VerifyWorkers
+		n.stack.setVerifyWorkers(config.CryptoConfig.VerifyWorkers)
+	},
+	"rbft.storage.sync_critical": func(n *Node, config *rbftconfig.RBFT) {
+		n.config.Rbft.StorageConfig.SyncCritical = config.StorageConfig.SyncCritical
+		n.stack.setSyncCritical(config.StorageConfig.SyncCritical)
+	},
+}
+
+// coreParams are the keys asked for most often that only the RBFT core uses.
+var coreParams = map[string]bool{
+	"rbft.batch_size":    true,
+	"rbft.timeout.batch": true,
+	"rbft.vc_period":     true,
+}
+
+const coreReason = "the ultramesh RBFT core fixes it on startup and can't change it while running, change it on all nodes and restart them one by one"
+
+// refuseReason explains why a key can't be changed live.
+func refuseReason(key string) string {
+	if coreParams[key] {
+		return coreReason
+	}
+	if key == "rbft.tx_cache_size" {
+		return "the capacity of the transaction cache is fixed on startup, restart the node to apply it"
+	}
+	if strings.HasPrefix(key, "rbft.crypto.") {
+		return "the other nodes couldn't verify the messages signed by a different scheme, update all nodes and restart them"
+	}
+	if strings.HasPrefix(key, "rbft.audit.") {
+		return "the audit log is opened on startup, restart the node to apply it"
+	}
+	if strings.HasPrefix(key, "rbft.storage.") {
+		return "the consensus state stays in the old storage, stop the node, run bitxhub order migrate and restart it"
+	}
+	return "it is read by the RBFT core on startup only, restart the node to apply it"
+}
+
+// ReConfig reloads order.toml and applies the changes of the live parameters,
+// the host calls it when the file is written. The other changes are refused,
+// the running values of them are kept and the returned error names every
+// refused key.
+func (n *Node) ReConfig() error {
+	n.reconfigLock.Lock()
+	defer n.reconfigLock.Unlock()
+
+	if n.config == nil {
+		return fmt.Errorf("order config is not loaded")
+	}
+	config, err := rbftconfig.Load(n.repoRoot)
+	if err != nil {
+		return fmt.Errorf("reload order config: %w", err)
+	}
+
+	refused := make([]string, 0)
+	for _, change := range rbftconfig.Diff(n.config, config) {
+		apply, ok := liveParams[change.Key]
+		if !ok {
+			refused = append(refused, fmt.Sprintf("%s (%s -> %s): %s", change.Key, change.Old, change.New, refuseReason(change.Key)))
+			continue
+		}
+		apply(n, &config.Rbft)
+		n.logger.Infof("Apply order config %s: %s -> %s", change.Key, change.Old, change.New)
+	}
+
+	if len(refused) != 0 {
+		return fmt.Errorf("refuse to change order config: %s", strings.Join(refused, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package rbft
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/meshplus/bitxhub-kit/log"
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClusterReplayAudit records the messages of node 4 and replays them
+// into a fresh node 4, it commits the same blocks as the recorded one.
+func TestClusterReplayAudit(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCluster(t, 4, withAudit(4, dir))
+	c.start()
+	c.commitBlocks(1, 3, c.ids()...)
+	c.sendTxs(2, 20)
+	c.waitHeight(c.node(2).ledger.height()+1, c.ids()...)
+	recorded := c.node(4)
+	recorded.order.Stop()
+
+	config, err := order.GenerateConfig(
+		order.WithID(4),
+		order.WithRepoRoot(c.repoRoot),
+		order.WithStoragePath(filepath.Join(t.TempDir(), "storage")),
+		order.WithPrivKey(genPrivKey()),
+		order.WithLogger(log.NewWithModule("replay")),
+		order.WithNodes(c.vpInfos),
+	)
+	require.Nil(t, err)
+	r, err := newReplayer(config, newSimLedger(constructBlock("genesis", uint64(1))))
+	require.Nil(t, err)
+	defer r.stop()
+
+	stepped, err := r.run(dir, true, func(path string, err error) {
+		t.Errorf("audit file %s is torn: %s", path, err)
+	})
+	require.Nil(t, err)
+	require.NotZero(t, stepped)
+
+	height := recorded.ledger.height()
+	require.Eventually(t, func() bool {
+		return r.ledger.height() >= height
+	}, clusterTimeout, 50*time.Millisecond, "replayed node doesn't reach height %d", height)
+	for h := uint64(2); h <= height; h++ {
+		expected, err := recorded.ledger.getBlock(h)
+		require.Nil(t, err)
+		block, err := r.ledger.getBlock(h)
+		require.Nil(t, err)
+		require.Equal(t, expected.BlockHash.String(), block.BlockHash.String(), "block %d differs", h)
+	}
+	require.NotZero(t, r.external.sentTypes()["COMMIT"])
+}
+
+// TestReplayAudit replays an audit log copied from a node, run it under a
+// debugger to reproduce how the node handled the messages:
+//
+//	RBFT_REPLAY=/path/to/audit RBFT_REPLAY_ID=2 RBFT_REPLAY_NODES=4 \
+//	RBFT_REPLAY_HEIGHT=1000 dlv test -- -test.run TestReplayAudit
+//
+// RBFT_REPLAY_HEIGHT is the height of the node when the log was started,
+// RBFT_REPLAY_REPO is the repo root holding the order.toml of the node,
+// ./testdata/ by default.
+func TestReplayAudit(t *testing.T) {
+	dir := os.Getenv("RBFT_REPLAY")
+	if dir == "" {
+		t.Skip("RBFT_REPLAY is not set")
+	}
+	id := replayEnv(t, "RBFT_REPLAY_ID", 1)
+	count := replayEnv(t, "RBFT_REPLAY_NODES", 4)
+	height := replayEnv(t, "RBFT_REPLAY_HEIGHT", 1)
+	repoRoot := os.Getenv("RBFT_REPLAY_REPO")
+	if repoRoot == "" {
+		repoRoot = "./testdata/"
+	}
+
+	nodes := make(map[uint64]*pb.VpInfo)
+	for i := uint64(1); i <= count; i++ {
+		nodes[i] = &pb.VpInfo{Id: i}
+	}
+	config, err := order.GenerateConfig(
+		order.WithID(id),
+		order.WithRepoRoot(repoRoot),
+		order.WithStoragePath(filepath.Join(t.TempDir(), "storage")),
+		order.WithPrivKey(genPrivKey()),
+		order.WithLogger(log.NewWithModule("replay")),
+		order.WithNodes(nodes),
+	)
+	require.Nil(t, err)
+	r, err := newReplayer(config, newSimLedger(constructBlock("genesis", height)))
+	require.Nil(t, err)
+	defer r.stop()
+
+	stepped, err := r.run(dir, true, func(path string, err error) {
+		t.Logf("audit file %s is torn: %s", path, err)
+	})
+	require.Nil(t, err)
+	t.Logf("replayed %d messages, node %d is at height %d and sent %v",
+		stepped, id, r.ledger.height(), r.external.sentTypes())
+}
+
+func replayEnv(t *testing.T, key string, def uint64) uint64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	require.Nil(t, err, "%s must be a number", key)
+	return n
+}
@@ -0,0 +1,165 @@
+package rbft
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftaudit"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/ultramesh/rbft"
+	"github.com/ultramesh/rbft/rbftpb"
+)
+
+// maxReplayGap caps the pause between two replayed messages, a node idle for
+// hours is replayed in seconds.
+const maxReplayGap = 5 * time.Second
+
+// replayExternal is the external of a node replaying an audit log. The
+// messages the node sends are kept instead of sent and the signatures are not
+// verified, the recorded node has already judged them and the replaying one
+// doesn't need the keys of the cluster.
+type replayExternal struct {
+	*Stack
+
+	lock sync.Mutex
+	sent []*rbftpb.ConsensusMessage
+}
+
+func (e *replayExternal) Broadcast(msg *rbftpb.ConsensusMessage) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.sent = append(e.sent, msg)
+	return nil
+}
+
+func (e *replayExternal) Unicast(msg *rbftpb.ConsensusMessage, to uint64) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.sent = append(e.sent, msg)
+	return nil
+}
+
+func (e *replayExternal) Verify(peerID uint64, signature []byte, msg []byte) error {
+	return nil
+}
+
+// sentTypes counts the messages the node has sent by type.
+func (e *replayExternal) sentTypes() map[string]int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	counts := make(map[string]int)
+	for _, msg := range e.sent {
+		counts[msg.Type.String()]++
+	}
+	return counts
+}
+
+// replayer feeds the inbound messages of an audit log into a fresh node cut
+// off from any network, the committed blocks are executed on a simLedger.
+// Break in the RBFT core to follow how the recorded node handled them.
+type replayer struct {
+	node     *Node
+	external *replayExternal
+	ledger   *simLedger
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newReplayer creates the node of config, it starts from the block on top of
+// the ledger. The peer manager and the chain of config are replaced, the
+// order.toml under the repo root must not enable the audit log, the node
+// would record into the log being replayed.
+func newReplayer(config *order.Config, ledger *simLedger) (*replayer, error) {
+	orderConfig, err := rbftconfig.Load(config.RepoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if orderConfig.Rbft.AuditConfig.Enable {
+		return nil, fmt.Errorf("disable rbft.audit in the replay repo %s", config.RepoRoot)
+	}
+
+	meta := ledger.chainMeta()
+	config.PeerMgr = newSimNetwork().join(config.ID, config.Nodes, ledger.getBlock)
+	config.Applied = meta.Height
+	config.Digest = meta.BlockHash.String()
+	config.GetChainMetaFunc = ledger.chainMeta
+	config.GetBlockByHeight = ledger.getBlock
+	config.GetAccountNonce = ledger.nonce
+
+	r := &replayer{
+		ledger: ledger,
+		done:   make(chan struct{}),
+	}
+	r.node, err = newNode(config, func(rbftConfig *rbft.Config, s *Stack) {
+		r.external = &replayExternal{Stack: s}
+		rbftConfig.External = r.external
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.node.Start(); err != nil {
+		return nil, err
+	}
+	go r.execute()
+	return r, nil
+}
+
+// run steps the inbound records in dir into the node in the order they are
+// received and returns how many are stepped. With pace the recorded gaps
+// between the records are kept, so the timers of the core fire as they did.
+// The torn files are reported to torn and skipped from the torn record on.
+func (r *replayer) run(dir string, pace bool, torn func(path string, err error)) (int, error) {
+	var (
+		stepped int
+		last    time.Time
+	)
+	err := rbftaudit.ReadDir(dir, func(record *rbftaudit.Record) error {
+		if record.Direction != rbftaudit.Inbound {
+			return nil
+		}
+		if pace && !last.IsZero() {
+			gap := record.Time.Sub(last)
+			if gap > maxReplayGap {
+				gap = maxReplayGap
+			}
+			time.Sleep(gap)
+		}
+		last = record.Time
+		if err := r.node.Step(record.Data); err != nil {
+			r.node.logger.Warningf("Replay message from %d at %s failed: %s", record.Peer, record.Time, err)
+		}
+		stepped++
+		return nil
+	}, torn)
+	return stepped, err
+}
+
+// execute plays the executor of the host like the cluster does.
+func (r *replayer) execute() {
+	for {
+		select {
+		case ev := <-r.node.Commit():
+			block := ev.Block
+			if !r.ledger.execute(block) {
+				continue
+			}
+			hashes := make([]*types.Hash, 0, len(block.Transactions))
+			for _, tx := range block.Transactions {
+				hashes = append(hashes, tx.TransactionHash)
+			}
+			r.node.ReportState(block.Height(), block.BlockHash, hashes)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *replayer) stop() {
+	r.stopOnce.Do(func() {
+		r.node.Stop()
+		close(r.done)
+	})
+}
@@ -0,0 +1,440 @@
+package rbft
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order/syncer"
+	"github.com/meshplus/bitxhub/pkg/peermgr"
+	"github.com/stretchr/testify/require"
+)
+
+// simReorderDelay is added to the latency of a reordered message, so the
+// messages sent after it on the same link overtake it.
+const simReorderDelay = 20 * time.Millisecond
+
+// simNetwork routes the messages between the peer managers of an in-process
+// cluster. Every directed link delivers its messages in order after the
+// latency, except the ones chosen to be reordered, which are delivered out of
+// band later. Messages between nodes in different partitions are lost.
+type simNetwork struct {
+	lock        sync.Mutex
+	peers       map[uint64]*simPeerManager
+	groups      map[uint64]int // partition of the nodes, all nodes are in 0 when healed
+	links       map[[2]uint64]chan *simPacket
+	latency     time.Duration
+	dropRate    float64
+	reorderRate float64
+	rand        *rand.Rand
+	closeC      chan struct{}
+	closed      bool
+}
+
+type simPacket struct {
+	from uint64
+	to   uint64
+	msg  *pb.Message
+	at   time.Time
+}
+
+// simSeedEnv fixes the seed of the drops and the reorders, the seed of a
+// failed run is logged to reproduce it.
+const simSeedEnv = "RBFT_SIMNET_SEED"
+
+// simSeed returns the seed set by simSeedEnv, or a new one, and logs it.
+func simSeed(t *testing.T) int64 {
+	seed := time.Now().UnixNano()
+	if value := os.Getenv(simSeedEnv); value != "" {
+		var err error
+		seed, err = strconv.ParseInt(value, 10, 64)
+		require.Nil(t, err, "%s must be a number", simSeedEnv)
+	}
+	t.Logf("simulated network seed %d, set %s to reproduce", seed, simSeedEnv)
+	return seed
+}
+
+func newSimNetwork(seed int64) *simNetwork {
+	return &simNetwork{
+		peers:  make(map[uint64]*simPeerManager),
+		groups: make(map[uint64]int),
+		links:  make(map[[2]uint64]chan *simPacket),
+		rand:   rand.New(rand.NewSource(seed)),
+		closeC: make(chan struct{}),
+	}
+}
+
+// join creates the peer manager of node id with the routing table nodes, the
+// blocks requested by the other nodes are read by getBlock.
+func (net *simNetwork) join(id uint64, nodes map[uint64]*pb.VpInfo, getBlock func(height uint64) (*pb.Block, error)) *simPeerManager {
+	routers := make(map[uint64]*pb.VpInfo, len(nodes))
+	for nodeID, vpInfo := range nodes {
+		routers[nodeID] = vpInfo
+	}
+	pm := &simPeerManager{
+		id:       id,
+		net:      net,
+		routers:  routers,
+		getBlock: getBlock,
+	}
+
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	net.peers[id] = pm
+	return pm
+}
+
+func (net *simNetwork) setLatency(latency time.Duration) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	net.latency = latency
+}
+
+func (net *simNetwork) setDropRate(rate float64) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	net.dropRate = rate
+}
+
+func (net *simNetwork) setReorderRate(rate float64) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	net.reorderRate = rate
+}
+
+// partition splits the given groups of nodes from each other, the nodes not
+// in any group stay together.
+func (net *simNetwork) partition(groups ...[]uint64) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	net.groups = make(map[uint64]int)
+	for i, group := range groups {
+		for _, id := range group {
+			net.groups[id] = i + 1
+		}
+	}
+}
+
+// isolate cuts node id off from all the other nodes.
+func (net *simNetwork) isolate(id uint64) {
+	net.partition([]uint64{id})
+}
+
+// heal removes all partitions.
+func (net *simNetwork) heal() {
+	net.partition()
+}
+
+func (net *simNetwork) close() {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	if !net.closed {
+		net.closed = true
+		close(net.closeC)
+	}
+}
+
+// reachable must be called with the lock held.
+func (net *simNetwork) reachable(from, to uint64) bool {
+	if net.closed || net.groups[from] != net.groups[to] {
+		return false
+	}
+	src, ok := net.peers[from]
+	if !ok || src.isRemoved() {
+		return false
+	}
+	dst, ok := net.peers[to]
+	return ok && !dst.isRemoved()
+}
+
+// send delivers msg asynchronously, it is lost silently like a message on a
+// broken connection.
+func (net *simNetwork) send(from, to uint64, msg *pb.Message) {
+	net.lock.Lock()
+	if !net.reachable(from, to) || net.rand.Float64() < net.dropRate {
+		net.lock.Unlock()
+		return
+	}
+	p := &simPacket{
+		from: from,
+		to:   to,
+		msg:  msg,
+		at:   time.Now().Add(net.latency),
+	}
+	if net.rand.Float64() < net.reorderRate {
+		net.lock.Unlock()
+		go func() {
+			time.Sleep(time.Until(p.at) + simReorderDelay)
+			net.deliver(p)
+		}()
+		return
+	}
+	link := net.link(from, to)
+	net.lock.Unlock()
+
+	select {
+	case link <- p:
+	case <-net.closeC:
+	}
+}
+
+// link returns the queue of the directed link, it must be called with the
+// lock held.
+func (net *simNetwork) link(from, to uint64) chan *simPacket {
+	key := [2]uint64{from, to}
+	if link, ok := net.links[key]; ok {
+		return link
+	}
+	link := make(chan *simPacket, 1024)
+	net.links[key] = link
+	go func() {
+		for {
+			select {
+			case p := <-link:
+				time.Sleep(time.Until(p.at))
+				net.deliver(p)
+			case <-net.closeC:
+				return
+			}
+		}
+	}()
+	return link
+}
+
+// deliver checks the partitions again, a message in flight is lost if the
+// link is cut before it arrives.
+func (net *simNetwork) deliver(p *simPacket) {
+	net.lock.Lock()
+	ok := net.reachable(p.from, p.to)
+	dst := net.peers[p.to]
+	net.lock.Unlock()
+	if ok {
+		dst.receive(p.msg)
+	}
+}
+
+// request sends msg and waits for the response of the peer, the round trip
+// takes twice the latency.
+func (net *simNetwork) request(from, to uint64, msg *pb.Message) (*pb.Message, error) {
+	net.lock.Lock()
+	if !net.reachable(from, to) {
+		net.lock.Unlock()
+		return nil, fmt.Errorf("peer %d is unreachable", to)
+	}
+	if net.rand.Float64() < net.dropRate {
+		net.lock.Unlock()
+		return nil, fmt.Errorf("request to peer %d timed out", to)
+	}
+	latency := net.latency
+	dst := net.peers[to]
+	net.lock.Unlock()
+
+	time.Sleep(2 * latency)
+	return handleBlockRequest(msg, dst.getBlock)
+}
+
+// simPeerManager is the peer manager of a node in a simNetwork. The methods
+// the order doesn't use are left to the embedded nil PeerManager.
+type simPeerManager struct {
+	peermgr.PeerManager
+
+	id       uint64
+	net      *simNetwork
+	getBlock func(height uint64) (*pb.Block, error)
+
+	lock    sync.RWMutex
+	routers map[uint64]*pb.VpInfo
+	removed bool
+	step    func(msg []byte) error // consensus messages are stepped into the order
+}
+
+func (pm *simPeerManager) Start() error {
+	return nil
+}
+
+func (pm *simPeerManager) Stop() error {
+	return nil
+}
+
+func (pm *simPeerManager) setStep(step func(msg []byte) error) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	pm.step = step
+}
+
+func (pm *simPeerManager) isRemoved() bool {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+	return pm.removed
+}
+
+func (pm *simPeerManager) receive(msg *pb.Message) {
+	if msg.Type != pb.Message_CONSENSUS {
+		return
+	}
+	pm.lock.RLock()
+	step := pm.step
+	pm.lock.RUnlock()
+	if step != nil {
+		_ = step(msg.Data)
+	}
+}
+
+func (pm *simPeerManager) AsyncSend(id uint64, msg *pb.Message) error {
+	pm.lock.RLock()
+	_, ok := pm.routers[id]
+	pm.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("peer %d is not in the routing table", id)
+	}
+	pm.net.send(pm.id, id, msg)
+	return nil
+}
+
+func (pm *simPeerManager) Send(id uint64, msg *pb.Message) (*pb.Message, error) {
+	return pm.net.request(pm.id, id, msg)
+}
+
+func (pm *simPeerManager) Broadcast(msg *pb.Message) error {
+	for id := range pm.Peers() {
+		if id != pm.id {
+			pm.net.send(pm.id, id, msg)
+		}
+	}
+	return nil
+}
+
+func (pm *simPeerManager) CountConnectedPeers() uint64 {
+	peers := pm.Peers()
+	pm.net.lock.Lock()
+	defer pm.net.lock.Unlock()
+	var counter uint64
+	for id := range peers {
+		if id != pm.id && pm.net.reachable(pm.id, id) {
+			counter++
+		}
+	}
+	return counter
+}
+
+func (pm *simPeerManager) Peers() map[uint64]*pb.VpInfo {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+	peers := make(map[uint64]*pb.VpInfo, len(pm.routers))
+	for id, vpInfo := range pm.routers {
+		peers[id] = vpInfo
+	}
+	return peers
+}
+
+func (pm *simPeerManager) AddNode(newNodeID uint64, vpInfo *pb.VpInfo) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	if _, ok := pm.routers[newNodeID]; !ok {
+		pm.routers[newNodeID] = vpInfo
+	}
+}
+
+// DelNode removes the node from the routing table, a node deleting itself
+// leaves the network.
+func (pm *simPeerManager) DelNode(delID uint64) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	delete(pm.routers, delID)
+	if delID == pm.id {
+		pm.routers = make(map[uint64]*pb.VpInfo)
+		pm.removed = true
+	}
+}
+
+func (pm *simPeerManager) UpdateRouter(vpInfos map[uint64]*pb.VpInfo, isNew bool) bool {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	pm.routers = make(map[uint64]*pb.VpInfo, len(vpInfos))
+	for id, vpInfo := range vpInfos {
+		pm.routers[id] = vpInfo
+	}
+	if _, ok := vpInfos[pm.id]; !ok && !isNew {
+		pm.removed = true
+		return true
+	}
+	return false
+}
+
+func (pm *simPeerManager) Disconnect(vpInfos map[uint64]*pb.VpInfo) {}
+
+// simLedger is the chain of a node in the cluster, the blocks are chained by
+// their parent hashes and the tx root commits to the transactions, so nodes
+// executing different batches end up with different block hashes.
+type simLedger struct {
+	lock   sync.RWMutex
+	blocks []*pb.Block
+	nonces map[string]uint64
+}
+
+func newSimLedger(genesis *pb.Block) *simLedger {
+	return &simLedger{
+		blocks: []*pb.Block{genesis},
+		nonces: make(map[string]uint64),
+	}
+}
+
+// execute appends the block if it is the next one, the blocks at other
+// heights are ignored.
+func (l *simLedger) execute(block *pb.Block) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	tip := l.blocks[len(l.blocks)-1]
+	if block.Height() != tip.Height()+1 {
+		return false
+	}
+	txRoot, err := syncer.CalcTxRoot(block.Transactions, nil)
+	if err != nil {
+		return false
+	}
+	for _, tx := range block.Transactions {
+		if tx.Nonce > l.nonces[tx.From.String()] {
+			l.nonces[tx.From.String()] = tx.Nonce
+		}
+	}
+	block.BlockHeader.ParentHash = tip.BlockHash
+	block.BlockHeader.TxRoot = txRoot
+	block.BlockHash = block.Hash()
+	l.blocks = append(l.blocks, block)
+	return true
+}
+
+func (l *simLedger) height() uint64 {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.blocks[len(l.blocks)-1].Height()
+}
+
+func (l *simLedger) getBlock(height uint64) (*pb.Block, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	first := l.blocks[0].Height()
+	if height < first || height >= first+uint64(len(l.blocks)) {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	return l.blocks[height-first], nil
+}
+
+func (l *simLedger) chainMeta() *pb.ChainMeta {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	tip := l.blocks[len(l.blocks)-1]
+	return &pb.ChainMeta{Height: tip.Height(), BlockHash: tip.BlockHash}
+}
+
+func (l *simLedger) nonce(address *types.Address) uint64 {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.nonces[address.String()]
+}
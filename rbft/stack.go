@@ -1,50 +1,102 @@
-package main
+package rbft
 
 import (
 	"context"
 	"crypto/sha256"
 	"fmt"
-	"os"
-	"strconv"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Rican7/retry"
 	"github.com/Rican7/retry/strategy"
 	"github.com/gogo/protobuf/proto"
 	"github.com/meshplus/bitxhub-kit/crypto"
-	"github.com/meshplus/bitxhub-kit/crypto/asym"
-	"github.com/meshplus/bitxhub-kit/types"
 	"github.com/meshplus/bitxhub-model/pb"
 	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftaudit"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
+	"github.com/meshplus/bitxhub/pkg/order/syncer"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
 	"github.com/meshplus/bitxhub/pkg/peermgr"
 	"github.com/sirupsen/logrus"
 	"github.com/ultramesh/rbft/rbftpb"
 )
 
 type Stack struct {
-	localID           uint64
-	store             *Storage
-	peerMgr           peermgr.PeerManager
-	priv              crypto.PrivateKey
-	nodes             map[uint64]*pb.VpInfo
-	readyC            chan *ready
-	blockC            chan *pb.CommitEvent
-	logger            logrus.FieldLogger
-	getChainMetaFunc  func() *pb.ChainMeta
-	stateUpdating     bool
-	stateUpdateHeight uint64
-	applyConfChange   func(cc *rbftpb.ConfState)
-	cancel            context.CancelFunc
-	isNew             bool
+	localID          uint64
+	store            *rbftstorage.Storage
+	peerMgr          peermgr.PeerManager
+	priv             crypto.PrivateKey
+	nodesLock        sync.RWMutex // guards nodes, UpdateTable runs beside Verify
+	nodes            map[uint64]*pb.VpInfo
+	readyC           chan *ready
+	blockC           chan *pb.CommitEvent
+	logger           logrus.FieldLogger
+	getChainMetaFunc func() *pb.ChainMeta
+	stateUpdate      stateUpdater
+	applyConfChange  func(cc *rbftpb.ConfState)
+	stateUpdated     func(state *rbftpb.ServiceState) // reports a state update reached to the core
+	cancel           context.CancelFunc
+	isNew            bool
+	syncBlocks       uint64
+	consensusFeed    order.EventFeed
+	nodeView         func() uint64 // current view of the RBFT core
+	stopNode         func()        // stops the order and the RBFT core
+	signAlgorithm    SignAlgorithm
+	scheme           signScheme // of signAlgorithm, the only one accepted
+	verifyCache      *verifyCache
+	verifyWorkers    int64
+	txStatus         *txstatus.Index
+	audit            *rbftaudit.Recorder // nil unless rbft.audit.enable is set
+
+	storeLock       sync.Mutex
+	pending         rbftstorage.Batch // writes not flushed yet
+	pendingCritical bool
+	storeErr        error // the failed write, it is sticky
+	syncCritical    uint32
+	lowWatermark    uint64 // persisted by the core at its stable checkpoint
 }
 
+// stateUpdateRetryLimit bounds the attempts of a single state update, the
+// RBFT core will trigger another one if the node is still behind.
+const stateUpdateRetryLimit = 5
+
+// removeDelay leaves time for the removal conf change to be delivered to the
+// other nodes before this node disconnects from them.
+var removeDelay = 3 * time.Second
+
 type ready struct {
 	txs       []*pb.Transaction
 	localList []bool
 	height    uint64
+	timestamp int64
 }
 
-func NewStack(store *Storage, config *order.Config, blockC chan *pb.CommitEvent, cancel context.CancelFunc, isNew bool) (*Stack, error) {
+// commitEvent builds the block for an executed batch. The block timestamp is
+// the one agreed by the RBFT core rather than the local wall clock, so every
+// replica produces the same block header for the same sequence number.
+func (r *ready) commitEvent() *pb.CommitEvent {
+	block := &pb.Block{
+		BlockHeader: &pb.BlockHeader{
+			Version:   []byte("1.0.0"),
+			Number:    r.height,
+			Timestamp: r.timestamp,
+		},
+		Transactions: r.txs,
+	}
+	return &pb.CommitEvent{
+		Block:     block,
+		LocalList: r.localList,
+	}
+}
+
+func NewStack(store *rbftstorage.Storage, config *order.Config, blockC chan *pb.CommitEvent, cancel context.CancelFunc, isNew bool) (*Stack, error) {
+	cache, err := newVerifyCache(defaultVerifyCacheSize)
+	if err != nil {
+		return nil, err
+	}
 	stack := &Stack{
 		localID:          config.ID,
 		store:            store,
@@ -57,11 +109,21 @@ func NewStack(store *Storage, config *order.Config, blockC chan *pb.CommitEvent,
 		blockC:           blockC,
 		cancel:           cancel,
 		isNew:            isNew,
+		signAlgorithm:    SignSecp256k1,
+		scheme:           &kitScheme{keyType: crypto.Secp256k1, priv: config.PrivKey},
+		verifyCache:      cache,
+		verifyWorkers:    int64(runtime.NumCPU()),
+		txStatus:         config.TxStatus,
 	}
+	stack.loadLowWatermark()
 	return stack, nil
 }
 
 func (s *Stack) Broadcast(msg *rbftpb.ConsensusMessage) error {
+	// the message must never be ahead of the persisted state
+	if err := s.flushState(); err != nil {
+		return err
+	}
 	data, err := msg.Marshal()
 	if err != nil {
 		return err
@@ -73,10 +135,16 @@ func (s *Stack) Broadcast(msg *rbftpb.ConsensusMessage) error {
 		Version: []byte("0.1.0"),
 	}
 
+	s.record(rbftaudit.Outbound, 0, data)
+	sentMessageCounter.WithLabelValues("broadcast", msg.Type.String()).Inc()
+	sentMessageBytes.WithLabelValues("broadcast", msg.Type.String()).Add(float64(len(data)))
 	return s.peerMgr.Broadcast(p2pmsg)
 }
 
 func (s *Stack) Unicast(msg *rbftpb.ConsensusMessage, to uint64) error {
+	if err := s.flushState(); err != nil {
+		return err
+	}
 	data, err := msg.Marshal()
 	if err != nil {
 		return err
@@ -87,10 +155,23 @@ func (s *Stack) Unicast(msg *rbftpb.ConsensusMessage, to uint64) error {
 		Data: data,
 	}
 
+	s.record(rbftaudit.Outbound, to, data)
+	sentMessageCounter.WithLabelValues("unicast", msg.Type.String()).Inc()
+	sentMessageBytes.WithLabelValues("unicast", msg.Type.String()).Add(float64(len(data)))
 	return s.peerMgr.AsyncSend(to, m)
 }
 
+// record appends a consensus message to the audit log, a failure is only
+// logged.
+func (s *Stack) record(direction rbftaudit.Direction, peer uint64, data []byte) {
+	if err := s.audit.Record(direction, peer, data); err != nil {
+		s.logger.Warningf("Record %s consensus message failed: %s", direction, err)
+	}
+}
+
 func (s *Stack) UpdateTable(change *rbftpb.ConfChange) {
+	// a failed write is logged by flushState, the change is agreed anyway
+	_ = s.flushState()
 	switch change.Type {
 	case rbftpb.ConfChangeType_ConfChangeAddNode:
 		newNodeID := change.NodeID
@@ -105,7 +186,9 @@ func (s *Stack) UpdateTable(change *rbftpb.ConfChange) {
 			return
 		}
 		s.peerMgr.AddNode(newNodeID, vpInfo)
+		s.nodesLock.Lock()
 		s.nodes[newNodeID] = vpInfo
+		s.nodesLock.Unlock()
 		if newNodeID == s.localID {
 			s.isNew = false
 		}
@@ -127,7 +210,10 @@ func (s *Stack) UpdateTable(change *rbftpb.ConfChange) {
 		delID := change.NodeID
 		oldPeers := s.peerMgr.Peers()
 		s.peerMgr.DelNode(delID)
+		s.nodesLock.Lock()
 		delete(s.nodes, delID)
+		s.nodesLock.Unlock()
+		s.verifyCache.purge()
 		if delID == s.localID {
 			delete(oldPeers, delID)
 			go s.stop(oldPeers)
@@ -162,11 +248,14 @@ func (s *Stack) UpdateTable(change *rbftpb.ConfChange) {
 			}
 			vpInfos[vpInfo.Id] = vpInfo
 		}
+		s.nodesLock.Lock()
 		s.nodes = vpInfos
+		s.nodesLock.Unlock()
+		s.verifyCache.purge()
 		// for restart node, if it has been deleted, then exit the consensus cluster.
 		isExit := s.peerMgr.UpdateRouter(vpInfos, s.isNew)
 		if isExit {
-			s.stop(vpInfos)
+			go s.stop(vpInfos)
 			return
 		}
 		cs := &rbftpb.ConfState{
@@ -176,134 +265,297 @@ func (s *Stack) UpdateTable(change *rbftpb.ConfChange) {
 	}
 }
 
+// Sign signs the message with the configured algorithm, the algorithm tag is
+// prepended to the signature.
 func (s *Stack) Sign(msg []byte) ([]byte, error) {
-	h := sha256.Sum256(msg)
-	return s.priv.Sign(h[:])
+	current := time.Now()
+	defer func() {
+		signDuration.Observe(float64(time.Since(current)) / float64(time.Second))
+	}()
+	sig, err := s.scheme.sign(msg)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{byte(s.signAlgorithm)}, sig...), nil
 }
 
+// Verify verifies the signature with the configured algorithm, a signature
+// tagged with any other algorithm is refused.
 func (s *Stack) Verify(peerID uint64, signature []byte, msg []byte) error {
-	h := sha256.Sum256(msg)
-	addr := types.NewAddressByStr(s.nodes[peerID].Account)
-	ret, err := asym.Verify(crypto.Secp256k1, signature, h[:], *addr)
-	if err != nil {
-		return err
+	current := time.Now()
+	defer func() {
+		verifyDuration.Observe(float64(time.Since(current)) / float64(time.Second))
+	}()
+	peer, ok := s.peer(peerID)
+	if !ok {
+		return fmt.Errorf("unknown peer %d", peerID)
 	}
-
-	if !ret {
-		return fmt.Errorf("verify error")
+	if len(signature) == 0 {
+		return fmt.Errorf("empty signature from peer %d", peerID)
 	}
-
+	// a node never falls back to another algorithm than the configured one
+	if alg := SignAlgorithm(signature[0]); alg != s.signAlgorithm {
+		return fmt.Errorf("peer %d signs with algorithm %d instead of the configured %d", peerID, alg, s.signAlgorithm)
+	}
+	key := verifyKey{
+		peer:   peerID,
+		digest: sha256.Sum256(msg),
+		sig:    string(signature),
+	}
+	if s.verifyCache.contains(key) {
+		return nil
+	}
+	if err := s.scheme.verify(peer, signature[1:], msg); err != nil {
+		return err
+	}
+	s.verifyCache.add(key)
 	return nil
 }
 
 func (s *Stack) Execute(requests []*pb.Transaction, localList []bool, seqNo uint64, timestamp int64) {
+	// a failed write is logged by flushState, the batch is committed by the
+	// quorum anyway
+	_ = s.flushState()
+	s.txStatus.Set(txstatus.Batched, seqNo, txHashes(requests)...)
+
 	s.readyC <- &ready{
 		txs:       requests,
 		localList: localList,
 		height:    seqNo,
+		timestamp: timestamp,
 	}
 }
 
-func (s *Stack) StateUpdate(seqNo uint64, digest string, peers []uint64) {
-	s.stateUpdating = true
-	s.stateUpdateHeight = seqNo
+func txHashes(txs []*pb.Transaction) []string {
+	hashes := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		if tx.TransactionHash != nil {
+			hashes = append(hashes, tx.TransactionHash.String())
+		}
+	}
+	return hashes
+}
 
+func (s *Stack) StateUpdate(seqNo uint64, digest string, peers []uint64) {
+	_ = s.flushState()
 	chain := s.getChainMetaFunc()
+	ctx := s.stateUpdate.begin(seqNo, chain.Height)
 	s.logger.WithFields(logrus.Fields{
 		"target":       seqNo,
 		"target_hash":  digest,
 		"current":      chain.Height,
 		"current_hash": chain.BlockHash.String(),
 	}).Info("State Update")
-	get := func(peers []uint64, i int) (block *pb.Block, err error) {
-		for _, id := range peers {
-			block, err = s.getBlock(id, i)
-			if err != nil {
-				s.logger.Error(err)
-				continue
-			}
 
-			return block, nil
+	// the executor reported the target already, nobody reports it again
+	if seqNo <= chain.Height {
+		if s.stateUpdate.reached(ctx) {
+			s.stateUpdated(&rbftpb.ServiceState{
+				Applied: chain.Height,
+				Digest:  chain.BlockHash.String(),
+			})
 		}
+		return
+	}
+	current := time.Now()
+	defer func() {
+		stateUpdateDuration.Observe(float64(time.Since(current)) / float64(time.Second))
+	}()
+
+	// block headers agreed by f+1 peers contain at least one honest replica,
+	// which is enough to trust the fetched range.
+	quorum := (s.nodeCount()-1)/3 + 1
+	syncBlocks := atomic.LoadUint64(&s.syncBlocks)
+	blockSyncer, err := syncer.New(syncBlocks, s.peerMgr, quorum, peers, s.logger)
+	if err != nil {
+		s.logger.Errorf("Create state syncer failed: %s", err.Error())
+		s.stateUpdate.fetchDone(ctx, err)
+		return
+	}
 
-		return nil, fmt.Errorf("can't get block from all peers")
+	// no block is handed over unless the peers serve the agreed target
+	header, err := blockSyncer.QuorumBlockHeader(seqNo)
+	if err == nil {
+		if hash := (&pb.Block{BlockHeader: header}).Hash().String(); hash != digest {
+			err = fmt.Errorf("the peers serve block %s at %d instead of the agreed %s", hash, seqNo, digest)
+		}
+	}
+	if err != nil {
+		s.stateUpdate.fetchDone(ctx, err)
+		s.logger.Errorf("State update to %d failed: %s", seqNo, err.Error())
+		return
 	}
 
-	blockCache := make([]*pb.Block, seqNo-chain.Height)
-	var block *pb.Block
-	for i := seqNo; i > chain.Height; i-- {
-		if err := retry.Retry(func(attempt uint) (err error) {
-			block, err = get(peers, int(i))
-			if err != nil {
-				s.logger.Info(err)
-				return err
+	begin := chain.Height + 1
+	parentHash := chain.BlockHash
+	var lastBlock *pb.Block
+	err = retry.Retry(func(attempt uint) error {
+		if ctx.Err() != nil {
+			return nil
+		}
+		blockCh := make(chan *pb.Block, syncBlocks+1)
+		errC := make(chan error, 1)
+		go func() {
+			if err := blockSyncer.SyncBFTBlocks(begin, seqNo, parentHash, blockCh); err != nil {
+				errC <- err
+				blockCh <- nil
+				return
 			}
-
-			if digest != block.BlockHash.String() {
-				s.logger.WithFields(logrus.Fields{
-					"required": digest,
-					"received": block.BlockHash.String(),
-					"height":   i,
-				}).Error("block hash is inconsistent in state update state")
-				return err
+			errC <- nil
+		}()
+
+		// blocks of every verified range are committed at once, so a failed
+		// attempt resumes from the last committed height instead of the start.
+		for block := range blockCh {
+			if block == nil {
+				break
 			}
+			localList := make([]bool, len(block.Transactions))
+			select {
+			case s.blockC <- &pb.CommitEvent{
+				Block:     block,
+				LocalList: localList,
+			}:
+			case <-ctx.Done():
+				// the syncer is left to finish on its own
+				go drainBlocks(blockCh)
+				return nil
+			}
+			stateUpdateBlocks.Inc()
+			s.stateUpdate.fetch(ctx, block.Height())
+			lastBlock = block
+			begin = block.Height() + 1
+			parentHash = block.BlockHash
+		}
 
-			digest = block.BlockHeader.ParentHash.String()
-			blockCache[i-chain.Height-1] = block
-
-			return nil
-		}, strategy.Wait(200*time.Millisecond)); err != nil {
-			s.logger.Error(err)
+		if err := <-errC; err != nil {
+			s.logger.WithFields(logrus.Fields{
+				"begin":   begin,
+				"end":     seqNo,
+				"attempt": attempt,
+			}).Errorf("Sync blocks failed: %s", err.Error())
+			return err
 		}
+		return nil
+	}, strategy.Limit(stateUpdateRetryLimit), strategy.Wait(200*time.Millisecond))
+	if ctx.Err() != nil {
+		s.logger.Warningf("State update to %d is cancelled at %d", seqNo, begin-1)
+		return
 	}
+	s.stateUpdate.fetchDone(ctx, err)
+	if err != nil {
+		s.logger.Errorf("State update to %d failed: %s", seqNo, err.Error())
+		return
+	}
+
+	if lastBlock != nil && lastBlock.BlockHash.String() != digest {
+		s.logger.Errorf("State update to %d failed: block hash is inconsistent, required %s, received %s",
+			seqNo, digest, lastBlock.BlockHash.String())
+	}
+}
 
-	for _, block := range blockCache {
+// drainBlocks reads the blocks of a cancelled sync up to its end.
+func drainBlocks(blockCh chan *pb.Block) {
+	for block := range blockCh {
 		if block == nil {
-			s.logger.Error("Receive a nil block")
 			return
 		}
-		localList := make([]bool, len(block.Transactions))
-		for i := 0; i < len(block.Transactions); i++ {
-			localList[i] = false
-		}
-		commitEvent := &pb.CommitEvent{
-			Block:     block,
-			LocalList: localList,
-		}
-		s.blockC <- commitEvent
 	}
 }
 
+// SendFilterEvent publishes the informs of the RBFT core as consensus events.
 func (s *Stack) SendFilterEvent(informType rbftpb.InformType, message ...interface{}) {
-	// TODO: add implement
+	_ = s.flushState()
+	ev := order.ConsensusEvent{
+		Type:   consensusEventType(informType),
+		NodeID: s.localID,
+		Detail: fmt.Sprintf("%s %v", informType.String(), message),
+	}
+	if s.getChainMetaFunc != nil {
+		ev.Height = s.getChainMetaFunc().Height
+	}
+	if s.nodeView != nil {
+		ev.View = s.nodeView()
+		ev.Primary = s.primary(ev.View)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"type":    ev.Type.String(),
+		"view":    ev.View,
+		"primary": ev.Primary,
+		"detail":  ev.Detail,
+	}).Info("Receive consensus event")
+	s.publish(ev)
 }
 
-func (s *Stack) getBlock(id uint64, i int) (*pb.Block, error) {
-	m := &pb.Message{
-		Type: pb.Message_GET_BLOCK,
-		Data: []byte(strconv.Itoa(i)),
+// publish never blocks the RBFT core, the event is dropped for a subscriber
+// which has fallen behind.
+func (s *Stack) publish(ev order.ConsensusEvent) {
+	if dropped := s.consensusFeed.Send(ev); dropped != 0 {
+		consensusEventsDropped.Add(float64(dropped))
+		s.logger.Warningf("Drop %s event for %d slow subscribers", ev.Type, dropped)
 	}
+}
 
-	res, err := s.peerMgr.Send(id, m)
-	if err != nil {
-		return nil, err
-	}
+func (s *Stack) peer(id uint64) (*pb.VpInfo, bool) {
+	s.nodesLock.RLock()
+	defer s.nodesLock.RUnlock()
+	peer, ok := s.nodes[id]
+	return peer, ok
+}
 
-	block := &pb.Block{}
-	if err := block.Unmarshal(res.Data); err != nil {
-		return nil, err
+// nodeCount returns the number of nodes in the routing table.
+func (s *Stack) nodeCount() uint64 {
+	s.nodesLock.RLock()
+	defer s.nodesLock.RUnlock()
+	return uint64(len(s.nodes))
+}
+
+// primary returns the primary of the view, the RBFT core rotates it over the
+// routing table, which is sorted by node id.
+func (s *Stack) primary(view uint64) uint64 {
+	s.nodesLock.RLock()
+	peers, err := sortPeers(s.nodes)
+	s.nodesLock.RUnlock()
+	if err != nil || len(peers) == 0 {
+		return 0
 	}
+	return peers[view%uint64(len(peers))].Id
+}
 
-	return block, nil
+func consensusEventType(informType rbftpb.InformType) order.ConsensusEventType {
+	switch informType {
+	case rbftpb.InformType_FilterFinishViewChange:
+		return order.ViewChanged
+	case rbftpb.InformType_FilterFinishRecovery:
+		return order.RecoveryFinished
+	case rbftpb.InformType_FilterFinishUpdateN:
+		return order.EpochChanged
+	case rbftpb.InformType_FilterFinishConfigChange:
+		return order.ConfChanged
+	case rbftpb.InformType_FilterStableCheckpoint:
+		return order.StableCheckpoint
+	default:
+		return order.UnknownConsensusEvent
+	}
 }
 
+// stop shuts down the order after this node has been removed from the
+// cluster, and reports the removal to the host as a NodeRemoved event.
 func (s *Stack) stop(peers map[uint64]*pb.VpInfo) {
-	s.logger.Infof("======== THIS NODE WILL STOP IN 3 SECONDS")
-	<-time.After(3 * time.Second)
+	s.logger.Infof("======== THIS NODE WILL STOP IN %v", removeDelay)
+	<-time.After(removeDelay)
+	// the RBFT core may persist its state until it has stopped, the storage
+	// is destroyed after that
+	s.stopNode()
 	s.cancel()
-	_ = s.Destroy()
+	if err := s.Destroy(); err != nil {
+		s.logger.Errorf("Destroy consensus storage failed: %s", err.Error())
+	}
 	s.peerMgr.Disconnect(peers)
 	s.logger.Infof("======== THIS NODE HAS BEEN DELETED!!!")
-	os.Exit(1)
-	return
+	s.publish(order.ConsensusEvent{
+		Type:   order.NodeRemoved,
+		NodeID: s.localID,
+		Detail: "this node has been removed from the cluster",
+	})
 }
@@ -1,12 +1,28 @@
-package main
+package rbft
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/meshplus/bitxhub-kit/log"
 	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
+	"github.com/meshplus/bitxhub/pkg/order/txstatus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/x509"
 	"github.com/ultramesh/rbft/rbftpb"
 )
 
@@ -114,6 +130,247 @@ func TestSignAndVerify(t *testing.T) {
 	ast.Nil(err)
 }
 
+func TestVerifyWithBadSignature(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	msgSign, err := node.stack.Sign([]byte("test sign"))
+	ast.Nil(err)
+	ast.Equal(byte(SignSecp256k1), msgSign[0])
+
+	err = node.stack.Verify(uint64(100), msgSign, []byte("test sign"))
+	ast.NotNil(err)
+
+	err = node.stack.Verify(uint64(1), nil, []byte("test sign"))
+	ast.NotNil(err)
+
+	badTag := append([]byte{byte(SignSM2)}, msgSign[1:]...)
+	err = node.stack.Verify(uint64(1), badTag, []byte("test sign"))
+	ast.NotNil(err)
+
+	err = node.stack.initSignSchemes("", &rbftconfig.CryptoConfig{Algorithm: "ecdsa_p256"})
+	ast.NotNil(err)
+
+	err = node.stack.initSignSchemes("", &rbftconfig.CryptoConfig{Algorithm: "rsa"})
+	ast.NotNil(err)
+
+	err = node.stack.initSignSchemes("", &rbftconfig.CryptoConfig{Algorithm: "ed25519"})
+	ast.NotNil(err)
+}
+
+func TestVerifyCache(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	msgSign, err := node.stack.Sign([]byte("test sign"))
+	ast.Nil(err)
+
+	hit := testutil.ToFloat64(verifyCacheCounter.WithLabelValues("hit"))
+	err = node.stack.Verify(uint64(1), msgSign, []byte("test sign"))
+	ast.Nil(err)
+	ast.Equal(hit, testutil.ToFloat64(verifyCacheCounter.WithLabelValues("hit")))
+	err = node.stack.Verify(uint64(1), msgSign, []byte("test sign"))
+	ast.Nil(err)
+	ast.Equal(hit+1, testutil.ToFloat64(verifyCacheCounter.WithLabelValues("hit")))
+
+	// failed verifications are not cached
+	err = node.stack.Verify(uint64(1), msgSign, []byte("wrong sign"))
+	ast.NotNil(err)
+	err = node.stack.Verify(uint64(1), msgSign, []byte("wrong sign"))
+	ast.NotNil(err)
+	ast.Equal(hit+1, testutil.ToFloat64(verifyCacheCounter.WithLabelValues("hit")))
+
+	// the same signature from another peer is verified again
+	err = node.stack.Verify(uint64(2), msgSign, []byte("test sign"))
+	ast.NotNil(err)
+
+	err = node.stack.initVerifyCache(&rbftconfig.CryptoConfig{VerifyCacheSize: 1, VerifyWorkers: 2})
+	ast.Nil(err)
+	ast.Equal(int64(2), node.stack.verifyWorkers)
+	ast.Equal(0, node.stack.verifyCache.cache.Len())
+}
+
+func TestVerifyBatch(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+
+	reqs := make([]*verifyRequest, 0)
+	for i := 0; i < 10; i++ {
+		msg := []byte(fmt.Sprintf("test sign %d", i))
+		msgSign, err := node.stack.Sign(msg)
+		ast.Nil(err)
+		if i%3 == 0 {
+			msg = []byte("wrong sign")
+		}
+		reqs = append(reqs, &verifyRequest{
+			peerID:    uint64(1),
+			signature: msgSign,
+			msg:       msg,
+		})
+	}
+
+	errs := node.stack.VerifyBatch(reqs)
+	ast.Equal(len(reqs), len(errs))
+	for i, err := range errs {
+		if i%3 == 0 {
+			ast.NotNil(err)
+		} else {
+			ast.Nil(err)
+		}
+	}
+
+	// the bundle has been cached for the following verifications
+	hit := testutil.ToFloat64(verifyCacheCounter.WithLabelValues("hit"))
+	err := node.stack.Verify(reqs[1].peerID, reqs[1].signature, reqs[1].msg)
+	ast.Nil(err)
+	ast.Equal(hit+1, testutil.ToFloat64(verifyCacheCounter.WithLabelValues("hit")))
+
+	ast.Equal(0, len(node.stack.VerifyBatch(nil)))
+}
+
+type testVcBasis struct {
+	ReplicaId uint64
+	View      uint64
+}
+
+func (b *testVcBasis) Reset()         {}
+func (b *testVcBasis) String() string { return fmt.Sprintf("%d-%d", b.ReplicaId, b.View) }
+func (b *testVcBasis) ProtoMessage()  {}
+func (b *testVcBasis) Marshal() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+type testViewChange struct {
+	Basis     *testVcBasis
+	Signature []byte
+}
+
+type testNewView struct {
+	ReplicaId     uint64
+	ViewChangeSet []*testViewChange
+}
+
+func (nv *testNewView) Reset()         {}
+func (nv *testNewView) String() string { return "" }
+func (nv *testNewView) ProtoMessage()  {}
+
+func TestSignedViewChanges(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+
+	newView := &testNewView{ReplicaId: 2}
+	for view := uint64(1); view <= 4; view++ {
+		basis := &testVcBasis{ReplicaId: 1, View: view}
+		data, err := basis.Marshal()
+		ast.Nil(err)
+		sig, err := node.stack.Sign(data)
+		ast.Nil(err)
+		newView.ViewChangeSet = append(newView.ViewChangeSet, &testViewChange{Basis: basis, Signature: sig})
+	}
+	// a forged view change
+	newView.ViewChangeSet[3].Basis.View = 5
+
+	reqs := signedViewChanges(newView)
+	ast.Equal(4, len(reqs))
+	errs := node.stack.VerifyBatch(reqs)
+	for i, err := range errs {
+		ast.Equal(uint64(1), reqs[i].peerID)
+		if i == 3 {
+			ast.NotNil(err)
+		} else {
+			ast.Nil(err)
+		}
+	}
+
+	// the core finds the view changes verified by the bundle in the cache
+	hit := testutil.ToFloat64(verifyCacheCounter.WithLabelValues("hit"))
+	err := node.stack.Verify(uint64(1), newView.ViewChangeSet[0].Signature, reqs[0].msg)
+	ast.Nil(err)
+	ast.Equal(hit+1, testutil.ToFloat64(verifyCacheCounter.WithLabelValues("hit")))
+
+	ast.Equal(0, len(signedViewChanges(&testNewView{})))
+}
+
+func TestSignAndVerifyWithEd25519(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	ast.Nil(err)
+	repoRoot := t.TempDir()
+	err = ioutil.WriteFile(filepath.Join(repoRoot, "consensus.key"), []byte(hex.EncodeToString(privKey.Seed())), 0600)
+	ast.Nil(err)
+	kitSign, err := node.stack.Sign([]byte("test sign"))
+	ast.Nil(err)
+
+	err = node.stack.initSignSchemes(repoRoot, &rbftconfig.CryptoConfig{
+		Algorithm:  "ed25519",
+		KeyFile:    "consensus.key",
+		PublicKeys: map[string]string{"1": hex.EncodeToString(pubKey)},
+	})
+	ast.Nil(err)
+
+	msgSign, err := node.stack.Sign([]byte("test sign"))
+	ast.Nil(err)
+	ast.Equal(byte(SignEd25519), msgSign[0])
+
+	err = node.stack.Verify(uint64(1), msgSign, []byte("wrong sign"))
+	ast.NotNil(err)
+
+	err = node.stack.Verify(uint64(1), msgSign, []byte("test sign"))
+	ast.Nil(err)
+
+	// a valid secp256k1 signature is no downgrade path
+	ast.Equal(byte(SignSecp256k1), kitSign[0])
+	err = node.stack.Verify(uint64(1), kitSign, []byte("test sign"))
+	ast.NotNil(err)
+	ast.Contains(err.Error(), "instead of the configured")
+}
+
+func TestSignAndVerifyWithSM2(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+
+	privKey, err := sm2.GenerateKey(rand.Reader)
+	ast.Nil(err)
+	repoRoot := t.TempDir()
+	err = ioutil.WriteFile(filepath.Join(repoRoot, "consensus.key"), []byte(x509.WritePrivateKeyToHex(privKey)), 0600)
+	ast.Nil(err)
+	kitSign, err := node.stack.Sign([]byte("test sign"))
+	ast.Nil(err)
+
+	err = node.stack.initSignSchemes(repoRoot, &rbftconfig.CryptoConfig{
+		Algorithm:  "sm2",
+		KeyFile:    "consensus.key",
+		PublicKeys: map[string]string{"1": x509.WritePublicKeyToHex(&privKey.PublicKey)},
+	})
+	ast.Nil(err)
+
+	msgSign, err := node.stack.Sign([]byte("test sign"))
+	ast.Nil(err)
+	ast.Equal(byte(SignSM2), msgSign[0])
+
+	err = node.stack.Verify(uint64(1), msgSign, []byte("wrong sign"))
+	ast.NotNil(err)
+
+	err = node.stack.Verify(uint64(1), msgSign, []byte("test sign"))
+	ast.Nil(err)
+
+	err = node.stack.Verify(uint64(1), kitSign, []byte("test sign"))
+	ast.NotNil(err)
+	ast.Contains(err.Error(), "instead of the configured")
+}
+
 func TestExecute(t *testing.T) {
 	ast := assert.New(t)
 	defer cleanData()
@@ -128,6 +385,58 @@ func TestExecute(t *testing.T) {
 	ast.Equal(uint64(2), block.Block.Height())
 }
 
+func TestExecuteTxStatus(t *testing.T) {
+	ast := assert.New(t)
+	ctrl := gomock.NewController(t)
+	logger := log.NewWithModule("order")
+
+	config := mockOrderConfig(logger, ctrl)
+	config.TxStatus = txstatus.New(0)
+	stack, err := NewStack(nil, config, make(chan *pb.CommitEvent, 1), nil, false)
+	ast.Nil(err)
+
+	tx := &pb.Transaction{Nonce: uint64(1)}
+	tx.TransactionHash = tx.Hash()
+	config.TxStatus.Set(txstatus.Pooled, 0, tx.TransactionHash.String())
+	stack.Execute([]*pb.Transaction{tx, {Nonce: uint64(2)}}, []bool{true, true}, uint64(3), time.Now().UnixNano())
+
+	status, ok := config.TxStatus.Get(tx.TransactionHash.String())
+	ast.True(ok)
+	ast.Equal(txstatus.Batched, status.State)
+	ast.Equal(uint64(3), status.Height)
+}
+
+func TestExecuteWithAgreedTimestamp(t *testing.T) {
+	ast := assert.New(t)
+	ctrl := gomock.NewController(t)
+	logger := log.NewWithModule("order")
+
+	txs := make([]*pb.Transaction, 0)
+	txs = append(txs, &pb.Transaction{Nonce: uint64(1)}, &pb.Transaction{Nonce: uint64(2)})
+	timestamp := time.Now().UnixNano()
+
+	executeHeader := func(timestamp int64) []byte {
+		stack, err := NewStack(nil, mockOrderConfig(logger, ctrl), make(chan *pb.CommitEvent, 1), nil, false)
+		ast.Nil(err)
+		stack.Execute(txs, []bool{true, true}, uint64(2), timestamp)
+		commitEvent := (<-stack.readyC).commitEvent()
+		ast.Equal(timestamp, commitEvent.Block.BlockHeader.Timestamp)
+
+		header, err := commitEvent.Block.BlockHeader.Marshal()
+		ast.Nil(err)
+		return header
+	}
+
+	// Block.Hash doesn't cover the timestamp, the marshalled headers are compared
+	header := executeHeader(timestamp)
+	for i := 1; i < 4; i++ {
+		// make sure the local wall clock differs between replicas
+		time.Sleep(time.Millisecond)
+		ast.Equal(header, executeHeader(timestamp))
+	}
+	ast.NotEqual(header, executeHeader(timestamp+1))
+}
+
 // refactor this unit test
 func TestUnicast(t *testing.T) {
 	ast := assert.New(t)
@@ -150,13 +459,265 @@ func TestStateUpdate(t *testing.T) {
 	defer cleanData()
 	ctrl := gomock.NewController(t)
 	node := mockNode(ctrl)
-	block := constructBlock("block3", uint64(3))
-	node.stack.StateUpdate(block.BlockHeader.Number, block.BlockHash.String(), []uint64{1, 2, 3})
-	ast.Equal(true, node.stack.stateUpdating)
+	node.stack.syncBlocks = 2
+	blocks := genBlocks(10)
+
+	target := blocks[4]
+	node.stack.StateUpdate(target.BlockHeader.Number, target.BlockHash.String(), []uint64{1, 2, 3})
+	ast.Equal(&order.StateUpdateProgress{
+		Phase:   order.StateUpdateApplying,
+		Target:  target.BlockHeader.Number,
+		Fetched: target.BlockHeader.Number,
+		Applied: 1,
+	}, node.stack.stateUpdate.progress())
+	for height := uint64(2); height <= target.BlockHeader.Number; height++ {
+		commitEvent := <-node.stack.blockC
+		ast.Equal(height, commitEvent.Block.BlockHeader.Number)
+		ast.Equal(blocks[height-1].BlockHash.String(), commitEvent.Block.BlockHash.String())
+		node.ReportState(height, commitEvent.Block.BlockHash, nil)
+		if height < target.BlockHeader.Number {
+			ast.Equal(height, node.stack.stateUpdate.progress().Applied)
+		}
+	}
+	ast.Equal(0, len(node.stack.blockC))
+	// the node is back to normal once the target is executed
+	ast.Nil(node.stack.stateUpdate.progress())
+	ast.Equal(target.BlockHeader.Number, atomic.LoadUint64(&node.lastCommitted))
+}
+
+func TestStateUpdateDigestMismatch(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	node.stack.syncBlocks = 2
+
+	// the peers serve a chain other than the agreed one
+	forged := constructBlock("forged", uint64(5))
+	node.stack.StateUpdate(5, forged.BlockHash.String(), []uint64{1, 2, 3})
+	ast.Equal(0, len(node.stack.blockC), "no block is executed")
+	ast.Nil(node.stack.stateUpdate.progress())
+
+	// the executed blocks are reported as usual after the failure
+	block := constructBlock("block2", uint64(2))
+	node.ReportState(2, block.BlockHash, nil)
+	ast.Equal(uint64(2), atomic.LoadUint64(&node.lastCommitted))
+}
+
+func TestStateUpdateReached(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	var states []*rbftpb.ServiceState
+	node.stack.stateUpdated = func(state *rbftpb.ServiceState) {
+		states = append(states, state)
+	}
+
+	// the core asks for a height the node has executed already
+	chain := getChainMetaFunc()
+	node.stack.StateUpdate(chain.Height, chain.BlockHash.String(), []uint64{1, 2, 3})
+	ast.Equal([]*rbftpb.ServiceState{{Applied: chain.Height, Digest: chain.BlockHash.String()}}, states)
+	ast.Equal(0, len(node.stack.blockC))
+	ast.Nil(node.stack.stateUpdate.progress())
+
+	block := constructBlock("block2", uint64(2))
+	node.ReportState(2, block.BlockHash, nil)
+	ast.Equal(uint64(2), atomic.LoadUint64(&node.lastCommitted))
+	ast.Equal(1, len(states), "the later blocks are executed as usual")
+}
+
+func TestStateUpdateCancel(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	node.stack.syncBlocks = 2
+	blocks := genBlocks(10)
+	// nobody executes the blocks
+	node.stack.blockC = make(chan *pb.CommitEvent)
+
+	target := blocks[4]
+	done := make(chan struct{})
+	go func() {
+		node.stack.StateUpdate(target.BlockHeader.Number, target.BlockHash.String(), []uint64{1, 2, 3})
+		close(done)
+	}()
+	ast.Eventually(func() bool {
+		progress := node.stack.stateUpdate.progress()
+		return progress != nil && progress.Phase == order.StateUpdateFetching
+	}, time.Second, 10*time.Millisecond)
+
+	node.stack.stateUpdate.stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cancelled state update is still running")
+	}
+	ast.Nil(node.stack.stateUpdate.progress())
+	// the later updates are cancelled at once
+	ast.NotNil(node.stack.stateUpdate.begin(target.BlockHeader.Number, 1).Err())
+}
+
+func TestSendFilterEvent(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+
+	ch := make(chan order.ConsensusEvent, 1)
+	sub := node.SubscribeConsensusEvent(ch)
+	defer sub.Unsubscribe()
+
+	node.stack.SendFilterEvent(rbftpb.InformType_FilterFinishRecovery, uint64(1))
+	ev := <-ch
+	ast.Equal(order.RecoveryFinished, ev.Type)
+	ast.Equal(node.id, ev.NodeID)
+	ast.Equal(uint64(1), ev.Height)
+	ast.Equal(node.n.Status().View, ev.View)
+	ast.Equal(node.stack.primary(ev.View), ev.Primary)
+	ast.NotZero(ev.Primary)
+
+	// a subscriber which never reads doesn't stall the core
+	slow := make(chan order.ConsensusEvent)
+	slowSub := node.SubscribeConsensusEvent(slow)
+	defer slowSub.Unsubscribe()
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 200; i++ {
+			node.stack.SendFilterEvent(rbftpb.InformType_FilterStableCheckpoint)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow subscriber blocks the consensus events")
+	}
+	ast.NotZero(node.stack.consensusFeed.Dropped())
+}
+
+func TestRemoveSelf(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	delay := removeDelay
+	removeDelay = 0
+	defer func() {
+		removeDelay = delay
+	}()
+	err := node.Start()
+	ast.Nil(err)
+
+	ch := make(chan order.ConsensusEvent, 1)
+	sub := node.SubscribeConsensusEvent(ch)
+	defer sub.Unsubscribe()
+
+	change := &rbftpb.ConfChange{
+		NodeID: node.id,
+		Type:   rbftpb.ConfChangeType_ConfChangeRemoveNode,
+	}
+	node.stack.UpdateTable(change)
+	select {
+	case ev := <-ch:
+		ast.Equal(order.NodeRemoved, ev.Type)
+		ast.Equal(node.id, ev.NodeID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait for node removed event timeout")
+	}
+	ast.NotNil(node.ctx.Err())
+	ast.Equal(2, len(node.stack.nodes))
+
+	// the host stops the order again on shutdown
+	node.Stop()
+}
+
+// closeCheckDB records the writes made after the storage is closed.
+type closeCheckDB struct {
+	rbftstorage.Store
+	lock       sync.Mutex
+	closed     bool
+	lateWrites int
+}
+
+func (db *closeCheckDB) Put(key, value []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.closed {
+		db.lateWrites++
+		return nil
+	}
+	return db.Store.Put(key, value)
+}
+
+func (db *closeCheckDB) Delete(key []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.closed {
+		db.lateWrites++
+		return nil
+	}
+	return db.Store.Delete(key)
+}
+
+func (db *closeCheckDB) Write(ops []rbftstorage.Op, sync bool) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.closed {
+		db.lateWrites++
+		return nil
+	}
+	return db.Store.Write(ops, sync)
+}
+
+func (db *closeCheckDB) Close() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.closed = true
+	return db.Store.Close()
+}
+
+func TestRemoveSelfStopsBeforeDestroy(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	delay := removeDelay
+	removeDelay = 0
+	defer func() {
+		removeDelay = delay
+	}()
+	db := &closeCheckDB{Store: node.stack.store.State}
+	node.stack.store.State = db
+	stopNode := node.stack.stopNode
+	node.stack.stopNode = func() {
+		stopNode()
+		// the RBFT core persists its state while stopping
+		_ = node.stack.StoreState("stopped", []byte("true"))
+	}
+	err := node.Start()
+	ast.Nil(err)
+
+	ch := make(chan order.ConsensusEvent, 1)
+	sub := node.SubscribeConsensusEvent(ch)
+	defer sub.Unsubscribe()
+
+	node.stack.UpdateTable(&rbftpb.ConfChange{
+		NodeID: node.id,
+		Type:   rbftpb.ConfChangeType_ConfChangeRemoveNode,
+	})
+	select {
+	case ev := <-ch:
+		ast.Equal(order.NodeRemoved, ev.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait for node removed event timeout")
+	}
 
-	block = constructBlock("block2", uint64(2))
-	node.stack.StateUpdate(block.BlockHeader.Number, block.BlockHash.String(), []uint64{1, 2, 3})
-	targetB := <-node.stack.blockC
-	ast.Equal(uint64(2), targetB.Block.BlockHeader.Number)
-	ast.Equal(true, node.stack.stateUpdating)
+	// the host stops the order again on shutdown
+	node.Stop()
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	ast.True(db.closed, "the storage is destroyed before the event is published")
+	ast.Equal(0, db.lateWrites, "no storage write happens after destroy")
 }
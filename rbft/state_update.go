@@ -0,0 +1,151 @@
+package rbft
+
+import (
+	"context"
+	"sync"
+
+	"github.com/meshplus/bitxhub/pkg/order"
+)
+
+// The RBFT core starts a state update on its own goroutine by StateUpdate,
+// while the host reports the executed blocks by ReportState from its own
+// goroutines. stateUpdater holds the update in progress under a lock: it is
+// fetching the blocks from the peers, applying them until the executor
+// reports the target, and turns idle once the target is reported. A failed
+// fetch, or a target the node has reached already, turns it idle at once, so
+// the executed blocks are reported to the core as usual again.
+// A newer update or the stop of the order cancels the context of the one in
+// progress, the fetch gives up and changes nothing after that.
+
+type stateUpdatePhase int
+
+const (
+	stateUpdateIdle stateUpdatePhase = iota
+	stateUpdateFetching
+	stateUpdateApplying
+)
+
+func (p stateUpdatePhase) String() string {
+	switch p {
+	case stateUpdateFetching:
+		return order.StateUpdateFetching
+	case stateUpdateApplying:
+		return order.StateUpdateApplying
+	default:
+		return "idle"
+	}
+}
+
+type stateUpdater struct {
+	lock    sync.Mutex
+	phase   stateUpdatePhase
+	target  uint64
+	fetched uint64 // height of the last block handed to the executor
+	applied uint64 // height of the last block the executor has reported
+	cancel  context.CancelFunc
+	stopped bool
+}
+
+// begin starts the update from the current height to the target and cancels
+// the one in progress. The context is done once the update is cancelled, it
+// is done at once after stop.
+func (u *stateUpdater) begin(target, current uint64) context.Context {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.cancel != nil {
+		u.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if u.stopped {
+		cancel()
+	}
+	u.phase = stateUpdateFetching
+	u.target = target
+	u.fetched = current
+	u.applied = current
+	u.cancel = cancel
+	return ctx
+}
+
+// fetch records a block of the update of ctx handed to the executor.
+func (u *stateUpdater) fetch(ctx context.Context, height uint64) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if ctx.Err() == nil && u.phase == stateUpdateFetching {
+		u.fetched = height
+	}
+}
+
+// fetchDone ends the fetch of the update of ctx, the executor may have
+// reported the target already. A failed update turns idle, the core starts
+// another one.
+func (u *stateUpdater) fetchDone(ctx context.Context, err error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if ctx.Err() != nil || u.phase != stateUpdateFetching {
+		return
+	}
+	if err != nil {
+		u.phase = stateUpdateIdle
+		return
+	}
+	u.phase = stateUpdateApplying
+}
+
+// reached ends the update of ctx whose target has been executed before it
+// began, it tells if the update is still the one in progress, only then the
+// target is reported to the core.
+func (u *stateUpdater) reached(ctx context.Context) bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if ctx.Err() != nil || u.phase != stateUpdateFetching {
+		return false
+	}
+	u.phase = stateUpdateIdle
+	return true
+}
+
+// report records a block reported by the executor, it tells if an update is
+// in progress and if the block is its target. The update turns idle on the
+// target, only one caller sees it reached.
+func (u *stateUpdater) report(height uint64) (updating, reached bool) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.phase == stateUpdateIdle {
+		return false, false
+	}
+	if height > u.applied {
+		u.applied = height
+	}
+	if height != u.target {
+		return true, false
+	}
+	u.phase = stateUpdateIdle
+	return true, true
+}
+
+// stop cancels the update in progress and the later ones.
+func (u *stateUpdater) stop() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.stopped = true
+	u.phase = stateUpdateIdle
+	if u.cancel != nil {
+		u.cancel()
+	}
+}
+
+// progress returns nil unless an update is in progress.
+func (u *stateUpdater) progress() *order.StateUpdateProgress {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.phase == stateUpdateIdle {
+		return nil
+	}
+	return &order.StateUpdateProgress{
+		Phase:   u.phase.String(),
+		Target:  u.target,
+		Fetched: u.fetched,
+		Applied: u.applied,
+	}
+}
@@ -0,0 +1,150 @@
+package rbft
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateUpdater(t *testing.T) {
+	ast := assert.New(t)
+	var u stateUpdater
+	ast.Nil(u.progress())
+	updating, _ := u.report(2)
+	ast.False(updating)
+
+	ctx := u.begin(10, 1)
+	u.fetch(ctx, 5)
+	ast.Equal(&order.StateUpdateProgress{Phase: order.StateUpdateFetching, Target: 10, Fetched: 5, Applied: 1}, u.progress())
+	u.fetchDone(ctx, errors.New("no quorum"))
+	ast.Nil(u.progress(), "a failed update turns idle")
+	updating, _ = u.report(3)
+	ast.False(updating)
+
+	// the core starts another update, the first one changes nothing after
+	next := u.begin(12, 5)
+	ast.NotNil(ctx.Err())
+	u.fetch(ctx, 9)
+	u.fetchDone(ctx, nil)
+	ast.Equal(&order.StateUpdateProgress{Phase: order.StateUpdateFetching, Target: 12, Fetched: 5, Applied: 5}, u.progress())
+
+	u.fetch(next, 12)
+	u.fetchDone(next, nil)
+	updating, reached := u.report(11)
+	ast.True(updating)
+	ast.False(reached)
+	ast.Equal(&order.StateUpdateProgress{Phase: order.StateUpdateApplying, Target: 12, Fetched: 12, Applied: 11}, u.progress())
+	updating, reached = u.report(12)
+	ast.True(updating)
+	ast.True(reached)
+	ast.Nil(u.progress())
+	ast.Nil(next.Err())
+
+	// the target has been executed before the update began
+	low := u.begin(10, 12)
+	ast.True(u.reached(low))
+	ast.False(u.reached(low), "only one caller reports the target")
+	ast.Nil(u.progress())
+	updating, _ = u.report(13)
+	ast.False(updating)
+
+	u.stop()
+	ast.NotNil(next.Err())
+	ast.NotNil(u.begin(20, 12).Err())
+	ast.False(u.reached(u.begin(10, 12)))
+}
+
+// TestStateUpdaterConcurrent runs the fetch of the core against the reports
+// of the host and the status readers, it is meant for -race.
+func TestStateUpdaterConcurrent(t *testing.T) {
+	const target = 200
+	var (
+		u       stateUpdater
+		wg      sync.WaitGroup
+		reached int32
+	)
+	for round := 0; round < 10; round++ {
+		reached = 0
+		ctx := u.begin(target, 0)
+		blocks := make(chan uint64, target)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for height := uint64(1); height <= target; height++ {
+				u.fetch(ctx, height)
+				blocks <- height
+			}
+			close(blocks)
+			u.fetchDone(ctx, nil)
+		}()
+		// the host reports every block from its own goroutine
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for height := range blocks {
+					if _, ok := u.report(height); ok {
+						atomic.AddInt32(&reached, 1)
+					}
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if progress := u.progress(); progress != nil {
+					assert.True(t, progress.Applied <= progress.Target)
+				}
+			}
+		}()
+		wg.Wait()
+
+		assert.Equal(t, int32(1), reached, "only one report sees the target")
+		assert.Nil(t, u.progress(), "the update ends on the target")
+	}
+}
+
+// TestStateUpdaterEndsIdle runs the updates which end without fetching the
+// target, a failed one and one below the current height, against the reports
+// of the host, it is meant for -race.
+func TestStateUpdaterEndsIdle(t *testing.T) {
+	var u stateUpdater
+	for round := 0; round < 20; round++ {
+		var wg sync.WaitGroup
+		ctx := u.begin(100, 50)
+		start := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if round%2 == 0 {
+				u.fetchDone(ctx, errors.New("no quorum"))
+				return
+			}
+			u.reached(ctx)
+		}()
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				<-start
+				for height := uint64(51 + i); height <= 90; height += 4 {
+					u.report(height)
+					u.progress()
+				}
+			}(i)
+		}
+		close(start)
+		wg.Wait()
+
+		assert.Nil(t, u.progress(), "round %d", round)
+		updating, _ := u.report(91)
+		assert.False(t, updating, "round %d", round)
+	}
+}
@@ -0,0 +1,233 @@
+package rbft
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
+)
+
+// The order stops in steps: it refuses new transactions, hands the batches
+// executed by the RBFT core over to the host, waits for the executor to
+// report the last of them, stops the core and closes the storage. A step cut
+// short by the deadline abandons what is left of it. The batches never handed
+// over are executed by the core already, they are saved to the storage as
+// unapplied blocks and handed over at their height after the restart, before
+// the core executes anything else.
+
+const (
+	// defaultStopTimeout bounds Stop, the host passes its own deadline to
+	// GracefulStop.
+	defaultStopTimeout = 10 * time.Second
+	stopPollInterval   = 10 * time.Millisecond
+)
+
+// Stop can be called more than once, the order stops itself when this node
+// is removed from the cluster and the host stops it again on shutdown.
+func (n *Node) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStopTimeout)
+	defer cancel()
+	n.GracefulStop(ctx)
+}
+
+func (n *Node) GracefulStop(ctx context.Context) *order.StopReport {
+	n.stopOnce.Do(func() {
+		n.stopReport = n.gracefulStop(ctx)
+		if n.stopReport.Abandoned() {
+			n.logger.Warningf("Order stopped, %s", n.stopReport)
+			return
+		}
+		n.logger.Infof("Order stopped, %s", n.stopReport)
+	})
+	return n.stopReport
+}
+
+func (n *Node) gracefulStop(ctx context.Context) *order.StopReport {
+	report := &order.StopReport{}
+	step := func(name string, done bool) {
+		report.Steps = append(report.Steps, name)
+		if !done {
+			report.TimedOut = append(report.TimedOut, name)
+		}
+	}
+
+	atomic.StoreUint32(&n.stopping, 1)
+	if n.txCache.close != nil {
+		close(n.txCache.close)
+	}
+	// the loop of Start hands the batches over while it runs, the one it
+	// holds when it quits is handed over first
+	if n.quitC != nil {
+		close(n.quitC)
+		<-n.loopDone
+	}
+	step(order.StopAccepting, true)
+
+	drained := true
+	if n.held != nil {
+		drained = n.deliver(ctx, n.held, report)
+		n.held = nil
+	}
+	for count := len(n.stack.readyC); count > 0; count-- {
+		if !n.deliver(ctx, <-n.stack.readyC, report) {
+			drained = false
+		}
+	}
+	step(order.StopDrain, drained)
+
+	step(order.StopExecutor, n.waitExecuted(ctx))
+
+	n.stack.stateUpdate.stop()
+	n.n.Stop()
+	// the batches the core executed in the meantime are not handed over
+	for abandoned := true; abandoned; {
+		select {
+		case r := <-n.stack.readyC:
+			n.abandon(r, report)
+		default:
+			abandoned = false
+		}
+	}
+	step(order.StopCore, true)
+
+	if err := n.saveUnapplied(); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	n.savePendingTxs(true)
+	report.PendingTxs = n.pendingTxs.Len()
+	if err := n.stack.audit.Close(); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("close consensus audit log: %s", err))
+	}
+	if err := n.stack.closeStore(); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("close consensus storage: %s", err))
+	}
+	step(order.StopStorage, true)
+	n.stack.cancel()
+
+	report.LastDelivered = atomic.LoadUint64(&n.lastDelivered)
+	report.LastReported = atomic.LoadUint64(&n.lastCommitted)
+	return report
+}
+
+// deliver hands the batch over to the host before the deadline, or abandons
+// it.
+func (n *Node) deliver(ctx context.Context, r *ready, report *order.StopReport) bool {
+	if n.isReplayed(r) {
+		return true
+	}
+	if ctx.Err() == nil {
+		select {
+		case n.blockC <- r.commitEvent():
+			atomic.StoreUint64(&n.lastDelivered, r.height)
+			return true
+		case <-ctx.Done():
+		}
+	}
+	n.abandon(r, report)
+	return false
+}
+
+// abandon keeps the batch to be saved as an unapplied block, its transactions
+// are not proposed again.
+func (n *Node) abandon(r *ready, report *order.StopReport) {
+	if n.isReplayed(r) {
+		return
+	}
+	n.abandoned = append(n.abandoned, r)
+	report.Undelivered = append(report.Undelivered, r.height)
+}
+
+func (n *Node) saveUnapplied() error {
+	if len(n.abandoned) == 0 {
+		return nil
+	}
+	events := make([]*pb.CommitEvent, 0, len(n.abandoned))
+	for _, r := range n.abandoned {
+		events = append(events, r.commitEvent())
+	}
+	err := n.stack.withStore(func(store *rbftstorage.Storage) error {
+		return rbftstorage.SaveUnapplied(store, events, true)
+	})
+	if err != nil {
+		return fmt.Errorf("save unapplied blocks: %w", err)
+	}
+	return nil
+}
+
+// loadUnapplied returns the blocks saved by the last stop which follow the
+// applied height, they are deleted once the host reports the last of them.
+// The blocks after a gap are left to the core to recover.
+func (n *Node) loadUnapplied() []*pb.CommitEvent {
+	events, err := rbftstorage.LoadUnapplied(n.stack.store)
+	if err != nil {
+		n.logger.Errorf("Load unapplied blocks failed: %s", err)
+		return nil
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	applied := atomic.LoadUint64(&n.lastCommitted)
+	replay := make([]*pb.CommitEvent, 0, len(events))
+	for _, ev := range events {
+		height := ev.Block.Height()
+		if height <= applied {
+			continue
+		}
+		if height != applied+uint64(len(replay))+1 {
+			break
+		}
+		replay = append(replay, ev)
+	}
+
+	atomic.StoreUint64(&n.unappliedTo, events[len(events)-1].Block.Height())
+	if len(replay) == 0 {
+		n.pruneUnapplied(applied)
+		return nil
+	}
+	n.replayed = replay[len(replay)-1].Block.Height()
+	n.logger.Infof("Replay %d unapplied blocks from height %d", len(replay), applied+1)
+	return replay
+}
+
+// pruneUnapplied deletes the saved blocks once the host has applied the last
+// of them.
+func (n *Node) pruneUnapplied(height uint64) {
+	to := atomic.LoadUint64(&n.unappliedTo)
+	if to == 0 || height < to {
+		return
+	}
+	err := n.stack.withStore(func(store *rbftstorage.Storage) error {
+		return rbftstorage.DeleteUnapplied(store, to, false)
+	})
+	if err != nil {
+		n.logger.Errorf("Delete unapplied blocks failed: %s", err)
+		return
+	}
+	atomic.StoreUint64(&n.unappliedTo, 0)
+}
+
+// isReplayed tells if the core executes again a batch replayed after the
+// restart.
+func (n *Node) isReplayed(r *ready) bool {
+	return r.height <= n.replayed
+}
+
+// waitExecuted waits for the executor to report the last block handed over.
+func (n *Node) waitExecuted(ctx context.Context) bool {
+	for atomic.LoadUint64(&n.lastCommitted) < atomic.LoadUint64(&n.lastDelivered) {
+		select {
+		case <-time.After(stopPollInterval):
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Node) isStopping() bool {
+	return atomic.LoadUint32(&n.stopping) == 1
+}
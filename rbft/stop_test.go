@@ -0,0 +1,126 @@
+package rbft
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/bitxhub-model/pb"
+	"github.com/meshplus/bitxhub/pkg/order"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/ultramesh/rbft/mempool"
+)
+
+var stopSteps = []string{order.StopAccepting, order.StopDrain, order.StopExecutor, order.StopCore, order.StopStorage}
+
+func TestGracefulStop(t *testing.T) {
+	defer cleanData()
+	ast := assert.New(t)
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	node.pendingTxs = rbftstorage.NewPendingTxs()
+	ast.Nil(node.Start())
+
+	// the executor reports every block it is handed
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case ev := <-node.Commit():
+				block := constructBlock("blockHash", ev.Block.Height())
+				node.ReportState(block.Height(), block.BlockHash, nil)
+			case <-done:
+				return
+			}
+		}
+	}()
+	node.stack.readyC <- &ready{height: uint64(2)}
+	node.stack.readyC <- &ready{height: uint64(3)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	report := node.GracefulStop(ctx)
+	ast.Equal(stopSteps, report.Steps)
+	ast.False(report.Abandoned(), report.String())
+	ast.Equal(uint64(3), report.LastDelivered)
+	ast.Equal(uint64(3), report.LastReported)
+	ast.Equal(0, report.PendingTxs)
+
+	tx := mempool.ConstructTx("account1")
+	tx.TransactionHash = tx.Hash()
+	ast.True(errors.Is(node.Prepare(tx), order.ErrBusy))
+	_, ok := <-node.txCache.close
+	ast.False(ok)
+	ast.Equal(errStoreClosed, node.stack.StoreState("view", []byte("1")))
+	ast.NotNil(node.ctx.Err())
+
+	// the later calls return the first report
+	node.Stop()
+	ast.Equal(report, node.GracefulStop(context.Background()))
+}
+
+func TestGracefulStopDeadline(t *testing.T) {
+	defer cleanData()
+	ast := assert.New(t)
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+	node.pendingTxs = rbftstorage.NewPendingTxs()
+	// nobody executes the blocks
+	node.blockC = make(chan *pb.CommitEvent)
+	ast.Nil(node.Start())
+
+	txs := make([]*pb.Transaction, 0, 2)
+	for i := 1; i <= 2; i++ {
+		tx := mempool.ConstructTx("account1")
+		tx.Nonce = uint64(i)
+		tx.TransactionHash = tx.Hash()
+		txs = append(txs, tx)
+		node.stack.readyC <- &ready{txs: []*pb.Transaction{tx}, localList: []bool{true}, height: uint64(i + 1)}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	report := node.GracefulStop(ctx)
+	ast.Equal(stopSteps, report.Steps)
+	ast.Equal([]string{order.StopDrain}, report.TimedOut)
+	ast.Equal([]uint64{2, 3}, report.Undelivered)
+	ast.Equal(uint64(0), report.LastDelivered)
+	ast.Equal(0, report.PendingTxs)
+	ast.True(report.Abandoned())
+
+	// the abandoned blocks are handed over at their height after the restart,
+	// their transactions are not proposed again
+	restarted := mockNode(ctrl)
+	restarted.pendingTxs = rbftstorage.NewPendingTxs()
+	restarted.lastCommitted = 1
+	ast.Nil(restarted.Start())
+	defer restarted.Stop()
+	for i, tx := range txs {
+		ev := <-restarted.Commit()
+		ast.Equal(uint64(i+2), ev.Block.Height())
+		ast.Equal(txHashes([]*pb.Transaction{tx}), txHashes(ev.Block.Transactions))
+		ast.Equal([]bool{true}, ev.LocalList)
+	}
+	ast.Equal(0, restarted.pendingTxs.Len())
+
+	// the core executing them again doesn't hand them over twice
+	restarted.stack.readyC <- &ready{height: uint64(3)}
+	restarted.stack.readyC <- &ready{height: uint64(4)}
+	ev := <-restarted.Commit()
+	ast.Equal(uint64(4), ev.Block.Height())
+
+	// they are deleted once the host reports the last of them
+	restarted.ReportState(2, types.NewHashByStr("0x0000000000000000000000000000000000000000000000000000000000000002"), nil)
+	unapplied, err := rbftstorage.LoadUnapplied(restarted.stack.store)
+	ast.Nil(err)
+	ast.Len(unapplied, 2)
+	restarted.ReportState(3, types.NewHashByStr("0x0000000000000000000000000000000000000000000000000000000000000003"), nil)
+	unapplied, err = rbftstorage.LoadUnapplied(restarted.stack.store)
+	ast.Nil(err)
+	ast.Len(unapplied, 0)
+}
@@ -1,109 +1,257 @@
-package main
+package rbft
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
-	"github.com/meshplus/bitxhub-kit/storage"
-	"github.com/meshplus/bitxhub-kit/storage/leveldb"
-	"github.com/meshplus/bitxhub-kit/storage/minifile"
-	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
 )
 
-type Storage struct {
-	DB   storage.Storage
-	File *minifile.MiniFile
-}
+// The writes of the RBFT core are grouped and written at once before the node
+// acts on them, that is before a message is sent, a batch is executed, a state
+// update is started, an event is published or the state is read. The core
+// persists what a message depends on before sending it, so a crash in between
+// loses a group the other nodes have never seen. A failed write is sticky, the
+// node stops sending messages and every later write returns the error.
 
-func NewStorage(path string) (*Storage, error) {
-	db, err := leveldb.New(path)
-	if err != nil {
-		return nil, err
+// errStoreClosed is sticky like a failed write, the writes after the storage
+// is closed return it.
+var errStoreClosed = errors.New("consensus storage is closed")
+
+// maxPendingOps bounds the writes kept in memory between two flushes.
+const maxPendingOps = 1024
+
+// criticalKeys are the prefixes of the keys the RBFT core restores its view,
+// its watermarks and its checkpoints from, a group writing one of them is
+// synced to the disk if rbft.storage.sync_critical is enabled.
+var criticalKeys = []string{"view", "setView", "new-view", "nodes", "rbft.", "chkpt."}
+
+func isCriticalKey(key string) bool {
+	for _, prefix := range criticalKeys {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
 	}
+	return false
+}
 
-	file, err := minifile.New(filepath.Join(path, "file"))
-	if err != nil {
-		return nil, err
+// watermarkKey is where the RBFT core persists its low watermark, the
+// sequence number of its last stable checkpoint, as a decimal text.
+const watermarkKey = "rbft.h"
+
+// StoreState stores a key,value pair to the database with the given namespace,
+// it returns the error of an earlier write
+func (s *Stack) StoreState(key string, value []byte) error {
+	if key == watermarkKey {
+		s.setLowWatermark(value)
 	}
+	return s.writeState(key, func(b *rbftstorage.Batch) {
+		b.PutState([]byte("consensus."+key), value)
+	})
+}
 
-	return &Storage{
-		DB:   db,
-		File: file,
-	}, nil
+// DelState removes a key,value pair from the database with the given namespace,
+// it returns the error of an earlier write
+func (s *Stack) DelState(key string) error {
+	return s.writeState(key, func(b *rbftstorage.Batch) {
+		b.DeleteState([]byte("consensus." + key))
+	})
 }
 
-// StoreState stores a key,value pair to the database with the given namespace
-func (s *Stack) StoreState(key string, value []byte) error {
-	s.store.DB.Put([]byte("consensus."+key), value)
+func (s *Stack) writeState(key string, write func(b *rbftstorage.Batch)) error {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	if s.storeErr != nil {
+		return s.storeErr
+	}
+
+	write(&s.pending)
+	if isCriticalKey(key) {
+		s.pendingCritical = true
+	}
+	if s.pending.Len() >= maxPendingOps {
+		return s.flushStateLocked()
+	}
 	return nil
 }
 
-// DelState removes a key,value pair from the database with the given namespace
-func (s *Stack) DelState(key string) error {
-	s.store.DB.Delete([]byte("consensus." + key))
+// flushState writes the pending group.
+func (s *Stack) flushState() error {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	return s.flushStateLocked()
+}
+
+func (s *Stack) flushStateLocked() error {
+	if s.storeErr != nil {
+		return s.storeErr
+	}
+	if s.pending.Len() == 0 {
+		return nil
+	}
+
+	sync := s.pendingCritical && atomic.LoadUint32(&s.syncCritical) == 1
+	if err := s.store.Write(&s.pending, sync); err != nil {
+		s.storeErr = fmt.Errorf("write consensus state: %w", err)
+		s.logger.Errorf("%s, the node stops sending consensus messages", s.storeErr)
+		return s.storeErr
+	}
+	s.pending.Reset()
+	s.pendingCritical = false
 	return nil
 }
 
+func (s *Stack) setSyncCritical(sync bool) {
+	var v uint32
+	if sync {
+		v = 1
+	}
+	atomic.StoreUint32(&s.syncCritical, v)
+}
+
 // ReadState retrieves a value to a key from the database with the given namespace
 func (s *Stack) ReadState(key string) ([]byte, error) {
-	b := s.store.DB.Get([]byte("consensus." + key))
-	if b == nil {
-		return nil, errors.ErrNotFound
+	if err := s.flushState(); err != nil {
+		return nil, err
 	}
-	return b, nil
+	return s.store.State.Get([]byte("consensus." + key))
 }
 
 // ReadStateSet retrieves all key-value pairs where the key starts with prefix from the database with the given namespace
 func (s *Stack) ReadStateSet(prefix string) (map[string][]byte, error) {
-	prefixRaw := []byte("consensus." + prefix)
-
-	ret := make(map[string][]byte)
-	it := s.store.DB.Prefix(prefixRaw)
-	if it == nil {
-		err := errors.New(fmt.Sprint("Can't get Iterator"))
+	if err := s.flushState(); err != nil {
 		return nil, err
 	}
+	prefixRaw := []byte("consensus." + prefix)
 
-	if !it.Seek(prefixRaw) {
-		err := fmt.Errorf("can not find key with %s in database", prefixRaw)
+	ret := make(map[string][]byte)
+	err := s.store.State.Iterate(prefixRaw, func(key, value []byte) bool {
+		ret[string(key[len("consensus."):])] = value
+		return true
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	for bytes.HasPrefix(it.Key(), prefixRaw) {
-		key := string(it.Key())
-		key = key[len("consensus."):]
-		ret[key] = append([]byte(nil), it.Value()...)
-		if !it.Next() {
-			break
-		}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("can not find key with %s in database", prefixRaw)
 	}
 	return ret, nil
 }
 
+// Destroy writes the pending group, removes all batches and closes the
+// storage, the first error is returned.
 func (s *Stack) Destroy() error {
 	// TODO (xcc): Destroy db
-	_ = s.store.DB.Close()
-	_ = s.DeleteAllBatchState()
-	return nil
+	err := s.DeleteAllBatchState()
+	if closeErr := s.closeStore(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// withStore runs fn on the storage unless it is closed.
+func (s *Stack) withStore(fn func(store *rbftstorage.Storage) error) error {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	if s.storeErr == errStoreClosed {
+		return errStoreClosed
+	}
+	return fn(s.store)
+}
+
+// closeStore writes the pending group and closes the storage, it can be called
+// more than once.
+func (s *Stack) closeStore() error {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	if s.storeErr == errStoreClosed {
+		return nil
+	}
+	err := s.flushStateLocked()
+	if closeErr := s.store.Close(); err == nil {
+		err = closeErr
+	}
+	s.storeErr = errStoreClosed
+	return err
 }
 
 func (s *Stack) StoreBatchState(key string, value []byte) error {
-	return s.store.File.Put(key, value)
+	return s.writeState(key, func(b *rbftstorage.Batch) {
+		b.PutBatch([]byte(key), value)
+	})
 }
 
 func (s *Stack) DelBatchState(key string) error {
-	return s.store.File.Delete(key)
+	return s.writeState(key, func(b *rbftstorage.Batch) {
+		b.DeleteBatch([]byte(key))
+	})
 }
 
+// ReadBatchState returns nil without error for a missing batch.
 func (s *Stack) ReadBatchState(key string) ([]byte, error) {
-	return s.store.File.Get(key)
+	if err := s.flushState(); err != nil {
+		return nil, err
+	}
+	value, err := s.store.Batches.Get([]byte(key))
+	if err == rbftstorage.ErrNotFound {
+		return nil, nil
+	}
+	return value, err
 }
 
 func (s *Stack) ReadAllBatchState() (map[string][]byte, error) {
-	return s.store.File.GetAll()
+	if err := s.flushState(); err != nil {
+		return nil, err
+	}
+	ret := make(map[string][]byte)
+	err := s.store.Batches.Iterate(nil, func(key, value []byte) bool {
+		ret[string(key)] = value
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
 }
 
+// DeleteAllBatchState removes all batches at once.
 func (s *Stack) DeleteAllBatchState() error {
-	return s.store.File.DeleteAll()
+	batches, err := s.ReadAllBatchState()
+	if err != nil {
+		return err
+	}
+
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+	for key := range batches {
+		s.pending.DeleteBatch([]byte(key))
+	}
+	return s.flushStateLocked()
+}
+
+// setLowWatermark keeps the low watermark persisted by the core for Status.
+func (s *Stack) setLowWatermark(value []byte) {
+	h, err := strconv.ParseUint(string(value), 10, 64)
+	if err != nil {
+		s.logger.Warningf("Parse low watermark %q failed: %s", value, err)
+		return
+	}
+	atomic.StoreUint64(&s.lowWatermark, h)
+}
+
+// loadLowWatermark restores the low watermark the core persisted before the
+// restart.
+func (s *Stack) loadLowWatermark() {
+	if s.store == nil {
+		return
+	}
+	value, err := s.store.State.Get([]byte("consensus." + watermarkKey))
+	if err != nil || value == nil {
+		return
+	}
+	s.setLowWatermark(value)
 }
@@ -1,10 +1,12 @@
-package main
+package rbft
 
 import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/meshplus/bitxhub/pkg/order/rbftstorage"
 	"github.com/stretchr/testify/assert"
+	"github.com/ultramesh/rbft/rbftpb"
 )
 
 func TestDBStore(t *testing.T) {
@@ -66,3 +68,47 @@ func TestFileStore(t *testing.T) {
 	ret, _ = node.stack.ReadAllBatchState()
 	ast.Equal(0, len(ret))
 }
+
+func TestStateGroup(t *testing.T) {
+	ast := assert.New(t)
+	defer cleanData()
+	ctrl := gomock.NewController(t)
+	node := mockNode(ctrl)
+
+	ast.Nil(node.stack.StoreState("view", []byte("1")))
+	ast.Nil(node.stack.StoreBatchState("batch1", []byte("txs")))
+	_, err := node.stack.store.State.Get([]byte("consensus.view"))
+	ast.Equal(rbftstorage.ErrNotFound, err, "the group is not written yet")
+
+	ret, err := node.stack.ReadState("view")
+	ast.Nil(err)
+	ast.Equal([]byte("1"), ret)
+	ret, err = node.stack.store.Batches.Get([]byte("batch1"))
+	ast.Nil(err)
+	ast.Equal([]byte("txs"), ret)
+	ast.Nil(node.stack.store.Verify())
+
+	// the failed write is returned by every later write
+	ast.Nil(node.stack.store.Close())
+	ast.Nil(node.stack.StoreState("view", []byte("2")))
+	_, err = node.stack.ReadState("view")
+	ast.NotNil(err)
+	ast.Equal(err, node.stack.StoreState("view", []byte("3")))
+	ast.Equal(err, node.stack.DelState("view"))
+}
+
+func TestDecodeState(t *testing.T) {
+	ast := assert.New(t)
+	v, err := DecodeState(rbftstorage.KindView, "consensus.view", []byte("1"))
+	ast.Nil(err)
+	ast.Nil(v)
+	_, err = DecodeState(rbftstorage.KindPSet, "consensus.pset.0.1.digest", []byte{0xff})
+	ast.NotNil(err)
+
+	prePrepare := &rbftpb.PrePrepare{SequenceNumber: 3, BatchDigest: "digest"}
+	data, err := prePrepare.Marshal()
+	ast.Nil(err)
+	v, err = DecodeState(rbftstorage.KindQSet, "consensus.qset.0.3.digest", data)
+	ast.Nil(err)
+	ast.Equal(prePrepare.BatchDigest, v.(*rbftpb.PrePrepare).BatchDigest)
+}
@@ -1,6 +1,7 @@
-package main
+package rbft
 
 import (
+	"sync"
 	"time"
 
 	"github.com/meshplus/bitxhub-model/pb"
@@ -24,11 +25,15 @@ type TxCache struct {
 	stopTimerC chan bool
 	txSetTick  time.Duration
 	txSetSize  uint64
+	paramLock  sync.RWMutex
 }
 
-func newTxCache(txSliceTimeout time.Duration, txSetSize uint64, logger logrus.FieldLogger) *TxCache {
+func newTxCache(txSliceTimeout time.Duration, txSetSize uint64, cacheSize int, logger logrus.FieldLogger) *TxCache {
 	txCache := &TxCache{}
-	txCache.recvTxC = make(chan *pb.Transaction, DefaultTxCacheSize)
+	if cacheSize <= 0 {
+		cacheSize = DefaultTxCacheSize
+	}
+	txCache.recvTxC = make(chan *pb.Transaction, cacheSize)
 	txCache.txSetC = make(chan []*pb.Transaction)
 	txCache.close = make(chan bool)
 	txCache.timerC = make(chan bool)
@@ -74,7 +79,11 @@ func (tc *TxCache) appendTx(tx *pb.Transaction) {
 		tc.startTxSetTimer()
 	}
 	tc.txSet = append(tc.txSet, tx)
-	if uint64(len(tc.txSet)) >= tc.txSetSize {
+	txSetDepth.Set(float64(len(tc.txSet)))
+	tc.paramLock.RLock()
+	txSetSize := tc.txSetSize
+	tc.paramLock.RUnlock()
+	if uint64(len(tc.txSet)) >= txSetSize {
 		tc.stopTxSetTimer()
 		tc.postTxSet()
 	}
@@ -85,15 +94,42 @@ func (tc *TxCache) postTxSet() {
 	copy(dst, tc.txSet)
 	tc.txSetC <- dst
 	tc.txSet = make([]*pb.Transaction, 0)
+	txSetDepth.Set(0)
 }
 
 func (tc *TxCache) IsFull() bool {
-	return len(tc.recvTxC) == DefaultTxCacheSize
+	return len(tc.recvTxC) == cap(tc.recvTxC)
+}
+
+// push hands the transaction over to the cache without blocking, it returns
+// false if the cache is full.
+func (tc *TxCache) push(tx *pb.Transaction) bool {
+	select {
+	case tc.recvTxC <- tx:
+		return true
+	default:
+		return false
+	}
+}
+
+func (tc *TxCache) setTxSetSize(txSetSize uint64) {
+	tc.paramLock.Lock()
+	defer tc.paramLock.Unlock()
+	tc.txSetSize = txSetSize
+}
+
+func (tc *TxCache) setTxSetTick(txSetTick time.Duration) {
+	tc.paramLock.Lock()
+	defer tc.paramLock.Unlock()
+	tc.txSetTick = txSetTick
 }
 
 func (tc *TxCache) startTxSetTimer() {
+	tc.paramLock.RLock()
+	txSetTick := tc.txSetTick
+	tc.paramLock.RUnlock()
 	go func() {
-		timer := time.NewTimer(tc.txSetTick)
+		timer := time.NewTimer(txSetTick)
 		select {
 		case <-timer.C:
 			tc.timerC <- true
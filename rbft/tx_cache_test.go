@@ -1,4 +1,4 @@
-package main
+package rbft
 
 import (
 	"testing"
@@ -13,7 +13,7 @@ func TestAppendTx(t *testing.T) {
 	ast := assert.New(t)
 	logger := log.NewWithModule("consensus")
 	sliceTimeout := 1 * time.Millisecond
-	txCache := newTxCache(sliceTimeout, 2, logger)
+	txCache := newTxCache(sliceTimeout, 2, 0, logger)
 	go txCache.listenEvent()
 
 	tx := &pb.Transaction{}
@@ -42,3 +42,20 @@ func TestAppendTx(t *testing.T) {
 	// test exit txCache
 	close(txCache.close)
 }
+
+func TestPushTx(t *testing.T) {
+	ast := assert.New(t)
+	logger := log.NewWithModule("consensus")
+	txCache := newTxCache(0, 0, 2, logger)
+	ast.Equal(DefaultTxSetTick, txCache.txSetTick)
+	ast.Equal(uint64(DefaultTxSetSize), txCache.txSetSize)
+
+	ast.True(txCache.push(&pb.Transaction{Nonce: 1}))
+	ast.False(txCache.IsFull())
+	ast.True(txCache.push(&pb.Transaction{Nonce: 2}))
+	ast.True(txCache.IsFull())
+	ast.False(txCache.push(&pb.Transaction{Nonce: 3}), "push doesn't block on a full cache")
+
+	<-txCache.recvTxC
+	ast.True(txCache.push(&pb.Transaction{Nonce: 3}))
+}
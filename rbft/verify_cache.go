@@ -0,0 +1,219 @@
+package rbft
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gogo/protobuf/proto"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/meshplus/bitxhub/pkg/order/rbftconfig"
+	"github.com/ultramesh/rbft/rbftpb"
+)
+
+const defaultVerifyCacheSize = 10000
+
+// verifyKey identifies a verified signature, the message is kept as its
+// digest so the cache doesn't hold the consensus payloads.
+type verifyKey struct {
+	peer   uint64
+	digest [sha256.Size]byte
+	sig    string
+}
+
+// verifyCache remembers the signatures which have been verified, so the
+// payloads carried again by view change and new view messages are not
+// verified from scratch. Only successful verifications are cached.
+type verifyCache struct {
+	cache *lru.Cache
+}
+
+func newVerifyCache(size int) (*verifyCache, error) {
+	if size <= 0 {
+		size = defaultVerifyCacheSize
+	}
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyCache{cache: cache}, nil
+}
+
+func (c *verifyCache) contains(key verifyKey) bool {
+	if c.cache.Contains(key) {
+		verifyCacheCounter.WithLabelValues("hit").Inc()
+		return true
+	}
+	verifyCacheCounter.WithLabelValues("miss").Inc()
+	return false
+}
+
+func (c *verifyCache) add(key verifyKey) {
+	c.cache.Add(key, struct{}{})
+}
+
+func (c *verifyCache) purge() {
+	c.cache.Purge()
+}
+
+func (c *verifyCache) resize(size int) {
+	if size <= 0 {
+		size = defaultVerifyCacheSize
+	}
+	c.cache.Resize(size)
+}
+
+// initVerifyCache resizes the verification cache and sets the number of
+// goroutines used by VerifyBatch.
+func (s *Stack) initVerifyCache(config *rbftconfig.CryptoConfig) error {
+	cache, err := newVerifyCache(config.VerifyCacheSize)
+	if err != nil {
+		return fmt.Errorf("create verify cache: %w", err)
+	}
+	s.verifyCache = cache
+	s.setVerifyWorkers(config.VerifyWorkers)
+	return nil
+}
+
+// setVerifyWorkers sets the number of goroutines used by VerifyBatch, it
+// falls back to the number of CPUs if workers is not positive.
+func (s *Stack) setVerifyWorkers(workers int) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	atomic.StoreInt64(&s.verifyWorkers, int64(workers))
+}
+
+// verifyRequest is a signed message of a bundle waiting for verification.
+type verifyRequest struct {
+	peerID    uint64
+	signature []byte
+	msg       []byte
+}
+
+// VerifyBatch verifies the signatures of a message bundle, such as a view
+// change set, concurrently. The errors are in the same order as the requests,
+// and the verified signatures are cached for the following Verify calls.
+func (s *Stack) VerifyBatch(reqs []*verifyRequest) []error {
+	errs := make([]error, len(reqs))
+	workers := int(atomic.LoadInt64(&s.verifyWorkers))
+	if workers > len(reqs) {
+		workers = len(reqs)
+	}
+
+	indexC := make(chan int, len(reqs))
+	for i := range reqs {
+		indexC <- i
+	}
+	close(indexC)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for index := range indexC {
+				req := reqs[index]
+				errs[index] = s.Verify(req.peerID, req.signature, req.msg)
+			}
+		}()
+	}
+	wg.Wait()
+
+	verifyBatchSize.Observe(float64(len(reqs)))
+	return errs
+}
+
+// bundleMessages are the consensus messages carrying the signed view changes
+// of other replicas, by the name of their type.
+var bundleMessages = map[string]func() proto.Message{
+	"VIEW_CHANGE": func() proto.Message { return &rbftpb.ViewChange{} },
+	"NEW_VIEW":    func() proto.Message { return &rbftpb.NewView{} },
+}
+
+// verifyBundle verifies the signed view changes of a view change or new view
+// message with VerifyBatch, before the RBFT core checks them one by one and
+// finds them in the cache. The core still decides on every signature, so a
+// message is never dropped here.
+func (s *Stack) verifyBundle(m *rbftpb.ConsensusMessage) {
+	newMsg, ok := bundleMessages[m.Type.String()]
+	if !ok {
+		return
+	}
+	msg := newMsg()
+	if err := proto.Unmarshal(m.Payload, msg); err != nil {
+		return
+	}
+	reqs := signedViewChanges(msg)
+	if len(reqs) == 0 {
+		return
+	}
+	for i, err := range s.VerifyBatch(reqs) {
+		if err != nil {
+			s.logger.Debugf("Verify view change of %d in %s from %d failed: %s", reqs[i].peerID, m.Type, m.From, err)
+		}
+	}
+}
+
+// signedViewChanges collects the view changes nested in msg, a view change is
+// a Basis naming its replica and the Signature of the marshalled Basis.
+func signedViewChanges(msg proto.Message) []*verifyRequest {
+	reqs := make([]*verifyRequest, 0)
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Ptr:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Slice:
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				return
+			}
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		case reflect.Struct:
+			if req := viewChangeRequest(v); req != nil {
+				reqs = append(reqs, req)
+				return
+			}
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).PkgPath == "" {
+					walk(v.Field(i))
+				}
+			}
+		}
+	}
+	walk(reflect.ValueOf(msg))
+	return reqs
+}
+
+func viewChangeRequest(v reflect.Value) *verifyRequest {
+	basis := v.FieldByName("Basis")
+	signature := v.FieldByName("Signature")
+	if !basis.IsValid() || basis.Kind() != reflect.Ptr || basis.IsNil() ||
+		!signature.IsValid() || signature.Type() != reflect.TypeOf([]byte(nil)) {
+		return nil
+	}
+	basisMsg, ok := basis.Interface().(proto.Message)
+	if !ok {
+		return nil
+	}
+	replicaID := basis.Elem().FieldByName("ReplicaId")
+	if !replicaID.IsValid() || replicaID.Kind() != reflect.Uint64 {
+		return nil
+	}
+	data, err := proto.Marshal(basisMsg)
+	if err != nil {
+		return nil
+	}
+	return &verifyRequest{
+		peerID:    replicaID.Uint(),
+		signature: signature.Bytes(),
+		msg:       data,
+	}
+}